@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+// keyFromEnv reads a database key from <prefix>_KEY (base64),
+// <prefix>_KEY_FILE (a file holding the base64 key) or <prefix>_PASSPHRASE.
+// It returns nil when none is set.
+func keyFromEnv(prefix string) (*db.Key, error) {
+	encoded := os.Getenv(prefix + "_KEY")
+	file := os.Getenv(prefix + "_KEY_FILE")
+	passphrase := os.Getenv(prefix + "_PASSPHRASE")
+
+	set := 0
+	for _, v := range []string{encoded, file, passphrase} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("set only one of %s_KEY, %s_KEY_FILE and %s_PASSPHRASE", prefix, prefix, prefix)
+	}
+
+	switch {
+	case encoded != "":
+		return db.ParseKey(encoded)
+	case file != "":
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		return db.ParseKey(string(contents))
+	case passphrase != "":
+		return db.PassphraseKey(passphrase)
+	default:
+		return nil, nil
+	}
+}
+
+// runGenKey prints a random key suitable for ARMOR_DB_KEY.
+func runGenKey() {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(key))
+}
+
+// runRekey re-encrypts the database from the current key (ARMOR_DB_KEY,
+// ARMOR_DB_KEY_FILE or ARMOR_DB_PASSPHRASE, unset for a plaintext database)
+// to the new one (ARMOR_DB_NEW_KEY, ARMOR_DB_NEW_KEY_FILE or
+// ARMOR_DB_NEW_PASSPHRASE). Stop the server first.
+func runRekey(args []string) {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError)
+	dbPath := flags.String("db", "./armor.db", "Database path")
+	flags.Parse(args)
+
+	if envDB := os.Getenv("ARMOR_DB_PATH"); envDB != "" {
+		*dbPath = envDB
+	}
+
+	absDBPath, err := filepath.Abs(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to resolve database path: %v", err)
+	}
+
+	current, err := keyFromEnv("ARMOR_DB")
+	if err != nil {
+		log.Fatalf("Invalid current key: %v", err)
+	}
+	next, err := keyFromEnv("ARMOR_DB_NEW")
+	if err != nil {
+		log.Fatalf("Invalid new key: %v", err)
+	}
+	if next == nil {
+		log.Fatal("Set ARMOR_DB_NEW_KEY, ARMOR_DB_NEW_KEY_FILE or ARMOR_DB_NEW_PASSPHRASE")
+	}
+
+	if err := db.Rekey(absDBPath, current, next); err != nil {
+		log.Fatalf("Failed to rekey database: %v", err)
+	}
+	log.Printf("Re-encrypted %s with the new key", absDBPath)
+}
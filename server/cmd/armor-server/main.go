@@ -9,13 +9,29 @@ import (
 
 	"github.com/HyphaGroup/armor/server/internal/api"
 	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/mcp"
 	"github.com/HyphaGroup/armor/server/internal/validator"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rekey":
+			runRekey(os.Args[2:])
+			return
+		case "genkey":
+			runGenKey()
+			return
+		case "mcp":
+			runMCP(os.Args[2:])
+			return
+		}
+	}
+
 	port := flag.String("port", "8080", "Server port")
 	dbPath := flag.String("db", "./armor.db", "Database path")
 	schemasDir := flag.String("schemas", "../schemas", "Path to JSON schemas directory")
+	mcpPort := flag.String("mcp-port", "", "MCP server port for agents over HTTP (off when empty)")
 	flag.Parse()
 
 	if envPort := os.Getenv("ARMOR_PORT"); envPort != "" {
@@ -27,35 +43,66 @@ func main() {
 	if envSchemas := os.Getenv("ARMOR_SCHEMAS_DIR"); envSchemas != "" {
 		*schemasDir = envSchemas
 	}
+	if envMCPPort := os.Getenv("ARMOR_MCP_PORT"); envMCPPort != "" {
+		*mcpPort = envMCPPort
+	}
+
+	database, val := openStore(*dbPath, *schemasDir)
+	defer database.Close()
+
+	server := api.NewServer(database, val)
+
+	if *mcpPort != "" {
+		agents := mcp.New(database, val, server)
+		go func() {
+			mcpAddr := ":" + *mcpPort
+			log.Printf("Starting MCP server on http://localhost%s (SSE at /sse, streamable HTTP at /mcp)", mcpAddr)
+			if err := http.ListenAndServe(mcpAddr, agents.Handler()); err != nil {
+				log.Fatalf("MCP server failed: %v", err)
+			}
+		}()
+	}
+
+	addr := ":" + *port
+	log.Printf("Starting server on http://localhost%s", addr)
+	if err := http.ListenAndServe(addr, server); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
 
-	absDBPath, err := filepath.Abs(*dbPath)
+// openStore opens the database, using the key from the environment, and
+// loads the section schemas.
+func openStore(dbPath, schemasDir string) (*db.DB, *validator.Validator) {
+	absDBPath, err := filepath.Abs(dbPath)
 	if err != nil {
 		log.Fatalf("Failed to resolve database path: %v", err)
 	}
 
-	absSchemasDir, err := filepath.Abs(*schemasDir)
+	absSchemasDir, err := filepath.Abs(schemasDir)
 	if err != nil {
 		log.Fatalf("Failed to resolve schemas directory: %v", err)
 	}
 
+	key, err := keyFromEnv("ARMOR_DB")
+	if err != nil {
+		log.Fatalf("Invalid database key: %v", err)
+	}
+	if key == nil {
+		log.Println("Warning: ARMOR_DB_KEY not set, database is not encrypted")
+	}
+
 	log.Printf("Opening database at %s", absDBPath)
-	database, err := db.Open(absDBPath)
+	database, err := db.Open(absDBPath, key)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
-	defer database.Close()
 
 	log.Printf("Loading schemas from %s", absSchemasDir)
 	val, err := validator.New(absSchemasDir)
 	if err != nil {
+		database.Close()
 		log.Fatalf("Failed to load schemas: %v", err)
 	}
 
-	server := api.NewServer(database, val)
-
-	addr := ":" + *port
-	log.Printf("Starting server on http://localhost%s", addr)
-	if err := http.ListenAndServe(addr, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
+	return database, val
 }
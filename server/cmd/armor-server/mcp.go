@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/HyphaGroup/armor/server/internal/api"
+	"github.com/HyphaGroup/armor/server/internal/mcp"
+)
+
+// runMCP serves one agent over stdio, authenticated with the API key in
+// ARMOR_API_KEY. Logs go to stderr so they stay out of the protocol stream.
+func runMCP(args []string) {
+	flags := flag.NewFlagSet("mcp", flag.ExitOnError)
+	dbPath := flags.String("db", "./armor.db", "Database path")
+	schemasDir := flags.String("schemas", "../schemas", "Path to JSON schemas directory")
+	flags.Parse(args)
+
+	if envDB := os.Getenv("ARMOR_DB_PATH"); envDB != "" {
+		*dbPath = envDB
+	}
+	if envSchemas := os.Getenv("ARMOR_SCHEMAS_DIR"); envSchemas != "" {
+		*schemasDir = envSchemas
+	}
+
+	key := os.Getenv("ARMOR_API_KEY")
+	if key == "" {
+		log.Fatal("Set ARMOR_API_KEY to an organization API key")
+	}
+
+	database, val := openStore(*dbPath, *schemasDir)
+	defer database.Close()
+
+	agents := mcp.New(database, val, api.NewServer(database, val))
+	if err := agents.ServeStdio(key); err != nil {
+		log.Fatalf("MCP server failed: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Package analysis inspects stored profile sections for gaps in the
+// MISSION → ASSETS → ADVERSARIES → THREATS → RISKS → MITIGATIONS chain.
+package analysis
+
+import "encoding/json"
+
+// item is one element of a section's main array.
+type item map[string]interface{}
+
+func (i item) str(field string) string {
+	value, _ := i[field].(string)
+	return value
+}
+
+func (i item) strings(field string) []string {
+	values, _ := i[field].([]interface{})
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (i item) object(field string) item {
+	value, _ := i[field].(map[string]interface{})
+	return value
+}
+
+// documents holds the decoded sections of a profile. Sections that are
+// empty or not valid JSON are treated as empty objects.
+type documents map[string]item
+
+func decode(sections map[string]*string) documents {
+	docs := make(documents)
+	for name, data := range sections {
+		doc := item{}
+		if data != nil {
+			json.Unmarshal([]byte(*data), &doc)
+		}
+		docs[name] = doc
+	}
+	return docs
+}
+
+// items returns the elements of a section's main array, such as the
+// "assets" list in the assets section.
+func (d documents) items(section, list string) []item {
+	values, _ := d[section][list].([]interface{})
+	var result []item
+	for _, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
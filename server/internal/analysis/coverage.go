@@ -0,0 +1,105 @@
+package analysis
+
+// Item identifies a profile entry in an analysis result.
+type Item struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DanglingMitigation is a mitigation that addresses risks that do not exist.
+type DanglingMitigation struct {
+	Item
+	MissingRiskIDs []string `json:"missing_risk_ids"`
+}
+
+type Coverage struct {
+	AssetsWithoutThreats        []Item               `json:"assets_without_threats"`
+	ThreatsWithoutRisks         []Item               `json:"threats_without_risks"`
+	RisksWithoutMitigations     []Item               `json:"risks_without_mitigations"`
+	AdversariesWithoutThreats   []Item               `json:"adversaries_without_threats"`
+	MitigationsWithMissingRisks []DanglingMitigation `json:"mitigations_with_missing_risks"`
+}
+
+// AnalyzeCoverage cross-references the sections of a profile. An asset is
+// covered when a threat targets it or a risk pairs it with a threat; a risk
+// is covered when a mitigation lists it or it names an existing mitigation.
+func AnalyzeCoverage(sections map[string]*string) Coverage {
+	docs := decode(sections)
+
+	coverage := Coverage{
+		AssetsWithoutThreats:        []Item{},
+		ThreatsWithoutRisks:         []Item{},
+		RisksWithoutMitigations:     []Item{},
+		AdversariesWithoutThreats:   []Item{},
+		MitigationsWithMissingRisks: []DanglingMitigation{},
+	}
+
+	threatenedAssets := make(map[string]bool)
+	threatenedBy := make(map[string]bool)
+	for _, threat := range docs.items("threats", "threats") {
+		for _, id := range threat.strings("targeted_assets") {
+			threatenedAssets[id] = true
+		}
+		for _, id := range threat.strings("relevant_adversaries") {
+			threatenedBy[id] = true
+		}
+	}
+
+	riskedThreats := make(map[string]bool)
+	riskIDs := make(map[string]bool)
+	for _, risk := range docs.items("risks", "risks") {
+		riskIDs[risk.str("risk_id")] = true
+		if threatID := risk.str("threat_id"); threatID != "" {
+			riskedThreats[threatID] = true
+			threatenedAssets[risk.str("asset_id")] = true
+		}
+	}
+
+	mitigationIDs := make(map[string]bool)
+	mitigatedRisks := make(map[string]bool)
+	for _, mitigation := range docs.items("mitigations", "mitigations") {
+		mitigationIDs[mitigation.str("mitigation_id")] = true
+
+		var missing []string
+		for _, id := range mitigation.strings("risk_ids") {
+			mitigatedRisks[id] = true
+			if !riskIDs[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			coverage.MitigationsWithMissingRisks = append(coverage.MitigationsWithMissingRisks, DanglingMitigation{
+				Item:           Item{ID: mitigation.str("mitigation_id"), Name: mitigation.str("title")},
+				MissingRiskIDs: missing,
+			})
+		}
+	}
+
+	for _, asset := range docs.items("assets", "assets") {
+		if !threatenedAssets[asset.str("asset_id")] {
+			coverage.AssetsWithoutThreats = append(coverage.AssetsWithoutThreats, Item{ID: asset.str("asset_id"), Name: asset.str("name")})
+		}
+	}
+
+	for _, threat := range docs.items("threats", "threats") {
+		if !riskedThreats[threat.str("threat_id")] {
+			coverage.ThreatsWithoutRisks = append(coverage.ThreatsWithoutRisks, Item{ID: threat.str("threat_id"), Name: threat.str("name")})
+		}
+	}
+
+	for _, risk := range docs.items("risks", "risks") {
+		id := risk.str("risk_id")
+		mitigationID := risk.str("mitigation_id")
+		if !mitigatedRisks[id] && (mitigationID == "" || !mitigationIDs[mitigationID]) {
+			coverage.RisksWithoutMitigations = append(coverage.RisksWithoutMitigations, Item{ID: id, Name: risk.str("scenario")})
+		}
+	}
+
+	for _, adversary := range docs.items("adversaries", "adversaries") {
+		if !threatenedBy[adversary.str("adversary_id")] {
+			coverage.AdversariesWithoutThreats = append(coverage.AdversariesWithoutThreats, Item{ID: adversary.str("adversary_id"), Name: adversary.str("name")})
+		}
+	}
+
+	return coverage
+}
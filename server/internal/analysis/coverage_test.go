@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCoverage(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents map[string]string
+		want      Coverage
+	}{
+		{
+			name: "empty profile",
+			want: Coverage{},
+		},
+		{
+			name: "asset without a threat",
+			documents: map[string]string{
+				"assets":      `{"assets": [{"asset_id": "a1", "name": "Donor list"}, {"asset_id": "a2", "name": "Website"}]}`,
+				"threats":     `{"threats": [{"threat_id": "t1", "name": "Phishing", "targeted_assets": ["a1"]}]}`,
+				"risks":       `{"risks": [{"risk_id": "r1", "asset_id": "a1", "threat_id": "t1", "mitigation_id": "m1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1"}]}`,
+			},
+			want: Coverage{AssetsWithoutThreats: []Item{{ID: "a2", Name: "Website"}}},
+		},
+		{
+			name: "risk pairs an asset with a threat",
+			documents: map[string]string{
+				"assets":      `{"assets": [{"asset_id": "a1", "name": "Donor list"}]}`,
+				"threats":     `{"threats": [{"threat_id": "t1", "name": "Phishing"}]}`,
+				"risks":       `{"risks": [{"risk_id": "r1", "asset_id": "a1", "threat_id": "t1", "mitigation_id": "m1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1"}]}`,
+			},
+			want: Coverage{},
+		},
+		{
+			name: "risk without a threat does not cover its asset",
+			documents: map[string]string{
+				"assets":      `{"assets": [{"asset_id": "a1", "name": "Donor list"}]}`,
+				"risks":       `{"risks": [{"risk_id": "r1", "asset_id": "a1", "mitigation_id": "m1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1"}]}`,
+			},
+			want: Coverage{AssetsWithoutThreats: []Item{{ID: "a1", Name: "Donor list"}}},
+		},
+		{
+			name: "threat without a risk",
+			documents: map[string]string{
+				"threats":     `{"threats": [{"threat_id": "t1", "name": "Phishing"}, {"threat_id": "t2", "name": "Raid"}]}`,
+				"risks":       `{"risks": [{"risk_id": "r1", "threat_id": "t1", "mitigation_id": "m1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1"}]}`,
+			},
+			want: Coverage{ThreatsWithoutRisks: []Item{{ID: "t2", Name: "Raid"}}},
+		},
+		{
+			name: "risks covered by a mitigation listing them or naming a mitigation",
+			documents: map[string]string{
+				"risks": `{"risks": [
+					{"risk_id": "r1", "scenario": "Listed"},
+					{"risk_id": "r2", "scenario": "Named", "mitigation_id": "m1"},
+					{"risk_id": "r3", "scenario": "Names a missing mitigation", "mitigation_id": "m9"},
+					{"risk_id": "r4", "scenario": "Unmitigated"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1", "risk_ids": ["r1"]}]}`,
+			},
+			want: Coverage{RisksWithoutMitigations: []Item{
+				{ID: "r3", Name: "Names a missing mitigation"},
+				{ID: "r4", Name: "Unmitigated"},
+			}},
+		},
+		{
+			name: "adversary without a threat",
+			documents: map[string]string{
+				"threats":     `{"threats": [{"threat_id": "t1", "relevant_adversaries": ["adv1"]}]}`,
+				"risks":       `{"risks": [{"risk_id": "r1", "threat_id": "t1", "mitigation_id": "m1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1"}]}`,
+				"adversaries": `{"adversaries": [{"adversary_id": "adv1", "name": "Troll farm"}, {"adversary_id": "adv2", "name": "Police"}]}`,
+			},
+			want: Coverage{AdversariesWithoutThreats: []Item{{ID: "adv2", Name: "Police"}}},
+		},
+		{
+			name: "mitigation points at a missing risk",
+			documents: map[string]string{
+				"risks":       `{"risks": [{"risk_id": "r1"}]}`,
+				"mitigations": `{"mitigations": [{"mitigation_id": "m1", "title": "MFA", "risk_ids": ["r1", "r-gone", "r-other"]}]}`,
+			},
+			want: Coverage{MitigationsWithMissingRisks: []DanglingMitigation{
+				{Item: Item{ID: "m1", Name: "MFA"}, MissingRiskIDs: []string{"r-gone", "r-other"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents := make(map[string]*string)
+			for name, data := range tt.documents {
+				data := data
+				documents[name] = &data
+			}
+
+			want := tt.want
+			for _, list := range []*[]Item{&want.AssetsWithoutThreats, &want.ThreatsWithoutRisks, &want.RisksWithoutMitigations, &want.AdversariesWithoutThreats} {
+				if *list == nil {
+					*list = []Item{}
+				}
+			}
+			if want.MitigationsWithMissingRisks == nil {
+				want.MitigationsWithMissingRisks = []DanglingMitigation{}
+			}
+
+			if got := AnalyzeCoverage(documents); !reflect.DeepEqual(got, want) {
+				t.Errorf("coverage = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
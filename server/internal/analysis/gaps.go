@@ -0,0 +1,306 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+)
+
+// Gap types, as described in the platform spec.
+const (
+	GapMissing      = "missing"
+	GapInconsistent = "inconsistent"
+	GapOutdated     = "outdated"
+)
+
+// Priorities shared by gaps and suggested next steps.
+const (
+	PriorityHigh   = "high"
+	PriorityMedium = "medium"
+	PriorityLow    = "low"
+)
+
+// indicatorReviewInterval is how old the last security indicator review may
+// get before it is reported as outdated.
+const indicatorReviewInterval = 90 * 24 * time.Hour
+
+var priorityRank = map[string]int{PriorityHigh: 0, PriorityMedium: 1, PriorityLow: 2}
+
+var sectionRank = map[string]int{
+	"meta":        -1,
+	"mission":     0,
+	"assets":      1,
+	"adversaries": 2,
+	"threats":     3,
+	"risks":       4,
+	"mitigations": 5,
+}
+
+type Gap struct {
+	Type        string `json:"type"`
+	Priority    string `json:"priority"`
+	Section     string `json:"section"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Suggestion  string `json:"suggestion"`
+}
+
+type NextStep struct {
+	Priority   string `json:"priority"`
+	Section    string `json:"section"`
+	Suggestion string `json:"suggestion"`
+	Reason     string `json:"reason"`
+}
+
+// AnalyzeGaps looks past field fill to the quality of a profile: missing
+// rationales, unowned critical assets, overdue mitigations and reviews that
+// have lapsed. Besides the sections, documents may hold the profile
+// metadata under "meta", which carries the review schedule. Gaps are ordered
+// by priority, then by methodology order.
+func AnalyzeGaps(documents map[string]*string, now time.Time) []Gap {
+	docs := decode(documents)
+	gaps := []Gap{}
+
+	for i, asset := range docs.items("assets", "assets") {
+		if asset.str("value") == "critical" && asset.str("owner") == "" {
+			gaps = append(gaps, Gap{
+				Type:        GapMissing,
+				Priority:    PriorityHigh,
+				Section:     "assets",
+				Path:        itemPath("assets", i, "owner"),
+				Description: fmt.Sprintf("Critical asset %q has no owner", label(asset, "asset_id", "name")),
+				Suggestion:  "Name the person or role responsible for protecting this asset",
+			})
+		}
+	}
+
+	for i, adversary := range docs.items("adversaries", "adversaries") {
+		if adversary.str("relevance_rationale") == "" {
+			gaps = append(gaps, Gap{
+				Type:        GapMissing,
+				Priority:    PriorityLow,
+				Section:     "adversaries",
+				Path:        itemPath("adversaries", i, "relevance_rationale"),
+				Description: fmt.Sprintf("Adversary %q has no relevance rationale", label(adversary, "adversary_id", "name")),
+				Suggestion:  "Explain why this adversary is or isn't relevant to the organization",
+			})
+		}
+	}
+
+	for i, threat := range docs.items("threats", "threats") {
+		if threat.str("likelihood_rationale") == "" {
+			gaps = append(gaps, Gap{
+				Type:        GapMissing,
+				Priority:    PriorityMedium,
+				Section:     "threats",
+				Path:        itemPath("threats", i, "likelihood_rationale"),
+				Description: fmt.Sprintf("Threat %q has no likelihood rationale", label(threat, "threat_id", "name")),
+				Suggestion:  "Record the evidence behind the likelihood rating, such as peer incidents or adversary activity",
+			})
+		}
+	}
+
+	for i, risk := range docs.items("risks", "risks") {
+		priority := PriorityLow
+		if level := risk.str("risk_level"); level == "critical" || level == "high" {
+			priority = PriorityMedium
+		}
+		for _, field := range []string{"likelihood_rationale", "vulnerability_rationale"} {
+			if risk.str(field) == "" {
+				gaps = append(gaps, Gap{
+					Type:        GapMissing,
+					Priority:    priority,
+					Section:     "risks",
+					Path:        itemPath("risks", i, field),
+					Description: fmt.Sprintf("Risk %q has no %s", risk.str("risk_id"), humanize(field)),
+					Suggestion:  "Explain the score so it can be revisited when circumstances change",
+				})
+			}
+		}
+	}
+
+	for i, mitigation := range docs.items("mitigations", "mitigations") {
+		status := mitigation.str("status")
+		if status == "completed" || status == "cancelled" {
+			continue
+		}
+		due, ok := parseDate(mitigation.object("timeline").str("target_completion"))
+		if !ok || !due.Before(startOfDay(now)) {
+			continue
+		}
+		priority := PriorityMedium
+		if p := mitigation.str("priority"); p == "critical" || p == "high" {
+			priority = PriorityHigh
+		}
+		gaps = append(gaps, Gap{
+			Type:        GapOutdated,
+			Priority:    priority,
+			Section:     "mitigations",
+			Path:        itemPath("mitigations", i, "timeline/target_completion"),
+			Description: fmt.Sprintf("Mitigation %q was due %s but is not completed", label(mitigation, "mitigation_id", "title"), due.Format("2006-01-02")),
+			Suggestion:  "Update the status, record blockers or set a new target date",
+		})
+	}
+
+	indicators := docs["risks"].object("security_indicators")
+	if reviewed, ok := parseDate(indicators.str("last_indicator_review")); ok && now.Sub(reviewed) > indicatorReviewInterval {
+		gaps = append(gaps, Gap{
+			Type:        GapOutdated,
+			Priority:    PriorityMedium,
+			Section:     "risks",
+			Path:        "/security_indicators/last_indicator_review",
+			Description: fmt.Sprintf("Security indicators were last reviewed %s", reviewed.Format("2006-01-02")),
+			Suggestion:  "Review the early warning indicators for changes in the threat environment",
+		})
+	}
+
+	schedule := docs["meta"].object("review_schedule")
+	if next, ok := parseDate(schedule.str("next_review")); ok && next.Before(startOfDay(now)) {
+		gaps = append(gaps, Gap{
+			Type:        GapOutdated,
+			Priority:    PriorityHigh,
+			Section:     "meta",
+			Path:        "/review_schedule/next_review",
+			Description: fmt.Sprintf("The scheduled review on %s has passed", next.Format("2006-01-02")),
+			Suggestion:  "Hold the threat model review and schedule the next one",
+		})
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if priorityRank[gaps[i].Priority] != priorityRank[gaps[j].Priority] {
+			return priorityRank[gaps[i].Priority] < priorityRank[gaps[j].Priority]
+		}
+		return sectionRank[gaps[i].Section] < sectionRank[gaps[j].Section]
+	})
+
+	return gaps
+}
+
+// SuggestNextSteps turns the state of a profile into a short, prioritized
+// to-do list: the next empty section in the methodology chain first, then
+// coverage holes, then the most pressing gaps. documents are as for
+// AnalyzeGaps.
+func SuggestNextSteps(documents map[string]*string, now time.Time) []NextStep {
+	docs := decode(documents)
+	steps := []NextStep{}
+
+	lists := map[string]string{
+		"assets":      "assets",
+		"adversaries": "adversaries",
+		"threats":     "threats",
+		"risks":       "risks",
+		"mitigations": "mitigations",
+	}
+	for _, section := range catalog.CoreSections {
+		empty := len(docs[section]) == 0
+		if list, ok := lists[section]; ok {
+			empty = len(docs.items(section, list)) == 0
+		}
+		if empty {
+			steps = append(steps, NextStep{
+				Priority:   PriorityHigh,
+				Section:    section,
+				Suggestion: fmt.Sprintf("Start the %s section", section),
+				Reason:     "Each step of the methodology builds on the previous one, and this is the first one not yet started",
+			})
+			break
+		}
+	}
+
+	coverage := AnalyzeCoverage(documents)
+	if n := len(coverage.AssetsWithoutThreats); n > 0 {
+		steps = append(steps, NextStep{
+			Priority:   PriorityHigh,
+			Section:    "threats",
+			Suggestion: "Map threats to the assets that have none",
+			Reason:     fmt.Sprintf("%d asset(s) have no threats mapped", n),
+		})
+	}
+	if n := len(coverage.RisksWithoutMitigations); n > 0 {
+		steps = append(steps, NextStep{
+			Priority:   PriorityHigh,
+			Section:    "mitigations",
+			Suggestion: "Plan mitigations for unaddressed risks, starting with the highest scores",
+			Reason:     fmt.Sprintf("%d risk(s) have no mitigation planned", n),
+		})
+	}
+	if n := len(coverage.ThreatsWithoutRisks); n > 0 {
+		steps = append(steps, NextStep{
+			Priority:   PriorityMedium,
+			Section:    "risks",
+			Suggestion: "Write risk scenarios for threats that have none",
+			Reason:     fmt.Sprintf("%d threat(s) are not assessed in any risk", n),
+		})
+	}
+	if n := len(coverage.AdversariesWithoutThreats); n > 0 {
+		steps = append(steps, NextStep{
+			Priority:   PriorityMedium,
+			Section:    "threats",
+			Suggestion: "Link adversaries to the threats they could carry out",
+			Reason:     fmt.Sprintf("%d adversary profile(s) are not linked to any threat", n),
+		})
+	}
+
+	for _, gap := range AnalyzeGaps(documents, now) {
+		if gap.Priority != PriorityHigh {
+			continue
+		}
+		steps = append(steps, NextStep{
+			Priority:   gap.Priority,
+			Section:    gap.Section,
+			Suggestion: gap.Suggestion,
+			Reason:     gap.Description,
+		})
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return priorityRank[steps[i].Priority] < priorityRank[steps[j].Priority]
+	})
+
+	return steps
+}
+
+func itemPath(list string, index int, field string) string {
+	return "/" + list + "/" + strconv.Itoa(index) + "/" + field
+}
+
+// label prefers an item's name and falls back to its ID.
+func label(i item, idField, nameField string) string {
+	if name := i.str(nameField); name != "" {
+		return name
+	}
+	return i.str(idField)
+}
+
+func humanize(field string) string {
+	switch field {
+	case "likelihood_rationale":
+		return "likelihood rationale"
+	case "vulnerability_rationale":
+		return "vulnerability rationale"
+	default:
+		return field
+	}
+}
+
+// parseDate accepts both the date and date-time formats used by the schemas.
+func parseDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
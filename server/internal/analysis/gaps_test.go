@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyzeGapsReviewDates(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		documents map[string]string
+		want      []string
+	}{
+		{
+			name:      "review schedule passed",
+			documents: map[string]string{"meta": `{"review_schedule": {"next_review": "2026-06-14"}}`},
+			want:      []string{"meta /review_schedule/next_review"},
+		},
+		{
+			name:      "review due today",
+			documents: map[string]string{"meta": `{"review_schedule": {"next_review": "2026-06-15"}}`},
+		},
+		{
+			name: "mitigation progress review is not the review schedule",
+			documents: map[string]string{
+				"mitigations": `{"mitigations": [], "progress_tracking": {"next_review": "2020-01-01"}}`,
+			},
+		},
+		{
+			name:      "indicators reviewed too long ago",
+			documents: map[string]string{"risks": `{"risks": [], "security_indicators": {"last_indicator_review": "2026-01-01"}}`},
+			want:      []string{"risks /security_indicators/last_indicator_review"},
+		},
+		{
+			name:      "indicators reviewed recently",
+			documents: map[string]string{"risks": `{"risks": [], "security_indicators": {"last_indicator_review": "2026-05-01"}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents := make(map[string]*string)
+			for name, data := range tt.documents {
+				data := data
+				documents[name] = &data
+			}
+
+			var got []string
+			for _, gap := range AnalyzeGaps(documents, now) {
+				if gap.Type == GapOutdated {
+					got = append(got, gap.Section+" "+gap.Path)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("outdated gaps = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("outdated gaps = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeGapsOrder(t *testing.T) {
+	assets := `{"assets": [{"asset_id": "a1", "value": "critical"}]}`
+	adversaries := `{"adversaries": [{"adversary_id": "adv1"}]}`
+	meta := `{"review_schedule": {"next_review": "2020-01-01"}}`
+
+	gaps := AnalyzeGaps(map[string]*string{"assets": &assets, "adversaries": &adversaries, "meta": &meta}, time.Now())
+
+	want := []string{"meta " + PriorityHigh, "assets " + PriorityHigh, "adversaries " + PriorityLow}
+	if len(gaps) != len(want) {
+		t.Fatalf("got %d gaps %+v, want %v", len(gaps), gaps, want)
+	}
+	for i, gap := range gaps {
+		if got := gap.Section + " " + gap.Priority; got != want[i] {
+			t.Errorf("gap %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/analysis"
+)
+
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	if len(parts) != 1 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	// The review schedule lives in the profile metadata rather than in a
+	// section, so gap analysis sees it as one more document.
+	documents := profile.Sections()
+	documents["meta"] = profile.Meta
+
+	switch parts[0] {
+	case "coverage":
+		writeJSON(w, analysis.AnalyzeCoverage(profile.Sections()))
+	case "gaps":
+		now := time.Now()
+		writeJSON(w, map[string]interface{}{
+			"gaps":                 analysis.AnalyzeGaps(documents, now),
+			"suggested_next_steps": analysis.SuggestNextSteps(documents, now),
+		})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
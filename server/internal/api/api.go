@@ -2,12 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/HyphaGroup/armor/server/internal/catalog"
 	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/report"
+	"github.com/HyphaGroup/armor/server/internal/scoring"
 	"github.com/HyphaGroup/armor/server/internal/validator"
 )
 
@@ -16,6 +21,33 @@ type Server struct {
 	validator *validator.Validator
 	password  string
 	mux       *http.ServeMux
+
+	// requireIfMatch rejects section writes that do not carry an If-Match
+	// header, so clients cannot blindly overwrite each other.
+	requireIfMatch bool
+
+	// integrityMode decides whether broken cross-section references are
+	// ignored, reported as warnings or rejected on save.
+	integrityMode string
+
+	// riskBands map computed risk scores to risk levels. When
+	// rejectScoreMismatch is set, saves whose submitted scores disagree with
+	// the computed ones are rejected instead of corrected.
+	riskBands           scoring.Bands
+	rejectScoreMismatch bool
+
+	// completenessWeights sets how much required and recommended fields
+	// count towards completeness.
+	completenessWeights validator.Weights
+
+	// sessionTTL is how long a login stays valid.
+	sessionTTL time.Duration
+
+	// redaction masks sensitive section fields for callers below its role.
+	redaction redactionPolicy
+
+	// reports renders profiles as HTML and PDF reports.
+	reports *report.Renderer
 }
 
 func NewServer(database *db.DB, val *validator.Validator) *Server {
@@ -30,6 +62,19 @@ func NewServer(database *db.DB, val *validator.Validator) *Server {
 		validator: val,
 		password:  password,
 		mux:       http.NewServeMux(),
+
+		requireIfMatch: os.Getenv("ARMOR_STRICT_CONCURRENCY") == "true",
+		integrityMode:  integrityModeFromEnv(),
+
+		riskBands:           riskBandsFromEnv(),
+		rejectScoreMismatch: os.Getenv("ARMOR_RISK_SCORING") == "reject",
+
+		completenessWeights: completenessWeightsFromEnv(),
+
+		sessionTTL: sessionTTLFromEnv(),
+		redaction:  redactionPolicyFromEnv(),
+
+		reports: reportRendererFromEnv(),
 	}
 
 	s.setupRoutes()
@@ -39,40 +84,39 @@ func NewServer(database *db.DB, val *validator.Validator) *Server {
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/profiles", s.handleProfiles)
 	s.mux.HandleFunc("/api/profiles/", s.handleProfileRoutes)
+	s.mux.HandleFunc("/api/organizations", s.handleOrganizations)
+	s.mux.HandleFunc("/api/organizations/", s.handleOrganizationRoutes)
+	s.mux.HandleFunc("/api/users/", s.handleUserRoutes)
+	s.mux.HandleFunc("/api/templates/", s.handleTemplates)
+	s.mux.HandleFunc("/auth/", s.handleAuth)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Armor-Source, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Auth check
-	if !s.checkAuth(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Logging in is the one request that needs no credentials.
+	if r.URL.Path == "/auth/login" {
+		s.mux.ServeHTTP(w, r)
 		return
 	}
 
-	s.mux.ServeHTTP(w, r)
-}
-
-func (s *Server) checkAuth(r *http.Request) bool {
-	auth := r.Header.Get("Authorization")
-	if auth == "" {
-		return false
-	}
-
-	parts := strings.SplitN(auth, " ", 2)
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		return false
+	// Auth check
+	p := s.authenticate(r)
+	if p == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	return parts[1] == s.password
+	s.mux.ServeHTTP(w, withPrincipal(r, p))
 }
 
 func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
@@ -88,8 +132,17 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleProfileRoutes(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/profiles/")
-	parts := strings.Split(path, "/")
+	if path == "import" {
+		s.importProfile(w, r)
+		return
+	}
+	s.routeProfile(w, r, strings.Split(path, "/"))
+}
 
+// routeProfile dispatches a request below a profile. parts starts with the
+// profile ID and is shared by /api/profiles/:id and
+// /api/organizations/:slug/profile.
+func (s *Server) routeProfile(w http.ResponseWriter, r *http.Request, parts []string) {
 	if len(parts) == 0 || parts[0] == "" {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
@@ -97,6 +150,12 @@ func (s *Server) handleProfileRoutes(w http.ResponseWriter, r *http.Request) {
 
 	profileID := parts[0]
 
+	role, ok := s.authorizeProfile(w, r, profileID, requiredProfileRole(r, parts))
+	if !ok {
+		return
+	}
+	r = withProfileRole(r, role)
+
 	if len(parts) == 1 {
 		switch r.Method {
 		case "GET":
@@ -109,17 +168,112 @@ func (s *Server) handleProfileRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if parts[1] == "history" {
+		s.handleHistory(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "registers" {
+		s.handleRegisters(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "incidents" {
+		s.handleIncidents(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "reviews" {
+		s.handleReviews(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "proposals" {
+		s.handleProposals(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "analysis" {
+		s.handleAnalysis(w, r, profileID, parts[2:])
+		return
+	}
+
+	if parts[1] == "export" && len(parts) == 2 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.exportProfile(w, r, profileID)
+		return
+	}
+
+	if parts[1] == "report" && len(parts) == 2 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.getReport(w, r, profileID)
+		return
+	}
+
+	if parts[1] == "import" && len(parts) == 2 {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.importIntoProfile(w, r, profileID)
+		return
+	}
+
+	if parts[1] == "meta" && len(parts) == 2 {
+		s.handleMeta(w, r, profileID)
+		return
+	}
+
+	if parts[1] == "integrity" && len(parts) == 2 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.getIntegrity(w, r, profileID)
+		return
+	}
+
+	if parts[1] == "adversaries" && len(parts) == 4 && parts[2] == "from-template" {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.createAdversaryFromTemplate(w, r, profileID, parts[3])
+		return
+	}
+
 	section := parts[1]
-	if !db.IsValidSection(section) {
+	if !catalog.IsValidSection(section) {
 		http.Error(w, "Invalid section", http.StatusNotFound)
 		return
 	}
 
+	if len(parts) > 2 {
+		if len(parts) != 3 || parts[2] != "validate" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.validateSection(w, r, profileID, section)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		s.getSection(w, r, profileID, section)
 	case "PUT":
 		s.updateSection(w, r, profileID, section)
+	case "PATCH":
+		s.patchSection(w, r, profileID, section)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -134,23 +288,25 @@ func (s *Server) listProfiles(w http.ResponseWriter, r *http.Request) {
 
 	var summaries []map[string]interface{}
 	for _, p := range profiles {
-		sections := map[string]*string{
-			"mission":     p.Mission,
-			"assets":      p.Assets,
-			"adversaries": p.Adversaries,
-			"threats":     p.Threats,
-			"risks":       p.Risks,
-			"mitigations": p.Mitigations,
+		role, err := s.organizationRole(r, p.OrganizationID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if role == "" {
+			continue
 		}
-		completeness := validator.CalculateProfileCompleteness(sections)
+
+		completeness := s.validator.ProfileCompleteness(p.Sections(), s.completenessWeights)
 
 		summaries = append(summaries, map[string]interface{}{
-			"id":           p.ID,
-			"name":         p.Name,
-			"description":  p.Description,
-			"completeness": completeness.Overall,
-			"created_at":   p.CreatedAt,
-			"updated_at":   p.UpdatedAt,
+			"id":              p.ID,
+			"name":            p.Name,
+			"description":     p.Description,
+			"organization_id": p.OrganizationID,
+			"completeness":    completeness.Overall,
+			"created_at":      p.CreatedAt,
+			"updated_at":      p.UpdatedAt,
 		})
 	}
 
@@ -161,7 +317,15 @@ func (s *Server) listProfiles(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, summaries)
 }
 
+// createProfile creates a profile outside any organization. Only
+// administrators can reach those; users get a profile by creating an
+// organization.
 func (s *Server) createProfile(w http.ResponseWriter, r *http.Request) {
+	if !principalFrom(r).admin {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	var req struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
@@ -183,12 +347,11 @@ func (s *Server) createProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	writeJSON(w, profile)
+	writeJSONStatus(w, http.StatusCreated, profile)
 }
 
 func (s *Server) getProfile(w http.ResponseWriter, r *http.Request, id string) {
-	profile, err := s.db.GetProfile(id)
+	profile, err := s.viewProfile(r, id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -199,36 +362,40 @@ func (s *Server) getProfile(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
-	sections := map[string]*string{
-		"mission":     profile.Mission,
-		"assets":      profile.Assets,
-		"adversaries": profile.Adversaries,
-		"threats":     profile.Threats,
-		"risks":       profile.Risks,
-		"mitigations": profile.Mitigations,
-	}
-	completeness := validator.CalculateProfileCompleteness(sections)
+	sections := profile.Sections()
+	completeness := s.validator.ProfileCompleteness(sections, s.completenessWeights)
 
+	w.Header().Set("ETag", profileETag(profile.Version))
 	response := map[string]interface{}{
-		"id":           profile.ID,
-		"name":         profile.Name,
-		"description":  profile.Description,
-		"mission":      parseJSON(profile.Mission),
-		"assets":       parseJSON(profile.Assets),
-		"adversaries":  parseJSON(profile.Adversaries),
-		"threats":      parseJSON(profile.Threats),
-		"risks":        parseJSON(profile.Risks),
-		"mitigations":  parseJSON(profile.Mitigations),
-		"completeness": completeness,
-		"created_at":   profile.CreatedAt,
-		"updated_at":   profile.UpdatedAt,
+		"id":               profile.ID,
+		"name":             profile.Name,
+		"description":      profile.Description,
+		"organization_id":  profile.OrganizationID,
+		"version":          profile.Version,
+		"completeness":     completeness,
+		"section_versions": sectionVersions(profile),
+		"created_at":       profile.CreatedAt,
+		"updated_at":       profile.UpdatedAt,
+	}
+	for name, data := range sections {
+		response[name] = parseJSON(data)
 	}
 
 	writeJSON(w, response)
 }
 
 func (s *Server) deleteProfile(w http.ResponseWriter, r *http.Request, id string) {
-	err := s.db.DeleteProfile(id)
+	organizationID, _, err := s.db.GetProfileOrganization(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if organizationID != "" {
+		http.Error(w, "Profile belongs to an organization; delete the organization instead", http.StatusConflict)
+		return
+	}
+
+	err = s.db.DeleteProfile(id)
 	if err != nil {
 		http.Error(w, "Profile not found", http.StatusNotFound)
 		return
@@ -238,7 +405,7 @@ func (s *Server) deleteProfile(w http.ResponseWriter, r *http.Request, id string
 }
 
 func (s *Server) getSection(w http.ResponseWriter, r *http.Request, profileID, section string) {
-	profile, err := s.db.GetProfile(profileID)
+	profile, err := s.viewProfile(r, profileID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -249,24 +416,11 @@ func (s *Server) getSection(w http.ResponseWriter, r *http.Request, profileID, s
 		return
 	}
 
-	var data *string
-	switch section {
-	case "mission":
-		data = profile.Mission
-	case "assets":
-		data = profile.Assets
-	case "adversaries":
-		data = profile.Adversaries
-	case "threats":
-		data = profile.Threats
-	case "risks":
-		data = profile.Risks
-	case "mitigations":
-		data = profile.Mitigations
-	}
-
+	version := profile.SectionVersions[section]
+	w.Header().Set("ETag", sectionETag(section, version))
 	writeJSON(w, map[string]interface{}{
-		"data": parseJSON(data),
+		"data":    parseJSON(profile.Section(section)),
+		"version": version,
 	})
 }
 
@@ -282,44 +436,195 @@ func (s *Server) updateSection(w http.ResponseWriter, r *http.Request, profileID
 		return
 	}
 
+	expectedVersion, ok := s.checkIfMatch(w, r, profile, section)
+	if !ok {
+		return
+	}
+
 	var body json.RawMessage
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	dataStr := string(body)
+	s.saveSection(w, r, profile, section, string(body), expectedVersion)
+}
 
+// validateSection runs the same checks as a save without storing anything,
+// so forms can report errors and progress while the user is still typing.
+func (s *Server) validateSection(w http.ResponseWriter, r *http.Request, profileID, section string) {
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	dataStr := s.unredact(r, section, profile.Section(section), string(body))
+
+	validationErrors := []validator.ValidationError{}
 	if s.validator.HasSchema(section) {
-		errors, err := s.validator.Validate(section, dataStr)
+		errs, err := s.validator.Validate(section, dataStr)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		validationErrors = append(validationErrors, errs...)
+	}
+
+	response := map[string]interface{}{}
+
+	derived, err := s.deriveSection(profile, section, dataStr)
+	if err == nil {
+		dataStr = derived.data
+		if derived.scoreCorrections != nil {
+			response["score_corrections"] = derived.scoreCorrections
+		}
+	}
+
+	warnings, _ := s.checkIntegrity(profile, section, dataStr)
+	if warnings == nil {
+		warnings = []validator.BrokenReference{}
+	}
 
-		if len(errors) > 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			writeJSON(w, map[string]interface{}{
+	response["valid"] = len(validationErrors) == 0
+	response["errors"] = validationErrors
+	response["warnings"] = warnings
+	response["completeness"] = s.validator.SectionCompleteness(section, &dataStr, s.completenessWeights)
+
+	writeJSON(w, response)
+}
+
+// saveSection validates a complete section value and stores it, writing the
+// response for both full replacements and patches.
+func (s *Server) saveSection(w http.ResponseWriter, r *http.Request, profile *db.Profile, section, dataStr string, expectedVersion int) {
+	if response, ok := s.storeSection(w, r, profile, section, dataStr, expectedVersion); ok {
+		writeJSON(w, response)
+	}
+}
+
+// storeSection checks and stores a section like saveSection, but leaves the
+// success response for the caller to extend and write. It sets the ETag and
+// reports false once it has written an error response.
+func (s *Server) storeSection(w http.ResponseWriter, r *http.Request, profile *db.Profile, section, dataStr string, expectedVersion int) (map[string]interface{}, bool) {
+	dataStr = s.unredact(r, section, profile.Section(section), dataStr)
+
+	if s.validator.HasSchema(section) {
+		validationErrors, err := s.validator.Validate(section, dataStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+
+		if len(validationErrors) > 0 {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
 				"error":  "Validation failed",
-				"errors": errors,
+				"errors": validationErrors,
 			})
-			return
+			return nil, false
 		}
 	}
 
-	if err := s.db.UpdateSection(profileID, section, dataStr); err != nil {
+	derived, err := s.deriveSections(profile, map[string]*string{section: &dataStr})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
-	writeJSON(w, map[string]interface{}{
-		"success": true,
-		"data":    parseJSON(&dataStr),
-	})
+	if s.rejectScoreMismatch && len(derived.scoreCorrections) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Risk scores do not match the computed values",
+			"score_corrections": derived.scoreCorrections,
+		})
+		return nil, false
+	}
+	dataStr = *derived.sections[section]
+
+	warnings, introduced := s.checkIntegrity(profile, section, dataStr)
+	if s.integrityMode == integrityModeError && len(introduced) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": introduced,
+		})
+		return nil, false
+	}
+
+	// Rescored dependents were derived from the stored values, so they are
+	// only written if nobody has changed them since.
+	expected := expectedVersions(profile, derived.sections)
+	if expectedVersion == db.AnyVersion {
+		delete(expected, section)
+	} else {
+		expected[section] = expectedVersion
+	}
+
+	writes, err := s.db.UpdateSections(profile.ID, derived.sections, expected, requestSource(r))
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		writePreconditionFailed(w, conflict.Section, conflict.Current)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	write := writes[section]
+
+	response := map[string]interface{}{
+		"success":         true,
+		"data":            parseJSON(s.maskSection(r, section, &dataStr)),
+		"version":         writes.Version(),
+		"section_version": write.SectionVersion,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	if len(derived.scoreCorrections) > 0 {
+		response["score_corrections"] = derived.scoreCorrections
+	}
+	if len(derived.cascaded) > 0 {
+		rescored := make(map[string]int, len(derived.cascaded))
+		for _, name := range derived.cascaded {
+			rescored[name] = writes[name].SectionVersion
+		}
+		response["rescored_sections"] = rescored
+	}
+	if tasks := s.reviewOnTrigger(profile, section, dataStr); len(tasks) > 0 {
+		response["review_tasks"] = tasks
+	}
+
+	w.Header().Set("ETag", sectionETag(section, write.SectionVersion))
+	return response, true
+}
+
+// requestSource reports where a change came from for the profile history.
+// Clients identify themselves with the X-Armor-Source header; anything else
+// is recorded as a plain API call.
+func requestSource(r *http.Request) string {
+	source := r.Header.Get("X-Armor-Source")
+	if db.ValidSources[source] {
+		return source
+	}
+	return db.SourceAPI
 }
 
 func writeJSON(w http.ResponseWriter, data interface{}) {
+	writeJSONStatus(w, http.StatusOK, data)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
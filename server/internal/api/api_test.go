@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+const testPassword = "test-password"
+
+// newTestServer serves a fresh database with the repository's schemas.
+// Requests made with do authenticate as the administrator.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	t.Setenv("ARMOR_PASSWORD", testPassword)
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "armor.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	schemas, err := filepath.Abs(filepath.Join("..", "..", "..", "schemas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := validator.New(schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewServer(database, val)
+}
+
+// do makes a request as the administrator. Extra headers are given as
+// name, value pairs.
+func do(t *testing.T, s *Server, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testPassword)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// mustDo makes a request and fails the test unless it gets the wanted status.
+func mustDo(t *testing.T, s *Server, status int, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := do(t, s, method, path, body, headers...)
+	if w.Code != status {
+		t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, strings.TrimSpace(w.Body.String()), status)
+	}
+	return w
+}
+
+// decode reads a JSON response body.
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+// createProfile makes an empty profile and returns its ID.
+func createProfile(t *testing.T, s *Server) string {
+	t.Helper()
+
+	w := mustDo(t, s, http.StatusCreated, "POST", "/api/profiles", `{"name": "Test"}`)
+	return decode(t, w)["id"].(string)
+}
+
+// createOrganization makes an organization and returns it. Its profile ID
+// is org.ProfileID.
+func createOrganization(t *testing.T, s *Server, slug string) *db.Organization {
+	t.Helper()
+
+	org, err := s.db.CreateOrganization("Test "+slug, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return org
+}
+
+// newAPIKey issues an API key for an organization and returns its secret,
+// to be sent as "Authorization", "Bearer "+key.
+func newAPIKey(t *testing.T, s *Server, organizationID, permission string) string {
+	t.Helper()
+
+	secret := apiKeyPrefix + permission + "-" + organizationID
+	if _, err := s.db.CreateAPIKey(organizationID, permission, secret[:apiKeyDisplayLength], hashToken(secret), permission, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// Assets, threats and risks for a profile with one scored risk:
+// medium (2) × high (3) × vulnerability 1 = 6, moderate.
+const (
+	testAssets = `{"assets": [
+		{"asset_id": "asset-a1", "name": "Donor list", "category": "donor_supporter_data", "value": "medium"}
+	]}`
+	testThreats = `{"threats": [
+		{"threat_id": "threat-t1", "name": "Phishing", "category": "account_phishing", "likelihood": "high"}
+	]}`
+	testRisks = `{"risks": [
+		{"risk_id": "risk-r1", "scenario": "Donor list stolen through phishing", "asset_id": "asset-a1",
+		 "threat_id": "threat-t1", "asset_value_score": 2, "likelihood_score": 3, "vulnerability_score": 1}
+	]}`
+)
+
+// createScoredProfile makes a profile holding testAssets, testThreats and
+// testRisks.
+func createScoredProfile(t *testing.T, s *Server) string {
+	t.Helper()
+
+	id := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", testThreats)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/risks", testRisks)
+	return id
+}
+
+// getRisk returns a stored risk and the risk summary.
+func getRisk(t *testing.T, s *Server, profileID, riskID string) (risk, riskSummary map[string]interface{}) {
+	t.Helper()
+
+	data := decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+profileID+"/risks", ""))["data"].(map[string]interface{})
+	for _, item := range data["risks"].([]interface{}) {
+		if item := item.(map[string]interface{}); item["risk_id"] == riskID {
+			risk = item
+		}
+	}
+	if risk == nil {
+		t.Fatalf("risk %s not found", riskID)
+	}
+	riskSummary, _ = data["risk_summary"].(map[string]interface{})
+	return risk, riskSummary
+}
+
+func TestSavingAssetsOrThreatsRescoresRisks(t *testing.T) {
+	tests := []struct {
+		name    string
+		section string
+		data    string
+		score   float64
+		level   string
+	}{
+		{
+			name:    "asset value raised",
+			section: "assets",
+			data:    strings.Replace(testAssets, `"medium"`, `"critical"`, 1),
+			score:   9,
+			level:   "moderate",
+		},
+		{
+			name:    "threat likelihood lowered",
+			section: "threats",
+			data:    strings.Replace(testThreats, `"high"`, `"low"`, 1),
+			score:   2,
+			level:   "low",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			id := createScoredProfile(t, s)
+
+			risk, _ := getRisk(t, s, id, "risk-r1")
+			if risk["risk_score"] != 6.0 {
+				t.Fatalf("initial risk_score = %v, want 6", risk["risk_score"])
+			}
+
+			response := decode(t, mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/"+tt.section, tt.data))
+			rescored, _ := response["rescored_sections"].(map[string]interface{})
+			if rescored["risks"] != 2.0 {
+				t.Errorf("rescored_sections = %v, want risks at version 2", response["rescored_sections"])
+			}
+
+			risk, _ = getRisk(t, s, id, "risk-r1")
+			if risk["risk_score"] != tt.score || risk["risk_level"] != tt.level {
+				t.Errorf("risk = %v/%v, want %v/%v", risk["risk_score"], risk["risk_level"], tt.score, tt.level)
+			}
+		})
+	}
+}
+
+func TestUnchangedDependentsAreNotRewritten(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+
+	renamed := strings.Replace(testAssets, "Donor list", "Donor database", 1)
+	response := decode(t, mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", renamed))
+	if _, ok := response["rescored_sections"]; ok {
+		t.Errorf("rescored_sections = %v, want none", response["rescored_sections"])
+	}
+
+	w := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/risks", "")
+	if etag := w.Header().Get("ETag"); etag != `"risks-1"` {
+		t.Errorf("risks ETag = %s, want \"risks-1\"", etag)
+	}
+}
+
+func TestRescoringRecountsRiskSummary(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+
+	_, riskSummary := getRisk(t, s, id, "risk-r1")
+	if riskSummary["moderate_risks"] != 1.0 || riskSummary["critical_risks"] != 0.0 {
+		t.Fatalf("initial risk_summary = %v", riskSummary)
+	}
+
+	// medium (2) × high (3) × vulnerability 2 = 12, high, then critical (3)
+	// × high (3) × vulnerability 2 = 18, critical, once the asset is raised.
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/risks", strings.Replace(testRisks, `"vulnerability_score": 1`, `"vulnerability_score": 2`, 1))
+	_, riskSummary = getRisk(t, s, id, "risk-r1")
+	if riskSummary["high_risks"] != 1.0 {
+		t.Fatalf("risk_summary = %v, want one high risk", riskSummary)
+	}
+
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", strings.Replace(testAssets, `"medium"`, `"critical"`, 1))
+
+	_, riskSummary = getRisk(t, s, id, "risk-r1")
+	if riskSummary["critical_risks"] != 1.0 || riskSummary["high_risks"] != 0.0 {
+		t.Errorf("risk_summary = %v, want one critical risk", riskSummary)
+	}
+	if ids, _ := riskSummary["top_risk_ids"].([]interface{}); len(ids) != 1 || ids[0] != "risk-r1" {
+		t.Errorf("top_risk_ids = %v, want [risk-r1]", riskSummary["top_risk_ids"])
+	}
+}
+
+func TestValidateSectionDoesNotSave(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+	path := "/api/profiles/" + id + "/risks"
+
+	before := mustDo(t, s, http.StatusOK, "GET", path, "")
+
+	tests := []struct {
+		name        string
+		body        string
+		wantValid   bool
+		wantErrors  bool
+		wantWarning bool
+	}{
+		{name: "valid", body: testRisks, wantValid: true},
+		{name: "schema errors", body: `{"risks": [{"risk_id": "risk-r1", "likelihood_score": "often"}]}`, wantErrors: true},
+		{
+			name:        "broken reference",
+			body:        strings.Replace(testRisks, `"asset_id": "asset-a1"`, `"asset_id": "asset-gone"`, 1),
+			wantValid:   true,
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := decode(t, mustDo(t, s, http.StatusOK, "POST", path+"/validate", tt.body))
+
+			if response["valid"] != tt.wantValid {
+				t.Errorf("valid = %v, want %v", response["valid"], tt.wantValid)
+			}
+			if errors, _ := response["errors"].([]interface{}); (len(errors) > 0) != tt.wantErrors {
+				t.Errorf("errors = %v, want errors %v", response["errors"], tt.wantErrors)
+			}
+			if warnings, _ := response["warnings"].([]interface{}); (len(warnings) > 0) != tt.wantWarning {
+				t.Errorf("warnings = %v, want warnings %v", response["warnings"], tt.wantWarning)
+			}
+
+			after := mustDo(t, s, http.StatusOK, "GET", path, "")
+			if after.Header().Get("ETag") != before.Header().Get("ETag") {
+				t.Errorf("ETag = %s after validating, want %s", after.Header().Get("ETag"), before.Header().Get("ETag"))
+			}
+			if after.Body.String() != before.Body.String() {
+				t.Errorf("risks changed after validating: %s", after.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+// apiKeyPrefix marks a bearer token as an API key rather than a session
+// token, and makes leaked keys easy to search for.
+const apiKeyPrefix = "armor_"
+
+// apiKeyDisplayLength is how much of a key is kept in the clear so people
+// can tell their keys apart.
+const apiKeyDisplayLength = len(apiKeyPrefix) + 6
+
+// permissionRole maps an API key permission to the membership role it acts
+// with. Keys never manage members, settings or other keys.
+func permissionRole(permission string) string {
+	if permission == db.PermissionReadWrite {
+		return db.RoleEditor
+	}
+	return db.RoleViewer
+}
+
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request, org *db.Organization, role string, parts []string) {
+	if !hasRole(role, db.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if len(parts) == 0 || parts[0] == "" {
+		switch r.Method {
+		case "GET":
+			keys, err := s.db.ListAPIKeys(org.ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, keys)
+		case "POST":
+			s.createAPIKey(w, r, org)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(parts) != 1 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := s.db.DeleteAPIKey(org.ID, parts[0])
+	if err == sql.ErrNoRows {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createAPIKey issues a key. The key itself is only returned in this
+// response; afterwards only its prefix is shown.
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request, org *db.Organization) {
+	var req struct {
+		Name       string     `json:"name"`
+		Permission string     `json:"permission"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if !db.ValidPermissions[req.Permission] {
+		http.Error(w, "Invalid permission: must be read, read-propose or read-write", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	secret := apiKeyPrefix + token
+
+	key, err := s.db.CreateAPIKey(org.ID, req.Name, secret[:apiKeyDisplayLength], hashToken(secret),
+		req.Permission, principalFrom(r).userID, req.ExpiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, map[string]interface{}{
+		"api_key": key,
+		"key":     secret,
+	})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func TestAPIKeyAccess(t *testing.T) {
+	s := newTestServer(t)
+	org := createOrganization(t, s, "acme")
+	other := createOrganization(t, s, "other")
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+other.ProfileID+"/assets", testAssets)
+
+	expired := apiKeyPrefix + "expired"
+	past := time.Now().Add(-time.Hour)
+	if _, err := s.db.CreateAPIKey(org.ID, "expired", expired[:apiKeyDisplayLength], hashToken(expired), db.PermissionReadWrite, "", &past); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := newAPIKey(t, s, org.ID, db.PermissionReadPropose)
+	keys, err := s.db.ListAPIKeys(org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if key.Permission == db.PermissionReadPropose {
+			mustDo(t, s, http.StatusNoContent, "DELETE", "/api/organizations/acme/api-keys/"+key.ID, "")
+		}
+	}
+
+	read := newAPIKey(t, s, org.ID, db.PermissionRead)
+	readWrite := newAPIKey(t, s, org.ID, db.PermissionReadWrite)
+	patch := `[{"op": "add", "path": "/assets", "value": []}]`
+
+	tests := []struct {
+		name        string
+		key         string
+		method      string
+		profileID   string
+		body        string
+		contentType string
+		status      int
+	}{
+		{name: "read key reads", key: read, method: "GET", profileID: org.ProfileID, status: http.StatusOK},
+		{name: "read key PUT", key: read, method: "PUT", profileID: org.ProfileID, body: testAssets, status: http.StatusForbidden},
+		{name: "read key PATCH", key: read, method: "PATCH", profileID: org.ProfileID, body: patch, contentType: contentTypeJSONPatch, status: http.StatusForbidden},
+		{name: "read-write key PUT", key: readWrite, method: "PUT", profileID: org.ProfileID, body: testAssets, status: http.StatusOK},
+		{name: "expired key", key: expired, method: "GET", profileID: org.ProfileID, status: http.StatusUnauthorized},
+		{name: "revoked key", key: revoked, method: "GET", profileID: org.ProfileID, status: http.StatusUnauthorized},
+		{name: "other organization's profile", key: readWrite, method: "GET", profileID: other.ProfileID, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := []string{"Authorization", "Bearer " + tt.key}
+			if tt.contentType != "" {
+				headers = append(headers, "Content-Type", tt.contentType)
+			}
+			mustDo(t, s, tt.status, tt.method, "/api/profiles/"+tt.profileID+"/assets", tt.body, headers...)
+		})
+	}
+}
+
+func TestAPIKeyStoresOnlyHash(t *testing.T) {
+	s := newTestServer(t)
+	org := createOrganization(t, s, "acme")
+
+	created := decode(t, mustDo(t, s, http.StatusCreated, "POST", "/api/organizations/acme/api-keys",
+		`{"name": "CI", "permission": "read"}`))
+	secret := created["key"].(string)
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		t.Fatalf("key = %q, want the %s prefix", secret, apiKeyPrefix)
+	}
+
+	keys, err := s.db.ListAPIKeys(org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	if keys[0].KeyHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("stored hash = %q, want the SHA-256 of the key", keys[0].KeyHash)
+	}
+	if keys[0].Prefix != secret[:apiKeyDisplayLength] {
+		t.Errorf("prefix = %q, want the first %d characters", keys[0].Prefix, apiKeyDisplayLength)
+	}
+
+	listing := mustDo(t, s, http.StatusOK, "GET", "/api/organizations/acme/api-keys", "").Body.String()
+	if strings.Contains(listing, secret) || strings.Contains(listing, keys[0].KeyHash) {
+		t.Errorf("listing %s exposes the key or its hash", listing)
+	}
+}
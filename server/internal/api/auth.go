@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+// principal is the caller a request is made on behalf of. The shared
+// ARMOR_PASSWORD authenticates an administrator who can reach every
+// organization and the profiles that predate organizations; users are
+// limited to the organizations they are members of, and API keys to the
+// organization they were issued for.
+type principal struct {
+	userID    string
+	tokenHash string
+	apiKey    *db.APIKey
+	admin     bool
+}
+
+type principalKey struct{}
+
+func withPrincipal(r *http.Request, p *principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+}
+
+func principalFrom(r *http.Request) *principal {
+	p, _ := r.Context().Value(principalKey{}).(*principal)
+	if p == nil {
+		return &principal{}
+	}
+	return p
+}
+
+func (s *Server) authenticate(r *http.Request) *principal {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(s.password)) == 1 {
+		return &principal{admin: true}
+	}
+
+	tokenHash := hashToken(parts[1])
+
+	if strings.HasPrefix(parts[1], apiKeyPrefix) {
+		key, err := s.db.UseAPIKey(tokenHash)
+		if err != nil {
+			log.Printf("Failed to look up API key: %v", err)
+			return nil
+		}
+		if key == nil {
+			return nil
+		}
+		return &principal{apiKey: key}
+	}
+
+	session, err := s.db.GetSession(tokenHash)
+	if err != nil {
+		log.Printf("Failed to look up session: %v", err)
+		return nil
+	}
+	if session == nil {
+		return nil
+	}
+	return &principal{userID: session.UserID, tokenHash: tokenHash}
+}
+
+var roleRank = map[string]int{
+	db.RoleViewer: 1,
+	db.RoleEditor: 2,
+	db.RoleAdmin:  3,
+	db.RoleOwner:  4,
+}
+
+func hasRole(role, required string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[required]
+}
+
+// organizationRole returns the caller's role in an organization, or "" if
+// they are not a member. Administrators act as owners everywhere.
+func (s *Server) organizationRole(r *http.Request, organizationID string) (string, error) {
+	p := principalFrom(r)
+	if p.admin {
+		return db.RoleOwner, nil
+	}
+	if p.apiKey != nil {
+		if organizationID == "" || organizationID != p.apiKey.OrganizationID {
+			return "", nil
+		}
+		return permissionRole(p.apiKey.Permission), nil
+	}
+	if p.userID == "" || organizationID == "" {
+		return "", nil
+	}
+
+	membership, err := s.db.GetMembership(organizationID, p.userID)
+	if err != nil || membership == nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// requiredProfileRole maps a profile request to the least role that may
+// make it: reads and dry-run validation need viewer, deleting the profile
+// needs owner, and every other write needs editor. Submitting a proposal
+// also starts at viewer; handleProposals checks canPropose itself.
+func requiredProfileRole(r *http.Request, parts []string) string {
+	switch {
+	case r.Method == "GET" || r.Method == "HEAD":
+		return db.RoleViewer
+	case r.Method == "POST" && len(parts) == 3 && parts[2] == "validate":
+		return db.RoleViewer
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "proposals":
+		return db.RoleViewer
+	case r.Method == "DELETE" && len(parts) == 1:
+		return db.RoleOwner
+	default:
+		return db.RoleEditor
+	}
+}
+
+// authorizeProfile enforces the caller's role in the organization that owns
+// a profile and returns that role, writing the error response when access
+// is refused. Callers without any access get the same 404 as for a missing
+// profile.
+func (s *Server) authorizeProfile(w http.ResponseWriter, r *http.Request, profileID, required string) (string, bool) {
+	if principalFrom(r).admin {
+		return db.RoleOwner, true
+	}
+
+	organizationID, found, err := s.db.GetProfileOrganization(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+
+	role := ""
+	if found {
+		role, err = s.organizationRole(r, organizationID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return "", false
+		}
+	}
+
+	if role == "" {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return "", false
+	}
+	if !hasRole(role, required) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return role, true
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+// newMember adds a user with a role to an organization and returns a
+// session token, to be sent as "Authorization", "Bearer "+token.
+func newMember(t *testing.T, s *Server, organizationID, role string) string {
+	t.Helper()
+
+	user, err := s.db.CreateUser(role+"@"+organizationID+".test", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.AddMember(organizationID, user.ID, role); err != nil {
+		t.Fatal(err)
+	}
+
+	token := "session-" + user.ID
+	if _, err := s.db.CreateSession(hashToken(token), user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRequiredProfileRole(t *testing.T) {
+	tests := []struct {
+		method string
+		path   []string
+		want   string
+	}{
+		{method: "GET", path: []string{"p"}, want: db.RoleViewer},
+		{method: "HEAD", path: []string{"p", "assets"}, want: db.RoleViewer},
+		{method: "GET", path: []string{"p", "history"}, want: db.RoleViewer},
+		{method: "POST", path: []string{"p", "assets", "validate"}, want: db.RoleViewer},
+		{method: "POST", path: []string{"p", "proposals"}, want: db.RoleViewer},
+		{method: "POST", path: []string{"p", "proposals", "accept"}, want: db.RoleEditor},
+		{method: "PUT", path: []string{"p", "assets"}, want: db.RoleEditor},
+		{method: "PATCH", path: []string{"p", "assets"}, want: db.RoleEditor},
+		{method: "POST", path: []string{"p", "import"}, want: db.RoleEditor},
+		{method: "DELETE", path: []string{"p", "assets"}, want: db.RoleEditor},
+		{method: "DELETE", path: []string{"p"}, want: db.RoleOwner},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		if got := requiredProfileRole(r, tt.path); got != tt.want {
+			t.Errorf("%s %v requires %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeProfile(t *testing.T) {
+	s := newTestServer(t)
+	org := createOrganization(t, s, "acme")
+	other := createOrganization(t, s, "other")
+
+	callers := map[string]string{
+		"viewer":          newMember(t, s, org.ID, db.RoleViewer),
+		"editor":          newMember(t, s, org.ID, db.RoleEditor),
+		"owner":           newMember(t, s, org.ID, db.RoleOwner),
+		"other owner":     newMember(t, s, other.ID, db.RoleOwner),
+		"read key":        newAPIKey(t, s, org.ID, db.PermissionRead),
+		"read-write key":  newAPIKey(t, s, org.ID, db.PermissionReadWrite),
+		"other org's key": newAPIKey(t, s, other.ID, db.PermissionReadWrite),
+	}
+
+	tests := []struct {
+		caller string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{caller: "viewer", method: "GET", path: "/assets", status: http.StatusOK},
+		{caller: "viewer", method: "POST", path: "/assets/validate", body: testAssets, status: http.StatusOK},
+		{caller: "viewer", method: "PUT", path: "/assets", body: testAssets, status: http.StatusForbidden},
+		{caller: "editor", method: "PUT", path: "/assets", body: testAssets, status: http.StatusOK},
+		{caller: "editor", method: "DELETE", path: "", status: http.StatusForbidden},
+		{caller: "owner", method: "PUT", path: "/assets", body: testAssets, status: http.StatusOK},
+		{caller: "read key", method: "GET", path: "/assets", status: http.StatusOK},
+		{caller: "read key", method: "PUT", path: "/assets", body: testAssets, status: http.StatusForbidden},
+		{caller: "read-write key", method: "PUT", path: "/assets", body: testAssets, status: http.StatusOK},
+		{caller: "read-write key", method: "DELETE", path: "", status: http.StatusForbidden},
+		{caller: "other owner", method: "GET", path: "/assets", status: http.StatusNotFound},
+		{caller: "other org's key", method: "PUT", path: "/assets", body: testAssets, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.caller+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			mustDo(t, s, tt.status, tt.method, "/api/profiles/"+org.ProfileID+tt.path, tt.body,
+				"Authorization", "Bearer "+callers[tt.caller])
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func sectionETag(section string, version int) string {
+	return fmt.Sprintf(`"%s-%d"`, section, version)
+}
+
+func profileETag(version int) string {
+	return fmt.Sprintf(`"profile-%d"`, version)
+}
+
+// sectionVersions lists the version of every section, including the ones
+// that have never been written, so clients can build If-Match headers from
+// a full profile read.
+func sectionVersions(profile *db.Profile) map[string]int {
+	versions := make(map[string]int, len(catalog.ValidSections))
+	for section := range catalog.ValidSections {
+		versions[section] = profile.SectionVersions[section]
+	}
+	return versions
+}
+
+// checkIfMatch evaluates the If-Match header of a section write against the
+// version read with the profile. It returns the version the write must still
+// find when it commits, or AnyVersion for an unconditional write. Tags are
+// compared strongly, as RFC 9110 requires for If-Match, so a weak tag never
+// matches. When the precondition fails the response has already been
+// written.
+func (s *Server) checkIfMatch(w http.ResponseWriter, r *http.Request, profile *db.Profile, section string) (int, bool) {
+	current := profile.SectionVersions[section]
+
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if s.requireIfMatch {
+			http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		return db.AnyVersion, true
+	}
+
+	expected := sectionETag(section, current)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == expected {
+			return current, true
+		}
+	}
+
+	writePreconditionFailed(w, section, current)
+	return 0, false
+}
+
+// checkProfileIfMatch evaluates the If-Match header of a write that spans
+// the whole profile, such as an import, against the profile version, using
+// the same strong comparison. When the precondition fails the response has
+// already been written.
+func (s *Server) checkProfileIfMatch(w http.ResponseWriter, r *http.Request, profile *db.Profile) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if s.requireIfMatch {
+			http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+			return false
+		}
+		return true
+	}
+
+	expected := profileETag(profile.Version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == expected {
+			return true
+		}
+	}
+
+	w.Header().Set("ETag", expected)
+	writeJSONStatus(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error":           "Precondition failed",
+		"current_version": profile.Version,
+	})
+	return false
+}
+
+func writePreconditionFailed(w http.ResponseWriter, section string, current int) {
+	w.Header().Set("ETag", sectionETag(section, current))
+	writeJSONStatus(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error":           "Precondition failed",
+		"section":         section,
+		"current_version": current,
+	})
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	profile := &db.Profile{Version: 5, SectionVersions: map[string]int{"assets": 2}}
+
+	tests := []struct {
+		name           string
+		section        string
+		header         string
+		requireIfMatch bool
+		ok             bool
+		expected       int
+		status         int
+	}{
+		{name: "no header", section: "assets", ok: true, expected: db.AnyVersion},
+		{name: "no header when required", section: "assets", requireIfMatch: true, status: http.StatusPreconditionRequired},
+		{name: "current ETag", section: "assets", header: `"assets-2"`, ok: true, expected: 2},
+		{name: "weak ETag", section: "assets", header: `W/"assets-2"`, status: http.StatusPreconditionFailed},
+		{name: "one of several", section: "assets", header: `"assets-1", "assets-2"`, ok: true, expected: 2},
+		{name: "wildcard", section: "assets", header: "*", ok: true, expected: 2},
+		{name: "stale ETag", section: "assets", header: `"assets-1"`, status: http.StatusPreconditionFailed},
+		{name: "ETag of another section", section: "assets", header: `"threats-2"`, status: http.StatusPreconditionFailed},
+		{name: "profile ETag", section: "assets", header: `"profile-5"`, status: http.StatusPreconditionFailed},
+		{name: "unwritten section", section: "threats", header: `"threats-0"`, ok: true, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{requireIfMatch: tt.requireIfMatch}
+			r := httptest.NewRequest("PUT", "/api/profiles/p/"+tt.section, nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			expected, ok := s.checkIfMatch(w, r, profile, tt.section)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (%d %s)", ok, tt.ok, w.Code, w.Body.String())
+			}
+			if ok {
+				if expected != tt.expected {
+					t.Errorf("expected version = %d, want %d", expected, tt.expected)
+				}
+				return
+			}
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusPreconditionFailed {
+				body := decode(t, w)
+				if body["section"] != tt.section || body["current_version"] != 2.0 {
+					t.Errorf("body = %v, want %s at version 2", body, tt.section)
+				}
+				if etag := w.Header().Get("ETag"); etag != sectionETag(tt.section, 2) {
+					t.Errorf("ETag = %s, want the current section ETag", etag)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckProfileIfMatch(t *testing.T) {
+	profile := &db.Profile{Version: 5, SectionVersions: map[string]int{"assets": 5}}
+
+	tests := []struct {
+		name           string
+		header         string
+		requireIfMatch bool
+		status         int
+	}{
+		{name: "no header", status: http.StatusOK},
+		{name: "no header when required", requireIfMatch: true, status: http.StatusPreconditionRequired},
+		{name: "current ETag", header: `"profile-5"`, status: http.StatusOK},
+		{name: "weak ETag", header: `W/"profile-5"`, status: http.StatusPreconditionFailed},
+		{name: "wildcard", header: "*", status: http.StatusOK},
+		{name: "stale ETag", header: `"profile-4"`, status: http.StatusPreconditionFailed},
+		{name: "section ETag", header: `"assets-5"`, status: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{requireIfMatch: tt.requireIfMatch}
+			r := httptest.NewRequest("POST", "/api/profiles/p/import", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			ok := s.checkProfileIfMatch(w, r, profile)
+			if ok != (tt.status == http.StatusOK) {
+				t.Fatalf("ok = %v, want status %d (%d %s)", ok, tt.status, w.Code, w.Body.String())
+			}
+			if ok {
+				return
+			}
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusPreconditionFailed {
+				if body := decode(t, w); body["current_version"] != 5.0 {
+					t.Errorf("body = %v, want current_version 5", body)
+				}
+				if etag := w.Header().Get("ETag"); etag != profileETag(5) {
+					t.Errorf("ETag = %s, want %s", etag, profileETag(5))
+				}
+			}
+		})
+	}
+}
+
+func TestStaleSectionWriteIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+
+	w := mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets)
+	etag := w.Header().Get("ETag")
+	if etag != `"assets-1"` {
+		t.Fatalf("ETag = %s, want \"assets-1\"", etag)
+	}
+
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets, "If-Match", etag)
+	mustDo(t, s, http.StatusPreconditionFailed, "PUT", "/api/profiles/"+id+"/assets", testAssets, "If-Match", etag)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/scoring"
+	"github.com/HyphaGroup/armor/server/internal/summary"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+func riskBandsFromEnv() scoring.Bands {
+	spec := os.Getenv("ARMOR_RISK_BANDS")
+	if spec == "" {
+		return scoring.DefaultBands
+	}
+
+	bands, err := scoring.ParseBands(spec)
+	if err != nil {
+		log.Printf("Warning: invalid ARMOR_RISK_BANDS (%v), using defaults", err)
+		return scoring.DefaultBands
+	}
+	return bands
+}
+
+func completenessWeightsFromEnv() validator.Weights {
+	spec := os.Getenv("ARMOR_COMPLETENESS_WEIGHTS")
+	if spec == "" {
+		return validator.DefaultWeights
+	}
+
+	weights, err := validator.ParseWeights(spec)
+	if err != nil {
+		log.Printf("Warning: invalid ARMOR_COMPLETENESS_WEIGHTS (%v), using defaults", err)
+		return validator.DefaultWeights
+	}
+	return weights
+}
+
+// derivedSection is a section value after the server has filled in the
+// fields it owns.
+type derivedSection struct {
+	data             string
+	scoreCorrections []scoring.Discrepancy
+}
+
+// deriveSection computes the server-owned fields of a section before it is
+// saved. Risk scores are derived from the assets and threats the risks
+// reference, so clients cannot submit scores that disagree with the model,
+// and the risk and mitigation summaries are recounted from their items.
+func (s *Server) deriveSection(profile *db.Profile, section, data string) (*derivedSection, error) {
+	derived := &derivedSection{data: data}
+
+	switch section {
+	case "risks":
+		scored, corrections, err := scoring.ScoreRisks(data, profile.Assets, profile.Threats, s.riskBands)
+		if err != nil {
+			return nil, err
+		}
+		derived.scoreCorrections = corrections
+
+		derived.data, err = summary.Risks(scored)
+		if err != nil {
+			return nil, err
+		}
+	case "mitigations":
+		summarized, err := summary.Mitigations(data)
+		if err != nil {
+			return nil, err
+		}
+		derived.data = summarized
+	}
+
+	return derived, nil
+}
+
+// dependents lists the sections whose derived fields are read from other
+// sections. Risks are scored from asset values and threat likelihoods, so
+// they have to be rescored whenever either changes.
+var dependents = map[string][]string{
+	"assets":  {"risks"},
+	"threats": {"risks"},
+}
+
+// derivedSections is a set of changed sections after derivation, together
+// with the stored sections that had to be derived again because they depend
+// on them.
+type derivedSections struct {
+	sections         map[string]*string
+	merged           *db.Profile
+	scoreCorrections []scoring.Discrepancy
+	// cascaded names the stored sections that changed only because a
+	// section they depend on did.
+	cascaded []string
+}
+
+// deriveSections derives every changed section against the profile as it
+// will look once they are all written, then rederives the stored sections
+// that depend on them. Dependent sections are derived last so they see the
+// new values, and are only included when their derived fields moved. Score
+// corrections are only reported for sections the caller submitted. A nil
+// value clears a section and is passed through as is.
+func (s *Server) deriveSections(profile *db.Profile, changed map[string]*string) (*derivedSections, error) {
+	merged := *profile
+	derived := &derivedSections{sections: make(map[string]*string), merged: &merged}
+	for name, data := range changed {
+		merged.SetSection(name, data)
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	isDependent := make(map[string]bool)
+	var cascade []string
+	for _, name := range names {
+		for _, dependent := range dependents[name] {
+			isDependent[dependent] = true
+			if _, ok := changed[dependent]; !ok && profile.Section(dependent) != nil && !contains(cascade, dependent) {
+				cascade = append(cascade, dependent)
+			}
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return !isDependent[names[i]] && isDependent[names[j]] })
+
+	for _, name := range names {
+		data := changed[name]
+		if data != nil {
+			result, err := s.deriveSection(&merged, name, *data)
+			if err != nil {
+				return nil, err
+			}
+			data = &result.data
+			derived.scoreCorrections = append(derived.scoreCorrections, result.scoreCorrections...)
+		}
+		derived.sections[name] = data
+		merged.SetSection(name, data)
+	}
+
+	for _, name := range cascade {
+		stored := profile.Section(name)
+		result, err := s.deriveSection(&merged, name, *stored)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(parseJSON(stored), parseJSON(&result.data)) {
+			continue
+		}
+		derived.sections[name] = &result.data
+		derived.cascaded = append(derived.cascaded, name)
+		merged.SetSection(name, &result.data)
+	}
+
+	return derived, nil
+}
+
+// expectedVersions returns the versions sections must still be at for a
+// write planned against profile to go ahead.
+func expectedVersions(profile *db.Profile, sections map[string]*string) map[string]int {
+	expected := make(map[string]int, len(sections))
+	for name := range sections {
+		expected[name] = profile.SectionVersions[name]
+	}
+	return expected
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,485 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/scoring"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+	"github.com/google/uuid"
+)
+
+// exportFormat identifies ARMOR profile exports among other JSON documents.
+const exportFormat = "armor-profile"
+
+// Conflict handling when an import meets a section that already has data.
+const (
+	conflictFail    = "fail"
+	conflictSkip    = "skip"
+	conflictReplace = "replace"
+)
+
+// profileExport is the document produced by export and accepted by import.
+// Meta follows meta.schema.json. The schema version is repeated at the top
+// level so readers can check it before looking at anything else.
+type profileExport struct {
+	Format        string                     `json:"format"`
+	SchemaVersion string                     `json:"schema_version"`
+	ExportedAt    time.Time                  `json:"exported_at"`
+	Meta          json.RawMessage            `json:"meta"`
+	Profile       exportedProfile            `json:"profile"`
+	Sections      map[string]json.RawMessage `json:"sections"`
+	Redacted      bool                       `json:"redacted,omitempty"`
+}
+
+type exportedProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     int    `json:"version"`
+}
+
+// exportProfile writes the whole profile as one self-describing document.
+// Sections go through the redaction policy like any other response, so an
+// export never carries more than its caller could read.
+func (s *Server) exportProfile(w http.ResponseWriter, r *http.Request, profileID string) {
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	fields, err := s.profileMeta(profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	meta, err := json.Marshal(fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sections := make(map[string]json.RawMessage)
+	for name, data := range profile.Sections() {
+		value, err := json.Marshal(parseJSON(data))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sections[name] = value
+	}
+
+	export := profileExport{
+		Format:        exportFormat,
+		SchemaVersion: s.validator.SchemaVersion(),
+		ExportedAt:    time.Now().UTC(),
+		Meta:          meta,
+		Profile: exportedProfile{
+			Name:        profile.Name,
+			Description: profile.Description,
+			Version:     profile.Version,
+		},
+		Sections: sections,
+		Redacted: !s.canSeeSensitive(r) && len(s.redaction.paths) > 0,
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="armor-%s.json"`, profile.ID))
+	writeJSON(w, export)
+}
+
+type importOptions struct {
+	remapIDs   bool
+	onConflict string
+}
+
+// importOptionsFrom reads ?remap_ids=true and ?on_conflict=fail|skip|replace.
+func importOptionsFrom(w http.ResponseWriter, r *http.Request) (importOptions, bool) {
+	query := r.URL.Query()
+	opts := importOptions{onConflict: conflictFail}
+
+	if value := query.Get("remap_ids"); value != "" {
+		remap, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid remap_ids", http.StatusBadRequest)
+			return opts, false
+		}
+		opts.remapIDs = remap
+	}
+
+	if value := query.Get("on_conflict"); value != "" {
+		switch value {
+		case conflictFail, conflictSkip, conflictReplace:
+			opts.onConflict = value
+		default:
+			http.Error(w, "Invalid on_conflict: must be fail, skip or replace", http.StatusBadRequest)
+			return opts, false
+		}
+	}
+
+	return opts, true
+}
+
+// importPlan holds the checked section values an import will write.
+type importPlan struct {
+	sections         map[string]*string
+	meta             *string
+	skipped          []string
+	idMapping        map[string]map[string]string
+	warnings         []validator.BrokenReference
+	scoreCorrections []scoring.Discrepancy
+	redacted         bool
+	// rescored names stored sections that are written only because the
+	// import changed sections they are derived from.
+	rescored []string
+}
+
+func (p *importPlan) response() map[string]interface{} {
+	imported := make([]string, 0, len(p.sections))
+	for name := range p.sections {
+		if !contains(p.rescored, name) {
+			imported = append(imported, name)
+		}
+	}
+	sort.Strings(imported)
+
+	response := map[string]interface{}{
+		"success":  true,
+		"sections": imported,
+		"skipped":  p.skipped,
+	}
+	if p.meta != nil {
+		response["meta_imported"] = true
+	}
+	if p.idMapping != nil {
+		response["id_mapping"] = p.idMapping
+	}
+	if len(p.warnings) > 0 {
+		response["warnings"] = p.warnings
+	}
+	if len(p.scoreCorrections) > 0 {
+		response["score_corrections"] = p.scoreCorrections
+	}
+	if len(p.rescored) > 0 {
+		response["rescored_sections"] = p.rescored
+	}
+	if p.redacted {
+		response["redacted"] = true
+	}
+	return response
+}
+
+// importProfile creates a new profile from an export. Like createProfile it
+// is for administrators, since the new profile belongs to no organization.
+func (s *Server) importProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !principalFrom(r).admin {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	opts, ok := importOptionsFrom(w, r)
+	if !ok {
+		return
+	}
+
+	export, ok := s.decodeExport(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = export.Profile.Name
+	}
+	if name == "" {
+		var meta struct {
+			Organization struct {
+				Name string `json:"name"`
+			} `json:"organization"`
+		}
+		json.Unmarshal(export.Meta, &meta)
+		name = meta.Organization.Name
+	}
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	plan, ok := s.planImport(w, r, export, &db.Profile{}, opts)
+	if !ok {
+		return
+	}
+
+	profile, err := s.db.CreateProfileWithSections(name, export.Profile.Description, plan.meta, plan.sections, db.SourceImport)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := plan.response()
+	response["profile"] = map[string]interface{}{
+		"id":          profile.ID,
+		"name":        profile.Name,
+		"description": profile.Description,
+		"created_at":  profile.CreatedAt,
+		"updated_at":  profile.UpdatedAt,
+	}
+	response["version"] = profile.Version
+	writeJSONStatus(w, http.StatusCreated, response)
+}
+
+// importIntoProfile writes the sections of an export into an existing
+// profile. Sections that already have data are refused, skipped or replaced
+// according to on_conflict.
+func (s *Server) importIntoProfile(w http.ResponseWriter, r *http.Request, profileID string) {
+	opts, ok := importOptionsFrom(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	if !s.checkProfileIfMatch(w, r, profile) {
+		return
+	}
+
+	export, ok := s.decodeExport(w, r)
+	if !ok {
+		return
+	}
+
+	plan, ok := s.planImport(w, r, export, profile, opts)
+	if !ok {
+		return
+	}
+
+	// The plan, including its conflict check, was made against the sections
+	// as they were read, so they are only written if they are still there.
+	version := profile.Version
+	if len(plan.sections) > 0 {
+		writes, err := s.db.UpdateSections(profile.ID, plan.sections, expectedVersions(profile, plan.sections), db.SourceImport)
+		var conflict *db.VersionConflictError
+		if errors.As(err, &conflict) {
+			writePreconditionFailed(w, conflict.Section, conflict.Current)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		version = writes.Version()
+	}
+	if plan.meta != nil {
+		if err := s.db.UpdateMeta(profile.ID, plan.meta); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := plan.response()
+	response["version"] = version
+	writeJSON(w, response)
+}
+
+// decodeExport reads an export document and checks that its schema version
+// is one this server understands.
+func (s *Server) decodeExport(w http.ResponseWriter, r *http.Request) (*profileExport, bool) {
+	var export profileExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if export.Format != "" && export.Format != exportFormat {
+		http.Error(w, "Not an ARMOR profile export", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if export.SchemaVersion == "" {
+		http.Error(w, "schema_version is required", http.StatusBadRequest)
+		return nil, false
+	}
+	if !compatibleSchemaVersion(export.SchemaVersion, s.validator.SchemaVersion()) {
+		http.Error(w, fmt.Sprintf("Unsupported schema_version %s (this server uses %s)",
+			export.SchemaVersion, s.validator.SchemaVersion()), http.StatusUnprocessableEntity)
+		return nil, false
+	}
+
+	if len(export.Meta) > 0 && !bytes.Equal(export.Meta, []byte("null")) {
+		errors, err := s.validator.ValidateMeta(string(export.Meta))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		if len(errors) > 0 {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "Validation failed",
+				"errors": map[string][]validator.ValidationError{"meta": errors},
+			})
+			return nil, false
+		}
+	}
+
+	return &export, true
+}
+
+// compatibleSchemaVersion accepts exports with the same major version, which
+// by semantic versioning only differ in additions.
+func compatibleSchemaVersion(version, current string) bool {
+	major, _, _ := strings.Cut(version, ".")
+	currentMajor, _, _ := strings.Cut(current, ".")
+	return major == currentMajor
+}
+
+// planImport works out and checks the section values an export would write
+// into target, which is an empty profile for a new import. Every section is
+// validated and derived as a save would be before anything is written. When
+// the import is refused the response has already been written.
+func (s *Server) planImport(w http.ResponseWriter, r *http.Request, export *profileExport, target *db.Profile, opts importOptions) (*importPlan, bool) {
+	plan := &importPlan{
+		sections: make(map[string]*string),
+		skipped:  []string{},
+		redacted: export.Redacted,
+	}
+
+	var conflicts []string
+	for name, raw := range export.Sections {
+		if !catalog.IsValidSection(name) {
+			http.Error(w, "Unknown section: "+name, http.StatusBadRequest)
+			return nil, false
+		}
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+
+		if target.Section(name) != nil {
+			switch opts.onConflict {
+			case conflictFail:
+				conflicts = append(conflicts, name)
+				continue
+			case conflictSkip:
+				plan.skipped = append(plan.skipped, name)
+				continue
+			}
+		}
+
+		data := s.unredact(r, name, target.Section(name), string(raw))
+		data, dropped := s.dropRedacted(name, data)
+		plan.redacted = plan.redacted || dropped
+		plan.sections[name] = &data
+	}
+
+	// Metadata such as the review schedule is imported like a section,
+	// except that the server-owned fields come from the target profile.
+	if len(export.Meta) > 0 {
+		meta, err := storableMeta(export.Meta)
+		if err != nil {
+			http.Error(w, "Metadata must be a JSON object", http.StatusBadRequest)
+			return nil, false
+		}
+		switch {
+		case meta == nil || (target.Meta != nil && *target.Meta == *meta):
+		case target.Meta != nil && opts.onConflict == conflictFail:
+			conflicts = append(conflicts, "meta")
+		case target.Meta != nil && opts.onConflict == conflictSkip:
+			plan.skipped = append(plan.skipped, "meta")
+		default:
+			plan.meta = meta
+		}
+	}
+	sort.Strings(plan.skipped)
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		writeJSONStatus(w, http.StatusConflict, map[string]interface{}{
+			"error":     "Sections already have data",
+			"conflicts": conflicts,
+		})
+		return nil, false
+	}
+
+	if opts.remapIDs {
+		mapping, err := validator.RemapIDs(plan.sections, newItemID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		plan.idMapping = mapping
+	}
+
+	validationErrors := map[string][]validator.ValidationError{}
+	for name, data := range plan.sections {
+		errors, err := s.validator.Validate(name, *data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		if len(errors) > 0 {
+			validationErrors[name] = errors
+		}
+	}
+	if len(validationErrors) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "Validation failed",
+			"errors": validationErrors,
+		})
+		return nil, false
+	}
+
+	// Derived fields depend on other sections, so they are computed against
+	// the profile as it will look once every imported section is in place.
+	// Stored risks are rescored when the import brings new assets or threats.
+	derived, err := s.deriveSections(target, plan.sections)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	plan.sections = derived.sections
+	plan.rescored = derived.cascaded
+	plan.scoreCorrections = derived.scoreCorrections
+	merged := derived.merged
+
+	plan.warnings = s.introducedReferences(target, merged)
+	if s.integrityMode == integrityModeError && len(plan.warnings) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": plan.warnings,
+		})
+		return nil, false
+	}
+
+	return plan, true
+}
+
+// newItemID makes a fresh item ID such as "asset-3f2a9c1b7d4e" for
+// remapped imports.
+func newItemID(kind string) string {
+	return kind + "-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// exportProfile returns the export document of a profile.
+func exportProfile(t *testing.T, s *Server, profileID string) string {
+	t.Helper()
+	return mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+profileID+"/export", "").Body.String()
+}
+
+func TestImportIntoProfileIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+	}{
+		{name: "no precondition", status: http.StatusOK},
+		{name: "any version", ifMatch: "*", status: http.StatusOK},
+		{name: "current version", ifMatch: `"profile-1"`, status: http.StatusOK},
+		{name: "stale version", ifMatch: `"profile-0"`, status: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			source := createProfile(t, s)
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+source+"/assets", testAssets)
+			export := exportProfile(t, s, source)
+
+			target := createProfile(t, s)
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+target+"/threats", testThreats)
+
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+			w := mustDo(t, s, tt.status, "POST", "/api/profiles/"+target+"/import?on_conflict=skip", export, headers...)
+			if tt.status == http.StatusPreconditionFailed {
+				if etag := w.Header().Get("ETag"); etag != `"profile-1"` {
+					t.Errorf("ETag = %s, want \"profile-1\"", etag)
+				}
+				if decode(t, w)["current_version"] != 1.0 {
+					t.Errorf("current_version = %v, want 1", decode(t, w)["current_version"])
+				}
+			}
+		})
+	}
+}
+
+func TestImportRescoresStoredRisks(t *testing.T) {
+	s := newTestServer(t)
+	source := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+source+"/assets", strings.Replace(testAssets, `"medium"`, `"critical"`, 1))
+	export := exportProfile(t, s, source)
+
+	target := createScoredProfile(t, s)
+	response := decode(t, mustDo(t, s, http.StatusOK, "POST", "/api/profiles/"+target+"/import?on_conflict=replace", export))
+
+	if sections, _ := response["sections"].([]interface{}); len(sections) != 1 || sections[0] != "assets" {
+		t.Errorf("sections = %v, want [assets]", response["sections"])
+	}
+	if rescored, _ := response["rescored_sections"].([]interface{}); len(rescored) != 1 || rescored[0] != "risks" {
+		t.Errorf("rescored_sections = %v, want [risks]", response["rescored_sections"])
+	}
+
+	// critical (3) × high (3) × vulnerability 1 = 9.
+	risk, _ := getRisk(t, s, target, "risk-r1")
+	if risk["risk_score"] != 9.0 {
+		t.Errorf("risk_score = %v, want 9", risk["risk_score"])
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/jsondiff"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	isRestore := len(parts) == 2 && parts[1] == "restore"
+	if isRestore && r.Method != "POST" || !isRestore && r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 0 || parts[0] == "":
+		s.listHistory(w, r, profileID)
+	case len(parts) == 1 && parts[0] == "diff":
+		s.diffHistory(w, r, profile)
+	case len(parts) == 1:
+		s.getHistoryEntry(w, r, profileID, parts[0])
+	case isRestore:
+		s.restoreVersion(w, r, profile, parts[0])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request, profileID string) {
+	query := r.URL.Query()
+	filter := db.HistoryFilter{Section: query.Get("section")}
+
+	if filter.Section != "" && !catalog.IsValidSection(filter.Section) {
+		http.Error(w, "Invalid section", http.StatusBadRequest)
+		return
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "Invalid since timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.db.ListHistory(profileID, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The list only carries metadata; values are fetched per version.
+	summaries := []map[string]interface{}{}
+	for _, e := range entries {
+		summaries = append(summaries, map[string]interface{}{
+			"id":        e.ID,
+			"version":   e.Version,
+			"section":   e.Section,
+			"source":    e.Source,
+			"timestamp": e.CreatedAt,
+		})
+	}
+
+	writeJSON(w, summaries)
+}
+
+func (s *Server) getHistoryEntry(w http.ResponseWriter, r *http.Request, profileID, versionParam string) {
+	version, err := strconv.Atoi(versionParam)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.viewHistoryEntry(r, profileID, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if entry == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	previous := parseJSON(entry.PreviousValue)
+	current := parseJSON(entry.NewValue)
+
+	writeJSON(w, map[string]interface{}{
+		"id":             entry.ID,
+		"version":        entry.Version,
+		"section":        entry.Section,
+		"source":         entry.Source,
+		"timestamp":      entry.CreatedAt,
+		"previous_value": previous,
+		"new_value":      current,
+		"diff":           jsondiff.Diff(previous, current),
+	})
+}
+
+// diffHistory compares the profile between two versions. Without a section
+// filter every section changed in between is included.
+func (s *Server) diffHistory(w http.ResponseWriter, r *http.Request, profile *db.Profile) {
+	query := r.URL.Query()
+
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil || from < 0 {
+		http.Error(w, "Invalid from version", http.StatusBadRequest)
+		return
+	}
+
+	to := profile.Version
+	if toParam := query.Get("to"); toParam != "" {
+		to, err = strconv.Atoi(toParam)
+		if err != nil || to < 0 {
+			http.Error(w, "Invalid to version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if from > profile.Version || to > profile.Version {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	var sections []string
+	if section := query.Get("section"); section != "" {
+		if !catalog.IsValidSection(section) {
+			http.Error(w, "Invalid section", http.StatusBadRequest)
+			return
+		}
+		sections = []string{section}
+	} else {
+		low, high := from, to
+		if low > high {
+			low, high = high, low
+		}
+		sections, err = s.db.ChangedSections(profile.ID, low, high)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	diffs := map[string][]jsondiff.Change{}
+	for _, section := range sections {
+		before, err := s.viewSectionAt(r, profile.ID, section, from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		after, err := s.viewSectionAt(r, profile.ID, section, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		diffs[section] = jsondiff.Diff(parseJSON(before), parseJSON(after))
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"from":     from,
+		"to":       to,
+		"sections": diffs,
+	})
+}
+
+// restoreVersion brings the profile, or a single section, back to how it was
+// at an earlier version. The restored values are validated against the
+// current schemas, derived and checked for broken references like a save,
+// and written as new versions, so history is never rewritten.
+func (s *Server) restoreVersion(w http.ResponseWriter, r *http.Request, profile *db.Profile, versionParam string) {
+	version, err := strconv.Atoi(versionParam)
+	if err != nil || version < 0 {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	if version > profile.Version {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Section string `json:"section"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if section := r.URL.Query().Get("section"); section != "" {
+		req.Section = section
+	}
+
+	var sections []string
+	if req.Section != "" {
+		if !catalog.IsValidSection(req.Section) {
+			http.Error(w, "Invalid section", http.StatusBadRequest)
+			return
+		}
+		sections = []string{req.Section}
+	} else {
+		sections, err = s.db.ChangedSections(profile.ID, version, profile.Version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	updates := map[string]*string{}
+	validationErrors := map[string][]validator.ValidationError{}
+	for _, section := range sections {
+		value, err := s.db.GetSectionAtVersion(profile.ID, section, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		current := profile.Section(section)
+		if value == nil && current == nil || value != nil && current != nil && *value == *current {
+			continue
+		}
+
+		if value != nil && s.validator.HasSchema(section) {
+			sectionErrors, err := s.validator.Validate(section, *value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(sectionErrors) > 0 {
+				validationErrors[section] = sectionErrors
+				continue
+			}
+		}
+
+		updates[section] = value
+	}
+
+	if len(validationErrors) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "Validation failed",
+			"errors": validationErrors,
+		})
+		return
+	}
+
+	restored := make([]string, 0, len(updates))
+	for section := range updates {
+		restored = append(restored, section)
+	}
+	sort.Strings(restored)
+
+	response := map[string]interface{}{
+		"success":       true,
+		"restored_from": version,
+		"sections":      restored,
+		"version":       profile.Version,
+	}
+	if len(updates) == 0 {
+		writeJSON(w, response)
+		return
+	}
+
+	// Restored values are derived against the profile as it is now, and
+	// stored risks are rescored when old assets or threats come back.
+	derived, err := s.deriveSections(profile, updates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	warnings := s.introducedReferences(profile, derived.merged)
+	if s.integrityMode == integrityModeError && len(warnings) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": warnings,
+		})
+		return
+	}
+
+	writes, err := s.db.UpdateSections(profile.ID, derived.sections, expectedVersions(profile, derived.sections), db.SourceRestore)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		writePreconditionFailed(w, conflict.Section, conflict.Current)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response["version"] = writes.Version()
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	if len(derived.scoreCorrections) > 0 {
+		response["score_corrections"] = derived.scoreCorrections
+	}
+	if len(derived.cascaded) > 0 {
+		rescored := make(map[string]int, len(derived.cascaded))
+		for _, name := range derived.cascaded {
+			rescored[name] = writes[name].SectionVersion
+		}
+		response["rescored_sections"] = rescored
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRestoreVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		integrity string
+		assets    string
+		status    int
+		score     float64
+	}{
+		{
+			// medium (2) × high (3) × vulnerability 1 = 6 again.
+			name:      "restored assets rescore risks",
+			integrity: integrityModeError,
+			assets:    testAssets,
+			status:    http.StatusOK,
+			score:     6,
+		},
+		{
+			name:      "restored assets break references",
+			integrity: integrityModeError,
+			assets:    `{"assets": []}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "broken references only warn",
+			integrity: integrityModeWarn,
+			assets:    `{"assets": []}`,
+			status:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.integrityMode = tt.integrity
+			id := createProfile(t, s)
+
+			// Version 1 holds the assets to restore.
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", tt.assets)
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", strings.Replace(testAssets, `"medium"`, `"critical"`, 1))
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", testThreats)
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/risks", testRisks)
+
+			w := mustDo(t, s, tt.status, "POST", "/api/profiles/"+id+"/history/1/restore", `{"section": "assets"}`)
+			if tt.status != http.StatusOK {
+				if broken, _ := decode(t, w)["broken_references"].([]interface{}); len(broken) == 0 {
+					t.Errorf("response = %s, want the broken references", w.Body.String())
+				}
+				w = mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/assets", "")
+				if etag := w.Header().Get("ETag"); etag != `"assets-2"` {
+					t.Errorf("assets ETag = %s, want nothing restored", etag)
+				}
+				return
+			}
+
+			response := decode(t, w)
+			if tt.score == 0 {
+				if warnings, _ := response["warnings"].([]interface{}); len(warnings) == 0 {
+					t.Errorf("response = %v, want warnings", response)
+				}
+				return
+			}
+
+			rescored, _ := response["rescored_sections"].(map[string]interface{})
+			if rescored["risks"] != 2.0 {
+				t.Errorf("rescored_sections = %v, want risks at version 2", response["rescored_sections"])
+			}
+			if risk, _ := getRisk(t, s, id, "risk-r1"); risk["risk_score"] != tt.score {
+				t.Errorf("risk_score = %v, want %v", risk["risk_score"], tt.score)
+			}
+		})
+	}
+}
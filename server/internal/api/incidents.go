@@ -0,0 +1,533 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/review"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+	"github.com/google/uuid"
+)
+
+// handleIncidents serves /incidents, /incidents/:id and the response
+// actions below /incidents/:id/actions.
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 0 || parts[0] == "" {
+		switch r.Method {
+		case "GET":
+			s.listIncidents(w, r, profileID)
+		case "POST":
+			s.createIncident(w, r, profile)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(parts) > 3 || len(parts) > 1 && parts[1] != "actions" && !(len(parts) == 2 && parts[1] == "suggestions") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	incident, err := s.db.GetIncident(profileID, parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if incident == nil {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case "GET":
+			writeJSON(w, incident)
+		case "PATCH":
+			s.updateIncident(w, r, profile, incident)
+		case "DELETE":
+			s.deleteIncident(w, r, incident)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case parts[1] == "suggestions":
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.getIncidentSuggestions(w, r, incident)
+	case len(parts) == 2:
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.addResponseAction(w, r, incident)
+	default:
+		switch r.Method {
+		case "PATCH":
+			s.updateResponseAction(w, r, incident, parts[2])
+		case "DELETE":
+			s.deleteResponseAction(w, r, incident, parts[2])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// listIncidents accepts comma separated ?status= and ?severity= lists,
+// ?type=, ?since= (on occurrence time) and ?limit=.
+func (s *Server) listIncidents(w http.ResponseWriter, r *http.Request, profileID string) {
+	query := r.URL.Query()
+	filter := db.IncidentFilter{Type: query.Get("type")}
+
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+		for _, value := range filter.Statuses {
+			if !db.ValidIncidentStatuses[value] {
+				http.Error(w, "Invalid status: must be open, investigating, resolved or closed", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if severity := query.Get("severity"); severity != "" {
+		filter.Severities = strings.Split(severity, ",")
+		for _, value := range filter.Severities {
+			if !db.ValidSeverities[value] {
+				http.Error(w, "Invalid severity: must be critical, high, medium or low", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if filter.Type != "" && !db.ValidIncidentTypes[filter.Type] {
+		http.Error(w, "Invalid type", http.StatusBadRequest)
+		return
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "Invalid since timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	incidents, err := s.db.ListIncidents(profileID, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, incidents)
+}
+
+// incidentRequest is the body of a create or update. Fields left out of an
+// update keep their values.
+type incidentRequest struct {
+	Title          *string    `json:"title"`
+	Description    *string    `json:"description"`
+	Type           *string    `json:"type"`
+	Severity       *string    `json:"severity"`
+	Status         *string    `json:"status"`
+	OccurredAt     *time.Time `json:"occurred_at"`
+	DiscoveredAt   *time.Time `json:"discovered_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	AffectedAssets []string   `json:"affected_assets"`
+	RelatedThreats []string   `json:"related_threats"`
+	LessonsLearned *string    `json:"lessons_learned"`
+}
+
+// apply copies the fields present in the request onto an incident.
+func (req *incidentRequest) apply(incident *db.Incident) {
+	if req.Title != nil {
+		incident.Title = *req.Title
+	}
+	if req.Description != nil {
+		incident.Description = *req.Description
+	}
+	if req.Type != nil {
+		incident.Type = *req.Type
+	}
+	if req.Severity != nil {
+		incident.Severity = *req.Severity
+	}
+	if req.OccurredAt != nil {
+		incident.OccurredAt = req.OccurredAt.UTC()
+	}
+	if req.DiscoveredAt != nil {
+		incident.DiscoveredAt = req.DiscoveredAt.UTC()
+	}
+	if req.AffectedAssets != nil {
+		incident.AffectedAssets = req.AffectedAssets
+	}
+	if req.RelatedThreats != nil {
+		incident.RelatedThreats = req.RelatedThreats
+	}
+	if req.LessonsLearned != nil {
+		if *req.LessonsLearned == "" {
+			incident.LessonsLearned = nil
+		} else {
+			incident.LessonsLearned = req.LessonsLearned
+		}
+	}
+
+	if req.Status != nil {
+		incident.Status = *req.Status
+	}
+	if req.ResolvedAt != nil {
+		t := req.ResolvedAt.UTC()
+		incident.ResolvedAt = &t
+	}
+
+	// resolved_at follows the status: it is stamped when an incident is
+	// resolved or closed and cleared when it is reopened.
+	switch incident.Status {
+	case db.IncidentResolved, db.IncidentClosed:
+		if incident.ResolvedAt == nil {
+			now := time.Now().UTC().Truncate(time.Second)
+			incident.ResolvedAt = &now
+		}
+	default:
+		incident.ResolvedAt = nil
+	}
+}
+
+// checkIncident validates an incident before it is stored, writing the
+// error response when it is invalid. Affected assets and related threats
+// must name items in the profile's assets and threats sections, except for
+// the stored links, whose items may have been removed since. Those are
+// reported by the integrity check instead.
+func (s *Server) checkIncident(w http.ResponseWriter, profile *db.Profile, incident *db.Incident, stored review.Links) bool {
+	switch {
+	case strings.TrimSpace(incident.Title) == "":
+		http.Error(w, "Title is required", http.StatusBadRequest)
+	case strings.TrimSpace(incident.Description) == "":
+		http.Error(w, "Description is required", http.StatusBadRequest)
+	case !db.ValidIncidentTypes[incident.Type]:
+		http.Error(w, "Invalid type: must be phishing, account_compromise, data_breach, malware, physical, harassment, disinformation or other", http.StatusBadRequest)
+	case !db.ValidSeverities[incident.Severity]:
+		http.Error(w, "Invalid severity: must be critical, high, medium or low", http.StatusBadRequest)
+	case !db.ValidIncidentStatuses[incident.Status]:
+		http.Error(w, "Invalid status: must be open, investigating, resolved or closed", http.StatusBadRequest)
+	case incident.OccurredAt.IsZero():
+		http.Error(w, "occurred_at is required", http.StatusBadRequest)
+	case incident.DiscoveredAt.Before(incident.OccurredAt):
+		http.Error(w, "discovered_at must not be before occurred_at", http.StatusBadRequest)
+	case incident.ResolvedAt != nil && incident.ResolvedAt.Before(incident.OccurredAt):
+		http.Error(w, "resolved_at must not be before occurred_at", http.StatusBadRequest)
+	default:
+		broken := incidentReferences(profile, incident, stored)
+		if len(broken) == 0 {
+			return true
+		}
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": broken,
+		})
+	}
+	return false
+}
+
+// incidentReferences returns the affected assets and related threats of an
+// incident that do not resolve to items in the profile, leaving out the
+// links in skip. Paths are relative to the incident.
+func incidentReferences(profile *db.Profile, incident *db.Incident, skip review.Links) []validator.BrokenReference {
+	var broken []validator.BrokenReference
+
+	check := func(field, target, kind string, ids, skipped []string) {
+		known := validator.ItemIDs(target, profile.Section(target))
+		for _, id := range skipped {
+			known[id] = true
+		}
+		for i, id := range ids {
+			if known[id] {
+				continue
+			}
+			broken = append(broken, validator.BrokenReference{
+				Section: "incidents",
+				Path:    fmt.Sprintf("/%s/%d", field, i),
+				ID:      id,
+				Target:  target,
+				Message: fmt.Sprintf("%s %q not found in %s", kind, id, target),
+			})
+		}
+	}
+	check("affected_assets", "assets", "asset", incident.AffectedAssets, skip.AssetIDs)
+	check("related_threats", "threats", "threat", incident.RelatedThreats, skip.ThreatIDs)
+
+	return broken
+}
+
+func (s *Server) createIncident(w http.ResponseWriter, r *http.Request, profile *db.Profile) {
+	var req incidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Incidents are usually logged as soon as they are found.
+	incident := &db.Incident{
+		Status:       db.IncidentOpen,
+		DiscoveredAt: time.Now().UTC().Truncate(time.Second),
+		CreatedBy:    principalFrom(r).userID,
+	}
+	req.apply(incident)
+
+	if !s.checkIncident(w, profile, incident, review.Links{}) {
+		return
+	}
+
+	if err := s.db.CreateIncident(profile.ID, incident); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.openReview(profile, incident, db.TriggerIncident,
+		review.Links{AssetIDs: incident.AffectedAssets, ThreatIDs: incident.RelatedThreats})
+
+	writeJSONStatus(w, http.StatusCreated, incident)
+}
+
+// getIncidentSuggestions works out the suggestions a review task for the
+// incident's links would carry, without opening one.
+func (s *Server) getIncidentSuggestions(w http.ResponseWriter, r *http.Request, incident *db.Incident) {
+	profile, err := s.viewProfile(r, incident.ProfileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	links := review.Links{AssetIDs: incident.AffectedAssets, ThreatIDs: incident.RelatedThreats}
+	writeJSON(w, map[string]interface{}{
+		"incident_id": incident.ID,
+		"suggestions": review.Suggest(profile.Sections(), links),
+	})
+}
+
+func (s *Server) updateIncident(w http.ResponseWriter, r *http.Request, profile *db.Profile, incident *db.Incident) {
+	var req incidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	before := review.Links{AssetIDs: incident.AffectedAssets, ThreatIDs: incident.RelatedThreats}
+	req.apply(incident)
+
+	if !s.checkIncident(w, profile, incident, before) {
+		return
+	}
+
+	if !s.saveIncident(w, incident, http.StatusOK, incident) {
+		return
+	}
+
+	// Assets and threats linked after the incident was logged get a review
+	// of their own.
+	added := review.Links{
+		AssetIDs:  newIDs(before.AssetIDs, incident.AffectedAssets),
+		ThreatIDs: newIDs(before.ThreatIDs, incident.RelatedThreats),
+	}
+	if len(added.AssetIDs) > 0 || len(added.ThreatIDs) > 0 {
+		s.openReview(profile, incident, db.TriggerIncidentLinks, added)
+	}
+}
+
+func (s *Server) deleteIncident(w http.ResponseWriter, r *http.Request, incident *db.Incident) {
+	err := s.db.DeleteIncident(incident.ProfileID, incident.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveIncident stores an updated incident and responds with body. It
+// reports whether the incident was saved.
+func (s *Server) saveIncident(w http.ResponseWriter, incident *db.Incident, status int, body interface{}) bool {
+	err := s.db.UpdateIncident(incident)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return false
+	}
+	if errors.Is(err, db.ErrIncidentChanged) {
+		http.Error(w, "Incident has changed since it was read; retry", http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	writeJSONStatus(w, status, body)
+	return true
+}
+
+// newIDs returns the IDs in after that are not in before.
+func newIDs(before, after []string) []string {
+	known := make(map[string]bool, len(before))
+	for _, id := range before {
+		known[id] = true
+	}
+	var added []string
+	for _, id := range after {
+		if !known[id] {
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+type responseActionRequest struct {
+	Description *string    `json:"description"`
+	AssignedTo  *string    `json:"assigned_to"`
+	Status      *string    `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// apply copies the fields present in the request onto an action and checks
+// the result, returning a message for the first problem found.
+func (req *responseActionRequest) apply(action *db.ResponseAction) string {
+	if req.Description != nil {
+		action.Description = *req.Description
+	}
+	if req.AssignedTo != nil {
+		if *req.AssignedTo == "" {
+			action.AssignedTo = nil
+		} else {
+			action.AssignedTo = req.AssignedTo
+		}
+	}
+	if req.Status != nil {
+		action.Status = *req.Status
+	}
+	if req.CompletedAt != nil {
+		t := req.CompletedAt.UTC()
+		action.CompletedAt = &t
+	}
+
+	if action.Status == db.ActionCompleted {
+		if action.CompletedAt == nil {
+			now := time.Now().UTC().Truncate(time.Second)
+			action.CompletedAt = &now
+		}
+	} else {
+		action.CompletedAt = nil
+	}
+
+	switch {
+	case strings.TrimSpace(action.Description) == "":
+		return "Description is required"
+	case !db.ValidActionStatuses[action.Status]:
+		return "Invalid status: must be pending, in_progress or completed"
+	}
+	return ""
+}
+
+func (s *Server) addResponseAction(w http.ResponseWriter, r *http.Request, incident *db.Incident) {
+	var req responseActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	action := db.ResponseAction{ID: uuid.New().String(), Status: db.ActionPending}
+	if msg := req.apply(&action); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	incident.ResponseActions = append(incident.ResponseActions, action)
+	s.saveIncident(w, incident, http.StatusCreated, action)
+}
+
+func (s *Server) updateResponseAction(w http.ResponseWriter, r *http.Request, incident *db.Incident, actionID string) {
+	action := incident.Action(actionID)
+	if action == nil {
+		http.Error(w, "Response action not found", http.StatusNotFound)
+		return
+	}
+
+	var req responseActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if msg := req.apply(action); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	s.saveIncident(w, incident, http.StatusOK, action)
+}
+
+func (s *Server) deleteResponseAction(w http.ResponseWriter, r *http.Request, incident *db.Incident, actionID string) {
+	actions := incident.ResponseActions[:0]
+	found := false
+	for _, action := range incident.ResponseActions {
+		if action.ID == actionID {
+			found = true
+			continue
+		}
+		actions = append(actions, action)
+	}
+	if !found {
+		http.Error(w, "Response action not found", http.StatusNotFound)
+		return
+	}
+	incident.ResponseActions = actions
+
+	err := s.db.UpdateIncident(incident)
+	if errors.Is(err, db.ErrIncidentChanged) {
+		http.Error(w, "Incident has changed since it was read; retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
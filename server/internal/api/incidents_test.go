@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIncidentLinksToRemovedItems(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", testThreats)
+
+	incident := decode(t, mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+id+"/incidents", `{
+		"title": "Laptop stolen", "description": "Left in a taxi", "type": "physical",
+		"severity": "medium", "occurred_at": "2026-01-05T10:00:00Z", "affected_assets": ["asset-a1"]}`))
+	path := "/api/profiles/" + id + "/incidents/" + incident["id"].(string)
+
+	// The asset is removed after the incident was logged.
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", `{"assets": []}`)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		broken []string
+	}{
+		{name: "unrelated change", body: `{"status": "investigating"}`, status: http.StatusOK},
+		{name: "link kept, valid link added", body: `{"affected_assets": ["asset-a1"], "related_threats": ["threat-t1"]}`, status: http.StatusOK},
+		{name: "unknown link added", body: `{"affected_assets": ["asset-a1", "asset-gone"]}`, status: http.StatusBadRequest, broken: []string{"/affected_assets/1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := mustDo(t, s, tt.status, "PATCH", path, tt.body)
+			if tt.broken == nil {
+				return
+			}
+			refs := decode(t, w)["broken_references"].([]interface{})
+			if len(refs) != len(tt.broken) {
+				t.Fatalf("broken_references = %v, want %v", refs, tt.broken)
+			}
+			for i, ref := range refs {
+				if got := ref.(map[string]interface{})["path"]; got != tt.broken[i] {
+					t.Errorf("broken reference %d path = %v, want %s", i, got, tt.broken[i])
+				}
+			}
+		})
+	}
+
+	report := decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/integrity", ""))
+	refs, _ := report["broken_references"].([]interface{})
+	found := false
+	for _, ref := range refs {
+		ref := ref.(map[string]interface{})
+		if ref["section"] == "incidents" && ref["path"] == "/"+incident["id"].(string)+"/affected_assets/0" {
+			found = true
+		}
+	}
+	if !found || report["valid"] != false {
+		t.Errorf("integrity report = %v, want the dangling incident link", report)
+	}
+}
+
+func TestIncidentSuggestions(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", strings.Replace(testThreats, `"high"`, `"medium"`, 1))
+
+	tests := []struct {
+		name    string
+		threats string
+		want    int
+	}{
+		{name: "linked threat", threats: `["threat-t1"]`, want: 1},
+		{name: "no links", threats: `[]`, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incident := decode(t, mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+id+"/incidents", `{
+				"title": "Phishing wave", "description": "Fake donation pages", "type": "phishing",
+				"severity": "high", "occurred_at": "2026-01-05T10:00:00Z", "related_threats": `+tt.threats+`}`))
+			path := "/api/profiles/" + id + "/incidents/" + incident["id"].(string) + "/suggestions"
+
+			response := decode(t, mustDo(t, s, http.StatusOK, "GET", path, ""))
+			if response["incident_id"] != incident["id"] {
+				t.Errorf("incident_id = %v, want %v", response["incident_id"], incident["id"])
+			}
+			if suggestions, _ := response["suggestions"].([]interface{}); len(suggestions) != tt.want {
+				t.Errorf("suggestions = %v, want %d", response["suggestions"], tt.want)
+			}
+
+			mustDo(t, s, http.StatusMethodNotAllowed, "POST", path, "")
+		})
+	}
+}
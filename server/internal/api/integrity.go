@@ -0,0 +1,114 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/review"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+const (
+	integrityModeOff   = "off"
+	integrityModeWarn  = "warn"
+	integrityModeError = "error"
+)
+
+func integrityModeFromEnv() string {
+	mode := os.Getenv("ARMOR_INTEGRITY_MODE")
+	switch mode {
+	case integrityModeOff, integrityModeWarn, integrityModeError:
+		return mode
+	case "":
+		return integrityModeWarn
+	default:
+		log.Printf("Warning: unknown ARMOR_INTEGRITY_MODE %q, using %q", mode, integrityModeWarn)
+		return integrityModeWarn
+	}
+}
+
+// checkIntegrity resolves references across the profile as it would look
+// after saving data into section. It returns the broken references that
+// involve the section, and the subset that the save itself would introduce.
+// Links that were already broken never block an unrelated save.
+func (s *Server) checkIntegrity(profile *db.Profile, section, data string) (warnings, introduced []validator.BrokenReference) {
+	if s.integrityMode == integrityModeOff {
+		return nil, nil
+	}
+
+	sections := profile.Sections()
+	before := make(map[string]bool)
+	for _, ref := range validator.CheckReferences(sections) {
+		before[ref.Key()] = true
+	}
+
+	sections[section] = &data
+	for _, ref := range validator.CheckReferences(sections) {
+		if ref.Section != section && ref.Target != section {
+			continue
+		}
+		warnings = append(warnings, ref)
+		if !before[ref.Key()] {
+			introduced = append(introduced, ref)
+		}
+	}
+
+	return warnings, introduced
+}
+
+// introducedReferences returns the broken references in after that were not
+// already broken in before, for writes that span several sections.
+func (s *Server) introducedReferences(before, after *db.Profile) []validator.BrokenReference {
+	if s.integrityMode == integrityModeOff {
+		return nil
+	}
+
+	broken := make(map[string]bool)
+	for _, ref := range validator.CheckReferences(before.Sections()) {
+		broken[ref.Key()] = true
+	}
+
+	var introduced []validator.BrokenReference
+	for _, ref := range validator.CheckReferences(after.Sections()) {
+		if !broken[ref.Key()] {
+			introduced = append(introduced, ref)
+		}
+	}
+	return introduced
+}
+
+func (s *Server) getIntegrity(w http.ResponseWriter, r *http.Request, profileID string) {
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	broken := validator.CheckReferences(profile.Sections())
+
+	// Incidents link to assets and threats too. Links to items removed
+	// since do not block incident updates, so this is where they surface.
+	incidents, err := s.db.ListIncidents(profileID, db.IncidentFilter{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range incidents {
+		for _, ref := range incidentReferences(profile, &incidents[i], review.Links{}) {
+			ref.Path = "/" + incidents[i].ID + ref.Path
+			broken = append(broken, ref)
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"valid":             len(broken) == 0,
+		"broken_references": broken,
+	})
+}
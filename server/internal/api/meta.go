@@ -0,0 +1,153 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+// serverMetaFields are the metadata fields the server fills in from the
+// profile itself. They are never stored, and ignored when written.
+var serverMetaFields = []string{"profile_id", "schema_version", "created_at", "updated_at"}
+
+// handleMeta serves the profile metadata described by meta.schema.json, such
+// as the organization and the review schedule.
+func (s *Server) handleMeta(w http.ResponseWriter, r *http.Request, profileID string) {
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		meta, err := s.profileMeta(profile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, meta)
+	case "PUT":
+		s.updateMeta(w, r, profile)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateMeta replaces the stored metadata. The document is validated with
+// the server-owned fields filled in, so clients may leave them out.
+func (s *Server) updateMeta(w http.ResponseWriter, r *http.Request, profile *db.Profile) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := storableMeta(body)
+	if err != nil {
+		http.Error(w, "Metadata must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	profile.Meta = stored
+	meta, err := s.profileMeta(profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	errors, err := s.validator.ValidateMeta(string(data))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(errors) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "Validation failed",
+			"errors": map[string][]validator.ValidationError{"meta": errors},
+		})
+		return
+	}
+
+	if err := s.db.UpdateMeta(profile.ID, stored); err == sql.ErrNoRows {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := s.db.GetProfile(profile.ID)
+	if err != nil || updated == nil {
+		http.Error(w, "Failed to read profile", http.StatusInternalServerError)
+		return
+	}
+	meta, err = s.profileMeta(updated)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, meta)
+}
+
+// profileMeta returns the stored metadata of a profile with the
+// server-owned fields filled in. The organization defaults to one named
+// after the profile.
+func (s *Server) profileMeta(profile *db.Profile) (map[string]interface{}, error) {
+	meta := map[string]interface{}{}
+	if profile.Meta != nil {
+		if err := json.Unmarshal([]byte(*profile.Meta), &meta); err != nil {
+			return nil, fmt.Errorf("invalid stored metadata: %w", err)
+		}
+	}
+
+	if _, ok := meta["organization"]; !ok {
+		meta["organization"] = map[string]interface{}{"name": profile.Name}
+	}
+	meta["profile_id"] = profile.ID
+	meta["schema_version"] = s.validator.SchemaVersion()
+	meta["created_at"] = profile.CreatedAt
+	meta["updated_at"] = profile.UpdatedAt
+
+	return meta, nil
+}
+
+// storableMeta drops the server-owned fields from a metadata document and
+// returns what is stored, or nil when nothing is left.
+func storableMeta(data json.RawMessage) (*string, error) {
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	for _, field := range serverMetaFields {
+		delete(meta, field)
+	}
+	if len(meta) == 0 {
+		return nil, nil
+	}
+
+	stored, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	result := string(stored)
+	return &result, nil
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+
+	meta := decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/meta", ""))
+	if meta["profile_id"] != id || meta["schema_version"] != "1.0.0" {
+		t.Errorf("meta = %v, want the server-owned fields filled in", meta)
+	}
+	if organization, _ := meta["organization"].(map[string]interface{}); organization["name"] != "Test" {
+		t.Errorf("organization = %v, want it named after the profile", meta["organization"])
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "not an object", body: `[]`, status: http.StatusBadRequest},
+		{name: "invalid review frequency", body: `{"review_schedule": {"review_frequency": "weekly"}}`, status: http.StatusBadRequest},
+		{name: "organization without a name", body: `{"organization": {"type": "labor"}}`, status: http.StatusBadRequest},
+		{name: "server-owned fields ignored", body: `{"profile_id": "not-a-uuid", "review_schedule": {"next_review": "2020-01-01"}}`, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustDo(t, s, tt.status, "PUT", "/api/profiles/"+id+"/meta", tt.body)
+		})
+	}
+
+	meta = decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/meta", ""))
+	if meta["profile_id"] != id {
+		t.Errorf("profile_id = %v, want %s", meta["profile_id"], id)
+	}
+	if schedule, _ := meta["review_schedule"].(map[string]interface{}); schedule["next_review"] != "2020-01-01" {
+		t.Errorf("review_schedule = %v, want the stored schedule", meta["review_schedule"])
+	}
+}
+
+func TestGapsReportPassedReviewSchedule(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/meta", `{"review_schedule": {"next_review": "2020-01-01"}}`)
+
+	gaps := decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/analysis/gaps", ""))["gaps"].([]interface{})
+	for _, gap := range gaps {
+		if gap := gap.(map[string]interface{}); gap["path"] == "/review_schedule/next_review" && gap["section"] == "meta" {
+			return
+		}
+	}
+	t.Errorf("gaps = %v, want the passed review schedule", gaps)
+}
+
+func TestImportStoresMeta(t *testing.T) {
+	s := newTestServer(t)
+	source := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+source+"/meta", `{"review_schedule": {"review_frequency": "annual"}}`)
+	export := exportProfile(t, s, source)
+
+	target := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+target+"/meta", `{"notes": "Kept"}`)
+
+	conflicts := decode(t, mustDo(t, s, http.StatusConflict, "POST", "/api/profiles/"+target+"/import", export))["conflicts"].([]interface{})
+	if len(conflicts) != 1 || conflicts[0] != "meta" {
+		t.Errorf("conflicts = %v, want [meta]", conflicts)
+	}
+
+	mustDo(t, s, http.StatusOK, "POST", "/api/profiles/"+target+"/import?on_conflict=replace", export)
+	meta := decode(t, mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+target+"/meta", ""))
+	if meta["profile_id"] != target || meta["notes"] != nil {
+		t.Errorf("meta = %v, want the imported metadata on the target profile", meta)
+	}
+	if schedule, _ := meta["review_schedule"].(map[string]interface{}); schedule["review_frequency"] != "annual" {
+		t.Errorf("review_schedule = %v, want the imported schedule", meta["review_schedule"])
+	}
+}
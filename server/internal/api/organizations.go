@@ -0,0 +1,484 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const maxSlugLength = 63
+
+// slugify derives a URL-friendly slug from an organization name.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimSuffix(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+func validSlug(slug string) bool {
+	return len(slug) <= maxSlugLength && slugPattern.MatchString(slug)
+}
+
+func (s *Server) handleOrganizations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s.listOrganizations(w, r)
+	case "POST":
+		s.createOrganization(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleOrganizationRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/organizations/")
+	parts := strings.Split(path, "/")
+
+	if len(parts) == 0 || parts[0] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	org, err := s.db.GetOrganizationBySlug(parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	role := ""
+	if org != nil {
+		role, err = s.organizationRole(r, org.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Non-members cannot tell an organization they may not see from one that
+	// does not exist.
+	if role == "" {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, db.OrganizationRole{Organization: *org, Role: role})
+		case "PATCH":
+			s.updateOrganization(w, r, org, role)
+		case "DELETE":
+			s.deleteOrganization(w, r, org, role)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch parts[1] {
+	case "members":
+		s.handleMembers(w, r, org, role, parts[2:])
+	case "api-keys":
+		s.handleAPIKeys(w, r, org, role, parts[2:])
+	case "profile":
+		if len(parts) == 2 && r.Method == "DELETE" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if len(parts) == 3 && parts[2] == "completeness" {
+			s.getCompleteness(w, r, org.ProfileID)
+			return
+		}
+		s.routeProfile(w, r, append([]string{org.ProfileID}, parts[2:]...))
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) listOrganizations(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+
+	var orgs []db.OrganizationRole
+	var err error
+	if p.admin || p.apiKey != nil {
+		orgs, err = s.db.ListOrganizations()
+	} else {
+		orgs, err = s.db.ListUserOrganizations(p.userID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if p.apiKey != nil {
+		scoped := []db.OrganizationRole{}
+		for _, org := range orgs {
+			if org.ID == p.apiKey.OrganizationID {
+				org.Role = permissionRole(p.apiKey.Permission)
+				scoped = append(scoped, org)
+			}
+		}
+		orgs = scoped
+	}
+
+	writeJSON(w, orgs)
+}
+
+func (s *Server) createOrganization(w http.ResponseWriter, r *http.Request) {
+	if principalFrom(r).apiKey != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Slug == "" {
+		req.Slug = slugify(req.Name)
+	}
+	if !validSlug(req.Slug) {
+		http.Error(w, "Invalid slug: use lowercase letters, digits and hyphens", http.StatusBadRequest)
+		return
+	}
+
+	org, err := s.db.CreateOrganization(req.Name, req.Slug)
+	if errors.Is(err, db.ErrSlugTaken) {
+		http.Error(w, "Slug already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Users own the organizations they create. Administrators already reach
+	// every organization and add members explicitly.
+	role := db.RoleOwner
+	if p := principalFrom(r); !p.admin {
+		if _, err := s.db.AddMember(org.ID, p.userID, db.RoleOwner); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSONStatus(w, http.StatusCreated, db.OrganizationRole{Organization: *org, Role: role})
+}
+
+func (s *Server) updateOrganization(w http.ResponseWriter, r *http.Request, org *db.Organization, role string) {
+	if !hasRole(role, db.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Name *string `json:"name"`
+		Slug *string `json:"slug"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+		org.Name = *req.Name
+	}
+	if req.Slug != nil {
+		if !validSlug(*req.Slug) {
+			http.Error(w, "Invalid slug: use lowercase letters, digits and hyphens", http.StatusBadRequest)
+			return
+		}
+		org.Slug = *req.Slug
+	}
+
+	err := s.db.UpdateOrganization(org.ID, org.Name, org.Slug)
+	if errors.Is(err, db.ErrSlugTaken) {
+		http.Error(w, "Slug already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := s.db.GetOrganizationBySlug(org.Slug)
+	if err != nil || updated == nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, db.OrganizationRole{Organization: *updated, Role: role})
+}
+
+func (s *Server) deleteOrganization(w http.ResponseWriter, r *http.Request, org *db.Organization, role string) {
+	if !hasRole(role, db.RoleOwner) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.db.DeleteOrganization(org.ID); err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getCompleteness(w http.ResponseWriter, r *http.Request, profileID string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, s.validator.ProfileCompleteness(profile.Sections(), s.completenessWeights))
+}
+
+func (s *Server) handleMembers(w http.ResponseWriter, r *http.Request, org *db.Organization, role string, parts []string) {
+	if len(parts) == 0 || parts[0] == "" {
+		switch r.Method {
+		case "GET":
+			members, err := s.db.ListMembers(org.ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, members)
+		case "POST":
+			s.addMember(w, r, org, role)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(parts) != 1 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "PATCH":
+		s.updateMember(w, r, org, role, parts[0])
+	case "DELETE":
+		s.removeMember(w, r, org, role, parts[0])
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// addMember adds an existing user by ID, or a user by email address,
+// creating the account if it does not exist yet.
+func (s *Server) addMember(w http.ResponseWriter, r *http.Request, org *db.Organization, role string) {
+	if !hasRole(role, db.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
+		Name   string `json:"name"`
+		Role   string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !db.ValidRoles[req.Role] {
+		http.Error(w, "Invalid role: must be owner, admin, editor or viewer", http.StatusBadRequest)
+		return
+	}
+	if req.Role == db.RoleOwner && role != db.RoleOwner {
+		http.Error(w, "Only owners can add owners", http.StatusForbidden)
+		return
+	}
+
+	var user *db.User
+	var err error
+	switch {
+	case req.UserID != "":
+		user, err = s.db.GetUser(req.UserID)
+		if err == nil && user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+	case req.Email != "":
+		user, err = s.db.GetUserByEmail(req.Email)
+		if err == nil && user == nil {
+			user, err = s.db.CreateUser(req.Email, req.Name)
+		}
+	default:
+		http.Error(w, "user_id or email is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	membership, err := s.db.AddMember(org.ID, user.ID, req.Role)
+	if errors.Is(err, db.ErrAlreadyMember) {
+		http.Error(w, "User is already a member", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, db.Member{User: *user, Role: membership.Role, JoinedAt: membership.CreatedAt})
+}
+
+func (s *Server) updateMember(w http.ResponseWriter, r *http.Request, org *db.Organization, role, userID string) {
+	if !hasRole(role, db.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !db.ValidRoles[req.Role] {
+		http.Error(w, "Invalid role: must be owner, admin, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	membership, err := s.db.GetMembership(org.ID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if membership == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if (req.Role == db.RoleOwner || membership.Role == db.RoleOwner) && role != db.RoleOwner {
+		http.Error(w, "Only owners can grant or change the owner role", http.StatusForbidden)
+		return
+	}
+	if !s.keepsAnOwner(w, org.ID, membership, req.Role) {
+		return
+	}
+
+	if err := s.db.UpdateMemberRole(org.ID, userID, req.Role); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	membership.Role = req.Role
+	writeJSON(w, membership)
+}
+
+// removeMember lets admins remove members and anyone leave on their own.
+func (s *Server) removeMember(w http.ResponseWriter, r *http.Request, org *db.Organization, role, userID string) {
+	self := principalFrom(r).userID == userID
+	if !self && !hasRole(role, db.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	membership, err := s.db.GetMembership(org.ID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if membership == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if membership.Role == db.RoleOwner && !self && role != db.RoleOwner {
+		http.Error(w, "Only owners can remove owners", http.StatusForbidden)
+		return
+	}
+	if !s.keepsAnOwner(w, org.ID, membership, "") {
+		return
+	}
+
+	if err := s.db.RemoveMember(org.ID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keepsAnOwner refuses to demote or remove the last owner of an
+// organization, which would leave nobody able to manage it.
+func (s *Server) keepsAnOwner(w http.ResponseWriter, organizationID string, membership *db.Membership, newRole string) bool {
+	if membership.Role != db.RoleOwner || newRole == db.RoleOwner {
+		return true
+	}
+
+	owners, err := s.db.CountOwners(organizationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if owners <= 1 {
+		http.Error(w, "Organization must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
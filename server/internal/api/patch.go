@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// patchSection applies an RFC 7396 merge patch or an RFC 6902 JSON Patch to
+// the stored section. The patched document is validated as a whole before it
+// replaces the section, exactly as a PUT would be.
+func (s *Server) patchSection(w http.ResponseWriter, r *http.Request, profileID, section string) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != contentTypeMergePatch && mediaType != contentTypeJSONPatch) {
+		w.Header().Set("Accept-Patch", contentTypeMergePatch+", "+contentTypeJSONPatch)
+		http.Error(w, "Unsupported patch format", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	// A patch is computed against the version read here, so the write must
+	// not land on top of a concurrent change even without If-Match.
+	if _, ok := s.checkIfMatch(w, r, profile, section); !ok {
+		return
+	}
+	expectedVersion := profile.SectionVersions[section]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Callers who cannot see sensitive fields patch the redacted view, so a
+	// JSON Patch test cannot probe the hidden values.
+	original := []byte("{}")
+	if current := s.maskSection(r, section, profile.Section(section)); current != nil {
+		original = []byte(*current)
+	}
+
+	patched, status, err := applyPatch(mediaType, original, body)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	s.saveSection(w, r, profile, section, string(patched), expectedVersion)
+}
+
+// applyPatch applies a patch document of the given media type. On failure it
+// returns the HTTP status that best describes the problem: 400 for a
+// malformed patch, 409 when a JSON Patch test operation fails and 422 when
+// the patch cannot be applied to the current document.
+func applyPatch(mediaType string, original, patch []byte) ([]byte, int, error) {
+	if mediaType == contentTypeMergePatch {
+		patched, err := jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("Invalid merge patch")
+		}
+		return patched, http.StatusOK, nil
+	}
+
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("Invalid JSON Patch")
+	}
+
+	patched, err := operations.Apply(original)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, http.StatusConflict, errors.New("JSON Patch test failed")
+	}
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, errors.New("Cannot apply JSON Patch: " + err.Error())
+	}
+
+	return patched, http.StatusOK, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	original := `{"assets": [{"asset_id": "a1", "name": "Laptop", "value": "low"}], "notes": "x"}`
+
+	tests := []struct {
+		name      string
+		mediaType string
+		patch     string
+		status    int
+		want      string
+	}{
+		{
+			name:      "merge patch",
+			mediaType: contentTypeMergePatch,
+			patch:     `{"notes": null, "owner": "Ana"}`,
+			status:    http.StatusOK,
+			want:      `{"assets": [{"asset_id": "a1", "name": "Laptop", "value": "low"}], "owner": "Ana"}`,
+		},
+		{
+			name:      "invalid merge patch",
+			mediaType: contentTypeMergePatch,
+			patch:     `{`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "JSON Patch",
+			mediaType: contentTypeJSONPatch,
+			patch:     `[{"op": "test", "path": "/assets/0/value", "value": "low"}, {"op": "replace", "path": "/assets/0/value", "value": "high"}]`,
+			status:    http.StatusOK,
+			want:      `{"assets": [{"asset_id": "a1", "name": "Laptop", "value": "high"}], "notes": "x"}`,
+		},
+		{
+			name:      "malformed JSON Patch",
+			mediaType: contentTypeJSONPatch,
+			patch:     `{"op": "add"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "failed test operation",
+			mediaType: contentTypeJSONPatch,
+			patch:     `[{"op": "test", "path": "/assets/0/value", "value": "high"}]`,
+			status:    http.StatusConflict,
+		},
+		{
+			name:      "path that does not exist",
+			mediaType: contentTypeJSONPatch,
+			patch:     `[{"op": "replace", "path": "/assets/5/value", "value": "high"}]`,
+			status:    http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "unknown operation",
+			mediaType: contentTypeJSONPatch,
+			patch:     `[{"op": "rename", "path": "/notes"}]`,
+			status:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, status, err := applyPatch(tt.mediaType, []byte(original), []byte(tt.patch))
+			if status != tt.status {
+				t.Fatalf("status = %d (%v), want %d", status, err, tt.status)
+			}
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("applyPatch succeeded with %s", patched)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameJSON(t, string(patched), tt.want) {
+				t.Errorf("patched = %s, want %s", patched, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchSection(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+	}{
+		{name: "merge patch", contentType: contentTypeMergePatch, patch: `{"assets": [{"asset_id": "asset-a1", "name": "Donors", "category": "donor_supporter_data", "value": "low"}]}`, status: http.StatusOK},
+		{name: "JSON Patch", contentType: contentTypeJSONPatch, patch: `[{"op": "replace", "path": "/assets/0/value", "value": "low"}]`, status: http.StatusOK},
+		{name: "plain JSON", contentType: "application/json", patch: `{}`, status: http.StatusUnsupportedMediaType},
+		{name: "failed test operation", contentType: contentTypeJSONPatch, patch: `[{"op": "test", "path": "/assets/0/value", "value": "low"}]`, status: http.StatusConflict},
+		{name: "result fails validation", contentType: contentTypeJSONPatch, patch: `[{"op": "replace", "path": "/assets/0/value", "value": "enormous"}]`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			id := createProfile(t, s)
+			mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets)
+
+			w := mustDo(t, s, tt.status, "PATCH", "/api/profiles/"+id+"/assets", tt.patch, "Content-Type", tt.contentType)
+			if tt.status == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Patch") == "" {
+				t.Error("415 response lacks Accept-Patch")
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			data := decode(t, w)["data"].(map[string]interface{})
+			asset := data["assets"].([]interface{})[0].(map[string]interface{})
+			if asset["value"] != "low" || asset["asset_id"] != "asset-a1" {
+				t.Errorf("asset = %v, want the patched value", asset)
+			}
+		})
+	}
+}
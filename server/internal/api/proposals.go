@@ -0,0 +1,457 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/jsondiff"
+)
+
+// canPropose reports whether the caller may submit proposals: editors and
+// above, who could also write directly, and read-propose API keys.
+func (s *Server) canPropose(r *http.Request) bool {
+	if key := principalFrom(r).apiKey; key != nil && key.Permission == db.PermissionReadPropose {
+		return true
+	}
+	return hasRole(profileRoleFrom(r), db.RoleEditor)
+}
+
+// handleProposals serves /proposals, the batch /proposals/accept and
+// /proposals/reject, and /proposals/:id with its accept and reject actions.
+func (s *Server) handleProposals(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	if len(parts) > 2 || len(parts) == 2 && parts[1] != "accept" && parts[1] != "reject" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 0 || parts[0] == "" {
+		switch r.Method {
+		case "GET":
+			s.listProposals(w, r, profile)
+		case "POST":
+			s.createProposal(w, r, profile)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(parts) == 1 && parts[0] != "accept" && parts[0] != "reject" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		proposal, err := s.db.GetProposal(profileID, parts[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if proposal == nil {
+			http.Error(w, "Proposal not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, s.proposalView(r, profile, proposal))
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		IDs    []string `json:"ids"`
+		Reason *string  `json:"reason"`
+		Rebase bool     `json:"rebase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	action := parts[0]
+	if len(parts) == 2 {
+		req.IDs = []string{parts[0]}
+		action = parts[1]
+	}
+	if len(req.IDs) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+
+	proposals, ok := s.pendingProposals(w, profileID, req.IDs)
+	if !ok {
+		return
+	}
+
+	if action == "accept" {
+		s.acceptProposals(w, r, profile, proposals, req.Rebase)
+	} else {
+		s.rejectProposals(w, r, profile, proposals, req.Reason)
+	}
+}
+
+// proposalView is a proposal as the API returns it. Pending proposals carry
+// the diff they would make to the section as it is now; stale ones were
+// written against an earlier section version.
+type proposalView struct {
+	*db.Proposal
+	Stale bool              `json:"stale"`
+	Diff  []jsondiff.Change `json:"diff"`
+	Error string            `json:"error,omitempty"`
+}
+
+func (s *Server) proposalView(r *http.Request, profile *db.Profile, proposal *db.Proposal) proposalView {
+	view := proposalView{Proposal: proposal}
+	if proposal.Status != db.ProposalPending {
+		return view
+	}
+
+	view.Stale = proposal.BaseVersion != profile.SectionVersions[proposal.Section]
+
+	current := profile.Section(proposal.Section)
+	patched, _, err := s.applyProposal(r, proposal, current)
+	if err != nil {
+		view.Error = err.Error()
+		return view
+	}
+	view.Diff = jsondiff.Diff(parseJSON(s.maskSection(r, proposal.Section, current)), parseJSON(s.maskSection(r, proposal.Section, &patched)))
+	return view
+}
+
+// applyProposal applies a proposal's patch to a section value. Proposers
+// who could not see sensitive fields wrote their patch against the redacted
+// view, so it is applied to that view and the hidden values are put back.
+func (s *Server) applyProposal(r *http.Request, proposal *db.Proposal, stored *string) (string, int, error) {
+	proposer := withProfileRole(r, proposal.ProposerRole)
+
+	original := []byte("{}")
+	if view := s.maskSection(proposer, proposal.Section, stored); view != nil {
+		original = []byte(*view)
+	}
+
+	patched, status, err := applyPatch(contentTypeJSONPatch, original, proposal.Patch)
+	if err != nil {
+		return "", status, err
+	}
+	return s.unredact(proposer, proposal.Section, stored, string(patched)), http.StatusOK, nil
+}
+
+// listProposals accepts ?status= (pending by default, or all) and ?section=.
+func (s *Server) listProposals(w http.ResponseWriter, r *http.Request, profile *db.Profile) {
+	query := r.URL.Query()
+	filter := db.ProposalFilter{Status: query.Get("status"), Section: query.Get("section")}
+
+	switch {
+	case filter.Status == "":
+		filter.Status = db.ProposalPending
+	case filter.Status == "all":
+		filter.Status = ""
+	case !db.ValidProposalStatuses[filter.Status]:
+		http.Error(w, "Invalid status: must be pending, accepted, rejected or all", http.StatusBadRequest)
+		return
+	}
+
+	if filter.Section != "" && !catalog.IsValidSection(filter.Section) {
+		http.Error(w, "Invalid section", http.StatusBadRequest)
+		return
+	}
+
+	proposals, err := s.db.ListProposals(profile.ID, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := []proposalView{}
+	for i := range proposals {
+		views = append(views, s.proposalView(r, profile, &proposals[i]))
+	}
+
+	writeJSON(w, views)
+}
+
+// createProposal records a JSON Patch against a section for review. The
+// patch must apply to the section as it is now and the result must pass
+// schema validation, so reviewers only see changes that could be saved.
+func (s *Server) createProposal(w http.ResponseWriter, r *http.Request, profile *db.Profile) {
+	if !s.canPropose(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Section   string          `json:"section"`
+		Patch     json.RawMessage `json:"patch"`
+		Rationale string          `json:"rationale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !catalog.IsValidSection(req.Section) {
+		http.Error(w, "Invalid section", http.StatusBadRequest)
+		return
+	}
+	if req.Rationale == "" {
+		http.Error(w, "Rationale is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Patch) == 0 {
+		http.Error(w, "Patch is required", http.StatusBadRequest)
+		return
+	}
+
+	p := principalFrom(r)
+	proposal := &db.Proposal{
+		Section:      req.Section,
+		Patch:        req.Patch,
+		Rationale:    req.Rationale,
+		BaseVersion:  profile.SectionVersions[req.Section],
+		Source:       requestSource(r),
+		ProposedBy:   p.userID,
+		ProposerRole: profileRoleFrom(r),
+	}
+	if p.apiKey != nil {
+		proposal.APIKeyID = p.apiKey.ID
+	}
+
+	patched, status, err := s.applyProposal(r, proposal, profile.Section(req.Section))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if s.validator.HasSchema(req.Section) {
+		validationErrors, err := s.validator.Validate(req.Section, patched)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(validationErrors) > 0 {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "Validation failed",
+				"errors": validationErrors,
+			})
+			return
+		}
+	}
+
+	if err := s.db.CreateProposal(profile.ID, proposal); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, s.proposalView(r, profile, proposal))
+}
+
+// pendingProposals loads the named proposals in the order they were made,
+// refusing unknown IDs and proposals that are no longer pending.
+func (s *Server) pendingProposals(w http.ResponseWriter, profileID string, ids []string) ([]db.Proposal, bool) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	proposals, err := s.db.GetProposals(profileID, unique)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	found := make(map[string]bool)
+	for _, proposal := range proposals {
+		found[proposal.ID] = true
+		if proposal.Status != db.ProposalPending {
+			http.Error(w, "Proposal already "+proposal.Status+": "+proposal.ID, http.StatusConflict)
+			return nil, false
+		}
+	}
+	for _, id := range unique {
+		if !found[id] {
+			http.Error(w, "Proposal not found: "+id, http.StatusNotFound)
+			return nil, false
+		}
+	}
+
+	return proposals, true
+}
+
+// acceptProposals applies proposals in the order they were made, then
+// validates and derives every section they touch, rescoring the stored
+// risks that depend on them, and writes them together with the proposals'
+// new status. The whole batch is refused if any patch
+// fails. A proposal written against an older section version is refused
+// unless rebase is set, in which case its patch is applied to the section
+// as it is now.
+func (s *Server) acceptProposals(w http.ResponseWriter, r *http.Request, profile *db.Profile, proposals []db.Proposal, rebase bool) {
+	ids := make([]string, 0, len(proposals))
+	working := profile.Sections()
+	touched := make(map[string]bool)
+
+	for i := range proposals {
+		proposal := &proposals[i]
+		ids = append(ids, proposal.ID)
+
+		current := profile.SectionVersions[proposal.Section]
+		if proposal.BaseVersion != current && !rebase {
+			writeJSONStatus(w, http.StatusConflict, map[string]interface{}{
+				"error":           "The section has changed since the proposal was made",
+				"proposal_id":     proposal.ID,
+				"section":         proposal.Section,
+				"base_version":    proposal.BaseVersion,
+				"current_version": current,
+			})
+			return
+		}
+
+		patched, status, err := s.applyProposal(r, proposal, working[proposal.Section])
+		if err != nil {
+			writeJSONStatus(w, status, map[string]interface{}{
+				"error":       err.Error(),
+				"proposal_id": proposal.ID,
+			})
+			return
+		}
+		working[proposal.Section] = &patched
+		touched[proposal.Section] = true
+	}
+
+	names := make([]string, 0, len(touched))
+	for name := range touched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := make(map[string]*string, len(names))
+	for _, name := range names {
+		if s.validator.HasSchema(name) {
+			validationErrors, err := s.validator.Validate(name, *working[name])
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(validationErrors) > 0 {
+				writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+					"error":   "Validation failed",
+					"section": name,
+					"errors":  validationErrors,
+				})
+				return
+			}
+		}
+		changed[name] = working[name]
+	}
+
+	// Accepted assets or threats rescore the stored risks, which are written
+	// in the same transaction.
+	derived, err := s.deriveSections(profile, changed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.rejectScoreMismatch && len(derived.scoreCorrections) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Risk scores do not match the computed values",
+			"score_corrections": derived.scoreCorrections,
+		})
+		return
+	}
+
+	warnings := s.introducedReferences(profile, derived.merged)
+	if s.integrityMode == integrityModeError && len(warnings) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": warnings,
+		})
+		return
+	}
+
+	writes, err := s.db.AcceptProposals(profile.ID, ids, derived.sections, expectedVersions(profile, derived.sections), db.SourceProposal, principalFrom(r).userID)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		writeJSONStatus(w, http.StatusConflict, map[string]interface{}{
+			"error":           "The section has changed since the proposal was made",
+			"section":         conflict.Section,
+			"current_version": conflict.Current,
+		})
+		return
+	}
+	if errors.Is(err, db.ErrProposalResolved) {
+		http.Error(w, "Proposal is no longer pending", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"accepted": ids,
+		"sections": names,
+		"version":  writes.Version(),
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	if len(derived.scoreCorrections) > 0 {
+		response["score_corrections"] = derived.scoreCorrections
+	}
+	if len(derived.cascaded) > 0 {
+		rescored := make(map[string]int, len(derived.cascaded))
+		for _, name := range derived.cascaded {
+			rescored[name] = writes[name].SectionVersion
+		}
+		response["rescored_sections"] = rescored
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) rejectProposals(w http.ResponseWriter, r *http.Request, profile *db.Profile, proposals []db.Proposal, reason *string) {
+	ids := make([]string, 0, len(proposals))
+	for _, proposal := range proposals {
+		ids = append(ids, proposal.ID)
+	}
+
+	if reason == nil || *reason == "" {
+		http.Error(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+
+	err := s.db.RejectProposals(profile.ID, ids, reason, principalFrom(r).userID)
+	if errors.Is(err, db.ErrProposalResolved) {
+		http.Error(w, "Proposal is no longer pending", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":  true,
+		"rejected": ids,
+	})
+}
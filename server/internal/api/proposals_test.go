@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func TestCanPropose(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *principal
+		role      string
+		want      bool
+	}{
+		{name: "administrator", principal: &principal{admin: true}, role: db.RoleOwner, want: true},
+		{name: "owner", principal: &principal{userID: "u"}, role: db.RoleOwner, want: true},
+		{name: "editor", principal: &principal{userID: "u"}, role: db.RoleEditor, want: true},
+		{name: "viewer", principal: &principal{userID: "u"}, role: db.RoleViewer, want: false},
+		{name: "read key", principal: &principal{apiKey: &db.APIKey{Permission: db.PermissionRead}}, role: db.RoleViewer, want: false},
+		{name: "read-propose key", principal: &principal{apiKey: &db.APIKey{Permission: db.PermissionReadPropose}}, role: db.RoleViewer, want: true},
+		{name: "read-write key", principal: &principal{apiKey: &db.APIKey{Permission: db.PermissionReadWrite}}, role: db.RoleEditor, want: true},
+		{name: "no role", principal: &principal{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			r := withProfileRole(withPrincipal(httptest.NewRequest("POST", "/", nil), tt.principal), tt.role)
+			if got := s.canPropose(r); got != tt.want {
+				t.Errorf("canPropose = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProposalPermissions(t *testing.T) {
+	s := newTestServer(t)
+	org := createOrganization(t, s, "acme")
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+org.ProfileID+"/assets", testAssets)
+
+	proposal := `{"section": "assets", "rationale": "Raise the value",
+		"patch": [{"op": "replace", "path": "/assets/0/value", "value": "high"}]}`
+
+	tests := []struct {
+		name       string
+		permission string
+		propose    int
+		accept     int
+	}{
+		{name: "read key", permission: db.PermissionRead, propose: http.StatusForbidden},
+		{name: "read-propose key", permission: db.PermissionReadPropose, propose: http.StatusCreated, accept: http.StatusForbidden},
+		{name: "read-write key", permission: db.PermissionReadWrite, propose: http.StatusCreated, accept: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := "Bearer " + newAPIKey(t, s, org.ID, tt.permission)
+			w := mustDo(t, s, tt.propose, "POST", "/api/profiles/"+org.ProfileID+"/proposals", proposal, "Authorization", auth)
+			if tt.propose != http.StatusCreated {
+				return
+			}
+
+			id := decode(t, w)["id"].(string)
+			mustDo(t, s, tt.accept, "POST", "/api/profiles/"+org.ProfileID+"/proposals/"+id+"/accept", "", "Authorization", auth)
+		})
+	}
+}
+
+func TestAcceptingAssetsProposalRescoresRisks(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+
+	proposal := decode(t, mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+id+"/proposals", `{
+		"section": "assets", "rationale": "The donor list is critical",
+		"patch": [{"op": "replace", "path": "/assets/0/value", "value": "critical"}]}`))
+
+	response := decode(t, mustDo(t, s, http.StatusOK, "POST", "/api/profiles/"+id+"/proposals/"+proposal["id"].(string)+"/accept", ""))
+	rescored, _ := response["rescored_sections"].(map[string]interface{})
+	if rescored["risks"] != 2.0 {
+		t.Errorf("rescored_sections = %v, want risks at version 2", response["rescored_sections"])
+	}
+
+	// critical (3) × high (3) × vulnerability 1 = 9.
+	if risk, _ := getRisk(t, s, id, "risk-r1"); risk["risk_score"] != 9.0 {
+		t.Errorf("risk_score = %v, want 9", risk["risk_score"])
+	}
+}
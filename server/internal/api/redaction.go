@@ -0,0 +1,370 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+// redactedValue replaces sensitive values for callers below the policy's
+// role. Writes that send it back keep the stored value.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactionPaths lists the fields that identify people at risk.
+// Paths are JSON Pointers into the section, with "*" matching any array
+// index or object key.
+var defaultRedactionPaths = map[string][]string{
+	"assets": {"/assets/*/who_has_access"},
+	"opsec":  {"/human_sources", "/personal_safety/staff_assessed"},
+}
+
+// redactionPolicy marks section paths as sensitive. Masking happens where
+// section data is loaded for a response: read handlers get profiles from
+// viewProfile and history from viewHistoryEntry and viewSectionAt, which
+// only ever return what the caller may see. Write paths work on the stored
+// profile, pass anything they echo back through maskSection, and put masked
+// values back with unredact.
+type redactionPolicy struct {
+	paths map[string][][]string
+	role  string
+}
+
+func newRedactionPolicy(paths map[string][]string, role string) (redactionPolicy, error) {
+	policy := redactionPolicy{paths: make(map[string][][]string), role: role}
+	if !db.ValidRoles[role] {
+		return policy, fmt.Errorf("unknown role %q", role)
+	}
+
+	for section, pointers := range paths {
+		if !catalog.IsValidSection(section) {
+			return policy, fmt.Errorf("unknown section %q", section)
+		}
+		for _, pointer := range pointers {
+			if !strings.HasPrefix(pointer, "/") || pointer == "/" {
+				return policy, fmt.Errorf("invalid path %q", pointer)
+			}
+			policy.paths[section] = append(policy.paths[section], splitPointer(pointer))
+		}
+	}
+	return policy, nil
+}
+
+// redactionPolicyFromEnv loads the sensitive paths from the JSON file named
+// by ARMOR_REDACTION_POLICY ({"section": ["/pointer", ...]}), replacing the
+// defaults, and the least role allowed to see them from
+// ARMOR_REDACTION_ROLE.
+func redactionPolicyFromEnv() redactionPolicy {
+	role := os.Getenv("ARMOR_REDACTION_ROLE")
+	if role == "" {
+		role = db.RoleEditor
+	}
+	paths := defaultRedactionPaths
+
+	if file := os.Getenv("ARMOR_REDACTION_POLICY"); file != "" {
+		var custom map[string][]string
+		contents, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(contents, &custom)
+		}
+		if err != nil {
+			log.Printf("Warning: invalid ARMOR_REDACTION_POLICY (%v), using defaults", err)
+		} else {
+			paths = custom
+		}
+	}
+
+	policy, err := newRedactionPolicy(paths, role)
+	if err != nil {
+		log.Printf("Warning: invalid redaction policy (%v), using defaults", err)
+		policy, _ = newRedactionPolicy(defaultRedactionPaths, db.RoleEditor)
+	}
+	return policy
+}
+
+type profileRoleKey struct{}
+
+// withProfileRole records the caller's role for the profile being served,
+// as established by authorizeProfile.
+func withProfileRole(r *http.Request, role string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), profileRoleKey{}, role))
+}
+
+func profileRoleFrom(r *http.Request) string {
+	role, _ := r.Context().Value(profileRoleKey{}).(string)
+	return role
+}
+
+func (s *Server) canSeeSensitive(r *http.Request) bool {
+	return hasRole(profileRoleFrom(r), s.redaction.role)
+}
+
+// viewProfile loads a profile as the caller may see it, with sensitive
+// values masked. It returns nil when the profile does not exist.
+func (s *Server) viewProfile(r *http.Request, profileID string) (*db.Profile, error) {
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil || profile == nil {
+		return nil, err
+	}
+
+	if s.canSeeSensitive(r) {
+		return profile, nil
+	}
+	view := *profile
+	for name, data := range profile.Sections() {
+		view.SetSection(name, s.maskSection(r, name, data))
+	}
+	return &view, nil
+}
+
+// viewHistoryEntry loads a history entry with both values masked for the
+// caller. It returns nil when there is no such version.
+func (s *Server) viewHistoryEntry(r *http.Request, profileID string, version int) (*db.HistoryEntry, error) {
+	entry, err := s.db.GetHistoryEntry(profileID, version)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	entry.PreviousValue = s.maskSection(r, entry.Section, entry.PreviousValue)
+	entry.NewValue = s.maskSection(r, entry.Section, entry.NewValue)
+	return entry, nil
+}
+
+// viewSectionAt loads a section as it was at a profile version, masked for
+// the caller.
+func (s *Server) viewSectionAt(r *http.Request, profileID, section string, version int) (*string, error) {
+	data, err := s.db.GetSectionAtVersion(profileID, section, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.maskSection(r, section, data), nil
+}
+
+// maskSection returns section data with the values the caller may not see
+// replaced by redactedValue. Data that needs no masking is returned as is.
+func (s *Server) maskSection(r *http.Request, section string, data *string) *string {
+	patterns := s.redaction.paths[section]
+	if data == nil || len(patterns) == 0 || s.canSeeSensitive(r) {
+		return data
+	}
+
+	doc := parseJSON(data)
+	for _, pattern := range patterns {
+		matchPointer(doc, pattern, nil, func(container interface{}, key string, _ []string) {
+			setChild(container, key, redactedValue)
+		})
+	}
+
+	masked, err := json.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+	value := string(masked)
+	return &value
+}
+
+// unredact puts stored values back wherever a caller who cannot see them
+// submitted the mask, so editing a redacted section does not wipe them.
+func (s *Server) unredact(r *http.Request, section string, stored *string, submitted string) string {
+	patterns := s.redaction.paths[section]
+	if len(patterns) == 0 || s.canSeeSensitive(r) {
+		return submitted
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(submitted), &doc); err != nil {
+		return submitted
+	}
+	original := parseJSON(stored)
+
+	changed := false
+	for _, pattern := range patterns {
+		matchPointer(doc, pattern, nil, func(container interface{}, key string, path []string) {
+			if child, _ := getChild(container, key); child != redactedValue {
+				return
+			}
+			changed = true
+			if value, ok := counterpart(original, doc, path); ok {
+				setChild(container, key, value)
+			} else if m, ok := container.(map[string]interface{}); ok {
+				delete(m, key)
+			}
+		})
+	}
+	if !changed {
+		return submitted
+	}
+
+	restored, err := json.Marshal(doc)
+	if err != nil {
+		return submitted
+	}
+	return string(restored)
+}
+
+// dropRedacted removes masks left in section data, such as those in a
+// redacted export, so they are not stored as if they were real values. It
+// reports whether any were removed.
+func (s *Server) dropRedacted(section, data string) (string, bool) {
+	patterns := s.redaction.paths[section]
+	if len(patterns) == 0 {
+		return data, false
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return data, false
+	}
+
+	dropped := false
+	for _, pattern := range patterns {
+		matchPointer(doc, pattern, nil, func(container interface{}, key string, _ []string) {
+			m, ok := container.(map[string]interface{})
+			if ok && m[key] == redactedValue {
+				delete(m, key)
+				dropped = true
+			}
+		})
+	}
+	if !dropped {
+		return data, false
+	}
+
+	cleaned, err := json.Marshal(doc)
+	if err != nil {
+		return data, false
+	}
+	return string(cleaned), true
+}
+
+func splitPointer(pointer string) []string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+	return segments
+}
+
+// matchPointer calls fn with the container and key of every value in doc
+// matching pattern, along with the concrete path to it.
+func matchPointer(doc interface{}, pattern []string, path []string, fn func(container interface{}, key string, path []string)) {
+	if len(pattern) == 0 {
+		return
+	}
+
+	var keys []string
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if pattern[0] == "*" {
+			for key := range node {
+				keys = append(keys, key)
+			}
+		} else if _, ok := node[pattern[0]]; ok {
+			keys = []string{pattern[0]}
+		}
+	case []interface{}:
+		if pattern[0] == "*" {
+			for i := range node {
+				keys = append(keys, strconv.Itoa(i))
+			}
+		} else if i, err := strconv.Atoi(pattern[0]); err == nil && i >= 0 && i < len(node) {
+			keys = []string{pattern[0]}
+		}
+	default:
+		return
+	}
+
+	for _, key := range keys {
+		childPath := append(append([]string{}, path...), key)
+		if len(pattern) == 1 {
+			fn(doc, key, childPath)
+			continue
+		}
+		child, _ := getChild(doc, key)
+		matchPointer(child, pattern[1:], childPath, fn)
+	}
+}
+
+func getChild(container interface{}, key string) (interface{}, bool) {
+	switch node := container.(type) {
+	case map[string]interface{}:
+		value, ok := node[key]
+		return value, ok
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, false
+		}
+		return node[i], true
+	}
+	return nil, false
+}
+
+func setChild(container interface{}, key string, value interface{}) {
+	switch node := container.(type) {
+	case map[string]interface{}:
+		node[key] = value
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node) {
+			node[i] = value
+		}
+	}
+}
+
+// counterpart finds the value in original at the place path points to in
+// submitted. Array items that carry their ID, such as an asset_id in
+// assets, are matched by it rather than by position, so reordering or
+// inserting items does not hand one item's hidden values to another.
+func counterpart(original, submitted interface{}, path []string) (interface{}, bool) {
+	current, mirror := original, submitted
+	list := ""
+	for _, key := range path {
+		next, _ := getChild(mirror, key)
+		if items, ok := current.([]interface{}); ok {
+			if field := itemIDField(list); field != "" {
+				item, _ := next.(map[string]interface{})
+				if id, ok := item[field].(string); ok {
+					current = nil
+					for _, item := range items {
+						if m, ok := item.(map[string]interface{}); ok && m[field] == id {
+							current = item
+							break
+						}
+					}
+					if current == nil {
+						return nil, false
+					}
+					mirror, list = next, key
+					continue
+				}
+			}
+		}
+
+		child, ok := getChild(current, key)
+		if !ok {
+			return nil, false
+		}
+		current, mirror, list = child, next, key
+	}
+	return current, true
+}
+
+// itemIDField names the ID field of the items in a list, such as asset_id
+// for assets.
+func itemIDField(list string) string {
+	switch {
+	case list == "":
+		return ""
+	case strings.HasSuffix(list, "ies"):
+		return strings.TrimSuffix(list, "ies") + "y_id"
+	default:
+		return strings.TrimSuffix(list, "s") + "_id"
+	}
+}
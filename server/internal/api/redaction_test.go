@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func TestNewRedactionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   map[string][]string
+		role    string
+		wantErr bool
+	}{
+		{name: "defaults", paths: defaultRedactionPaths, role: db.RoleEditor},
+		{name: "unknown role", paths: defaultRedactionPaths, role: "auditor", wantErr: true},
+		{name: "unknown section", paths: map[string][]string{"secrets": {"/x"}}, role: db.RoleEditor, wantErr: true},
+		{name: "relative path", paths: map[string][]string{"assets": {"assets/*/owner"}}, role: db.RoleEditor, wantErr: true},
+		{name: "whole section", paths: map[string][]string{"assets": {"/"}}, role: db.RoleEditor, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRedactionPolicy(tt.paths, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRedactionPolicy error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// requestAs returns a request made with a profile role.
+func requestAs(role string) *http.Request {
+	return withProfileRole(httptest.NewRequest("GET", "/", nil), role)
+}
+
+func TestMaskAndUnredactRoundTrip(t *testing.T) {
+	s := &Server{}
+	var err error
+	s.redaction, err = newRedactionPolicy(map[string][]string{"assets": {"/assets/*/who_has_access", "/assets/*/owner"}}, db.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := `{"assets":[{"asset_id":"a1","name":"Donor list","owner":"Ana","who_has_access":["Ana","Ben"]},{"asset_id":"a2","name":"Site"}]}`
+	viewer, editor := requestAs(db.RoleViewer), requestAs(db.RoleEditor)
+
+	masked := s.maskSection(viewer, "assets", &stored)
+	want := `{"assets":[{"asset_id":"a1","name":"Donor list","owner":"[REDACTED]","who_has_access":"[REDACTED]"},{"asset_id":"a2","name":"Site"}]}`
+	if !sameJSON(t, *masked, want) {
+		t.Fatalf("masked = %s, want %s", *masked, want)
+	}
+	if got := s.maskSection(editor, "assets", &stored); got != &stored {
+		t.Errorf("editor view = %s, want the stored value", *got)
+	}
+	if got := s.maskSection(viewer, "threats", &stored); got != &stored {
+		t.Errorf("section without sensitive paths was rewritten")
+	}
+
+	tests := []struct {
+		name      string
+		r         *http.Request
+		submitted string
+		want      string
+	}{
+		{
+			name:      "view sent back unchanged",
+			r:         viewer,
+			submitted: *masked,
+			want:      stored,
+		},
+		{
+			name:      "other fields edited",
+			r:         viewer,
+			submitted: strings.Replace(*masked, "Donor list", "Donor database", 1),
+			want:      strings.Replace(stored, "Donor list", "Donor database", 1),
+		},
+		{
+			name:      "sensitive value replaced",
+			r:         viewer,
+			submitted: strings.Replace(*masked, `"owner":"[REDACTED]"`, `"owner":"Cleo"`, 1),
+			want:      strings.Replace(stored, `"owner":"Ana"`, `"owner":"Cleo"`, 1),
+		},
+		{
+			name:      "items reordered",
+			r:         viewer,
+			submitted: `{"assets":[{"asset_id":"a2","name":"Site","owner":"[REDACTED]"},{"asset_id":"a1","name":"Donor list","owner":"[REDACTED]","who_has_access":"[REDACTED]"}]}`,
+			want:      `{"assets":[{"asset_id":"a2","name":"Site"},{"asset_id":"a1","name":"Donor list","owner":"Ana","who_has_access":["Ana","Ben"]}]}`,
+		},
+		{
+			name:      "mask on an item with nothing stored",
+			r:         viewer,
+			submitted: `{"assets":[{"asset_id":"a3","name":"New","owner":"[REDACTED]"}]}`,
+			want:      `{"assets":[{"asset_id":"a3","name":"New"}]}`,
+		},
+		{
+			name:      "editors write the mask as is",
+			r:         editor,
+			submitted: *masked,
+			want:      *masked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.unredact(tt.r, "assets", &stored, tt.submitted)
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("unredact = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	cleaned, dropped := s.dropRedacted("assets", *masked)
+	if !dropped || strings.Contains(cleaned, redactedValue) {
+		t.Errorf("dropRedacted = %s, %v, want the masks removed", cleaned, dropped)
+	}
+}
+
+func sameJSON(t *testing.T, a, b string) bool {
+	t.Helper()
+
+	var x, y interface{}
+	if err := json.Unmarshal([]byte(a), &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(b), &y); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// TestReadEndpointsMaskSensitiveValues covers every endpoint that returns
+// section data, for a caller below the redaction role.
+func TestReadEndpointsMaskSensitiveValues(t *testing.T) {
+	s := newTestServer(t)
+	org := createOrganization(t, s, "masked")
+	id := org.ProfileID
+
+	sensitive := `{"assets": [{"asset_id": "asset-a1", "name": "Donor list", "category": "donor_supporter_data",
+		"value": "high", "who_has_access": ["Secret Person"]}]}`
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", `{"assets": []}`)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", sensitive)
+
+	reader := "Bearer " + newAPIKey(t, s, org.ID, db.PermissionRead)
+	writer := "Bearer " + newAPIKey(t, s, org.ID, db.PermissionReadWrite)
+
+	endpoints := []string{
+		"",
+		"/assets",
+		"/export",
+		"/report?format=html",
+		"/registers/assets?format=csv",
+		"/history/2",
+		"/history/diff?from=0",
+		"/analysis/gaps",
+		"/integrity",
+	}
+
+	for _, endpoint := range endpoints {
+		t.Run(endpoint, func(t *testing.T) {
+			w := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+endpoint, "", "Authorization", reader)
+			if strings.Contains(w.Body.String(), "Secret Person") {
+				t.Errorf("viewer response contains the sensitive value: %s", w.Body.String())
+			}
+		})
+	}
+
+	w := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/assets", "", "Authorization", writer)
+	if !strings.Contains(w.Body.String(), "Secret Person") {
+		t.Errorf("editor response lacks the sensitive value: %s", w.Body.String())
+	}
+
+	// A viewer cannot write, but the masked value survives an editor-level
+	// proposal from a read-propose key being accepted.
+	proposer := "Bearer " + newAPIKey(t, s, org.ID, db.PermissionReadPropose)
+	mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+id+"/proposals",
+		`{"section": "assets", "rationale": "Shorter name", "patch": [{"op": "replace", "path": "/assets/0/name", "value": "Donors"}]}`, "Authorization", proposer)
+	proposals := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/proposals", "", "Authorization", proposer)
+	if strings.Contains(proposals.Body.String(), "Secret Person") {
+		t.Errorf("proposal diff contains the sensitive value: %s", proposals.Body.String())
+	}
+	mustDo(t, s, http.StatusOK, "POST", "/api/profiles/"+id+"/proposals/accept", `{"ids": [`+
+		strings.TrimSpace(firstProposalID(t, proposals))+`]}`)
+
+	w = mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/assets", "", "Authorization", writer)
+	if !strings.Contains(w.Body.String(), "Secret Person") || !strings.Contains(w.Body.String(), "Donors") {
+		t.Errorf("assets after the proposal = %s, want the new name and the stored access list", w.Body.String())
+	}
+}
+
+func firstProposalID(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var proposals []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &proposals); err != nil || len(proposals) == 0 {
+		t.Fatalf("proposals = %s", w.Body.String())
+	}
+	id, _ := json.Marshal(proposals[0]["id"])
+	return string(id)
+}
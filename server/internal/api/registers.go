@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/register"
+)
+
+// maxRegisterUpload bounds spreadsheet imports. Registers are at most a few
+// hundred rows.
+const maxRegisterUpload = 10 << 20
+
+// handleRegisters serves /registers/:name as a spreadsheet and
+// /registers/:name/import to load one back.
+func (s *Server) handleRegisters(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	if len(parts) == 0 || len(parts) > 2 || len(parts) == 2 && parts[1] != "import" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	reg, ok := register.Lookup(parts[0])
+	if !ok {
+		http.Error(w, "Unknown register", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.exportRegister(w, r, profileID, reg)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.importRegister(w, r, profileID, reg)
+}
+
+func (s *Server) exportRegister(w http.ResponseWriter, r *http.Request, profileID string, reg register.Register) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = register.FormatCSV
+	}
+	if format != register.FormatCSV && format != register.FormatXLSX {
+		http.Error(w, "Invalid format: must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	fields := s.validator.ItemFields(reg.Section, reg.List)
+	table := reg.Export(profile.Section(reg.Section), fields)
+
+	var buf bytes.Buffer
+	if format == register.FormatXLSX {
+		err = table.WriteXLSX(&buf, reg.Name)
+	} else {
+		err = table.WriteCSV(&buf)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", register.ContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="armor-%s-%s.%s"`, reg.Name, profile.ID, format))
+	w.Write(buf.Bytes())
+}
+
+// importRegister replaces a register with the rows of a CSV or XLSX upload.
+// Every row is converted and validated on its own first, and nothing is
+// saved unless all rows pass. With ?dry_run=true the rows are only checked.
+// The assembled section is then saved like a PUT, with the same validation,
+// derived fields, integrity checks and If-Match handling.
+func (s *Server) importRegister(w http.ResponseWriter, r *http.Request, profileID string, reg register.Register) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		for name, contentType := range register.ContentTypes {
+			if t, _, _ := mime.ParseMediaType(contentType); t == mediaType {
+				format = name
+			}
+		}
+	}
+	if format != register.FormatCSV && format != register.FormatXLSX {
+		http.Error(w, "Unsupported format: send CSV or XLSX, or set ?format=", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	overrides, err := parseColumnOverrides(r.URL.Query().Get("columns"))
+	if err != nil {
+		http.Error(w, "Invalid columns: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	// Rows are merged with the section as read here, so the write must not
+	// land on top of a concurrent change even without If-Match.
+	if _, ok := s.checkIfMatch(w, r, profile, reg.Section); !ok {
+		return
+	}
+	expectedVersion := profile.SectionVersions[reg.Section]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRegisterUpload))
+	if err != nil {
+		http.Error(w, "Upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var records [][]string
+	if format == register.FormatXLSX {
+		records, err = register.ReadXLSX(bytes.NewReader(body), reg.Name)
+	} else {
+		records, err = register.ReadCSV(bytes.NewReader(body))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) == 0 {
+		http.Error(w, "The spreadsheet has no header row", http.StatusBadRequest)
+		return
+	}
+
+	fields := s.validator.ItemFields(reg.Section, reg.List)
+	mapping, err := reg.MapColumns(records[0], fields, overrides)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored := profile.Section(reg.Section)
+	rows := reg.ParseRows(records, mapping, stored, redactedValue)
+
+	var failed []register.Row
+	for i := range rows {
+		row := &rows[i]
+		if len(row.Errors) == 0 {
+			s.validateRow(reg, stored, row)
+		}
+		if len(row.Errors) > 0 {
+			failed = append(failed, *row)
+		}
+	}
+
+	columns := make(map[string]string)
+	for col, f := range mapping.Fields {
+		columns[records[0][col]] = f.Path
+	}
+
+	if len(failed) > 0 || dryRun {
+		status := http.StatusOK
+		if len(failed) > 0 {
+			status = http.StatusBadRequest
+		}
+		if failed == nil {
+			failed = []register.Row{}
+		}
+		writeJSONStatus(w, status, map[string]interface{}{
+			"valid":           len(failed) == 0,
+			"rows":            len(rows),
+			"errors":          failed,
+			"columns":         columns,
+			"ignored_columns": nonNilStrings(mapping.Ignored),
+		})
+		return
+	}
+
+	data, err := reg.Assemble(stored, rows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.saveSection(w, r, profile, reg.Section, data, expectedVersion)
+}
+
+// validateRow checks a row against the section schema on its own and
+// records the errors that belong to it, with paths relative to the item.
+func (s *Server) validateRow(reg register.Register, stored *string, row *register.Row) {
+	doc, pointer, err := reg.RowDocument(stored, *row)
+	if err != nil {
+		row.Errors = append(row.Errors, register.RowError{Message: err.Error()})
+		return
+	}
+
+	errors, err := s.validator.Validate(reg.Section, doc)
+	if err != nil {
+		row.Errors = append(row.Errors, register.RowError{Message: err.Error()})
+		return
+	}
+
+	for _, e := range errors {
+		if e.Path != pointer && !strings.HasPrefix(e.Path, pointer+"/") {
+			continue
+		}
+		path := strings.TrimPrefix(e.Path, pointer)
+		row.Errors = append(row.Errors, register.RowError{
+			Column:  strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "."),
+			Path:    path,
+			Message: e.Message,
+		})
+	}
+}
+
+// parseColumnOverrides reads ?columns=Header=field,Other header=field.
+func parseColumnOverrides(spec string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	if spec == "" {
+		return overrides, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		header, field, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(header) == "" || strings.TrimSpace(field) == "" {
+			return nil, fmt.Errorf("expected header=field, got %q", part)
+		}
+		overrides[strings.TrimSpace(header)] = strings.TrimSpace(field)
+	}
+	return overrides, nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/report"
+)
+
+// reportRendererFromEnv loads the HTML template named by
+// ARMOR_REPORT_TEMPLATE and the brand file named by ARMOR_REPORT_BRAND,
+// falling back to the built-in ones.
+func reportRendererFromEnv() *report.Renderer {
+	brand := report.DefaultBrand
+	if path := os.Getenv("ARMOR_REPORT_BRAND"); path != "" {
+		loaded, err := report.LoadBrand(path)
+		if err != nil {
+			log.Printf("Warning: invalid ARMOR_REPORT_BRAND (%v), using defaults", err)
+		} else {
+			brand = loaded
+		}
+	}
+
+	renderer, err := report.NewRenderer(os.Getenv("ARMOR_REPORT_TEMPLATE"), brand)
+	if err != nil {
+		log.Printf("Warning: invalid ARMOR_REPORT_TEMPLATE (%v), using defaults", err)
+		renderer, err = report.NewRenderer("", brand)
+		if err != nil {
+			log.Fatalf("Failed to load built-in report template: %v", err)
+		}
+	}
+	return renderer
+}
+
+// getReport renders the profile as an HTML or PDF report, chosen with
+// ?format= or the Accept header. Sections are redacted for the caller
+// before they reach the template.
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request, profileID string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+		if strings.Contains(r.Header.Get("Accept"), "application/pdf") {
+			format = "pdf"
+		}
+	}
+	if format != "html" && format != "pdf" {
+		http.Error(w, "Invalid format: must be html or pdf", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.viewProfile(r, profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	// Risks saved before scoring was server-side may lack scores, so the
+	// register is scored the way a save would score it.
+	sections := profile.Sections()
+	if risks := sections["risks"]; risks != nil {
+		if derived, err := s.deriveSection(profile, "risks", *risks); err == nil {
+			sections["risks"] = &derived.data
+		}
+	}
+
+	completeness := s.validator.ProfileCompleteness(profile.Sections(), s.completenessWeights)
+	rep := report.Build(profile.Name, profile.Description, profile.Version, sections, completeness, time.Now().UTC())
+	rep.SchemaVersion = s.validator.SchemaVersion()
+
+	// Render into a buffer so a template error still gets a clean 500.
+	var buf bytes.Buffer
+	if format == "pdf" {
+		err = s.reports.PDF(&buf, rep)
+	} else {
+		err = s.reports.HTML(&buf, rep)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="armor-report-%s.pdf"`, profile.ID))
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Write(buf.Bytes())
+}
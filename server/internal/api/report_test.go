@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+)
+
+func TestReportFormats(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+	path := "/api/profiles/" + id + "/report"
+
+	tests := []struct {
+		name        string
+		query       string
+		headers     []string
+		status      int
+		contentType string
+		prefix      string
+	}{
+		{name: "default", status: http.StatusOK, contentType: "text/html; charset=utf-8", prefix: "<!DOCTYPE html>"},
+		{name: "html", query: "?format=html", status: http.StatusOK, contentType: "text/html; charset=utf-8", prefix: "<!DOCTYPE html>"},
+		{name: "pdf", query: "?format=pdf", status: http.StatusOK, contentType: "application/pdf", prefix: "%PDF"},
+		{name: "pdf by Accept", headers: []string{"Accept", "application/pdf"}, status: http.StatusOK, contentType: "application/pdf", prefix: "%PDF"},
+		{name: "query wins over Accept", query: "?format=html", headers: []string{"Accept", "application/pdf"}, status: http.StatusOK, contentType: "text/html; charset=utf-8", prefix: "<!DOCTYPE html>"},
+		{name: "unknown format", query: "?format=docx", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := mustDo(t, s, tt.status, "GET", path+tt.query, "", tt.headers...)
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %s, want %s", got, tt.contentType)
+			}
+			if !bytes.HasPrefix(bytes.TrimSpace(w.Body.Bytes()), []byte(tt.prefix)) {
+				t.Errorf("body starts %q, want %q", w.Body.String()[:min(len(w.Body.String()), 20)], tt.prefix)
+			}
+		})
+	}
+}
+
+func TestReportSortsRisksByScore(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/assets", testAssets)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", testThreats)
+
+	// medium (2) × high (3) × vulnerability: 6, 18 and 12.
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/risks", `{"risks": [
+		{"risk_id": "risk-low", "scenario": "Scenario scored six", "asset_id": "asset-a1", "threat_id": "threat-t1",
+		 "asset_value_score": 2, "likelihood_score": 3, "vulnerability_score": 1},
+		{"risk_id": "risk-high", "scenario": "Scenario scored eighteen", "asset_id": "asset-a1", "threat_id": "threat-t1",
+		 "asset_value_score": 2, "likelihood_score": 3, "vulnerability_score": 3},
+		{"risk_id": "risk-mid", "scenario": "Scenario scored twelve", "asset_id": "asset-a1", "threat_id": "threat-t1",
+		 "asset_value_score": 2, "likelihood_score": 3, "vulnerability_score": 2}
+	]}`)
+
+	html := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+id+"/report", "").Body.String()
+
+	previous := -1
+	for _, scenario := range []string{"Scenario scored eighteen", "Scenario scored twelve", "Scenario scored six"} {
+		at := strings.Index(html, scenario)
+		if at < 0 {
+			t.Fatalf("report lacks %q", scenario)
+		}
+		if at < previous {
+			t.Errorf("%q comes before a higher-scored risk", scenario)
+		}
+		previous = at
+	}
+}
+
+func TestReportRedactsForViewers(t *testing.T) {
+	s := newTestServer(t)
+	var err error
+	s.redaction, err = newRedactionPolicy(map[string][]string{"assets": {"/assets/*/who_has_access", "/assets/*/owner"}}, db.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	org := createOrganization(t, s, "report")
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+org.ProfileID+"/assets", `{"assets": [
+		{"asset_id": "asset-a1", "name": "Donor list", "category": "donor_supporter_data", "value": "high",
+		 "owner": "Owner Olga", "who_has_access": ["Secret Person"]}]}`)
+
+	tests := []struct {
+		permission string
+		wantOwner  bool
+	}{
+		{permission: db.PermissionRead},
+		{permission: db.PermissionReadPropose},
+		{permission: db.PermissionReadWrite, wantOwner: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			key := "Bearer " + newAPIKey(t, s, org.ID, tt.permission)
+			for _, format := range []string{"html", "pdf"} {
+				body := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+org.ProfileID+"/report?format="+format, "", "Authorization", key).Body.String()
+				if strings.Contains(body, "Secret Person") {
+					t.Errorf("%s report contains who_has_access", format)
+				}
+				if format == "html" && strings.Contains(body, "Owner Olga") != tt.wantOwner {
+					t.Errorf("html report shows the owner = %v, want %v", !tt.wantOwner, tt.wantOwner)
+				}
+			}
+		})
+	}
+}
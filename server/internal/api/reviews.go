@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/review"
+)
+
+// handleReviews serves /reviews, /reviews/:id and the accept and dismiss
+// actions below a review task.
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request, profileID string, parts []string) {
+	if len(parts) == 0 || parts[0] == "" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.listReviews(w, r, profileID)
+		return
+	}
+
+	if len(parts) > 2 || len(parts) == 2 && parts[1] != "accept" && parts[1] != "dismiss" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	wantMethod := "GET"
+	if len(parts) == 2 {
+		wantMethod = "POST"
+	}
+	if r.Method != wantMethod {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, err := s.db.GetReviewTask(profileID, parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if task == nil {
+		http.Error(w, "Review task not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		writeJSON(w, task)
+	case parts[1] == "accept":
+		s.acceptReview(w, r, task)
+	default:
+		s.dismissReview(w, r, task)
+	}
+}
+
+// listReviews accepts ?status=open|closed and ?incident_id=.
+func (s *Server) listReviews(w http.ResponseWriter, r *http.Request, profileID string) {
+	query := r.URL.Query()
+	filter := db.ReviewFilter{Status: query.Get("status"), IncidentID: query.Get("incident_id")}
+
+	if filter.Status != "" && filter.Status != db.ReviewOpen && filter.Status != db.ReviewClosed {
+		http.Error(w, "Invalid status: must be open or closed", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.db.ListReviewTasks(profileID, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, tasks)
+}
+
+// openReview records a review task for an incident, with suggestions worked
+// out from the links given. A failure is logged rather than returned, since
+// the change that triggered the review has already been saved.
+func (s *Server) openReview(profile *db.Profile, incident *db.Incident, trigger string, links review.Links) *db.ReviewTask {
+	task := &db.ReviewTask{
+		IncidentID:  &incident.ID,
+		Trigger:     trigger,
+		Title:       "Review the threat model after incident: " + incident.Title,
+		Suggestions: review.Suggest(profile.Sections(), links),
+	}
+
+	if err := s.db.CreateReviewTask(profile.ID, task); err != nil {
+		log.Printf("Failed to create review task for incident %s: %v", incident.ID, err)
+		return nil
+	}
+	return task
+}
+
+// reviewOnTrigger opens review tasks for unresolved incidents when a save
+// turns on response_capability's post_incident.threat_model_update_trigger.
+// Incidents that already have an open review are skipped. It returns the
+// IDs of the tasks created.
+func (s *Server) reviewOnTrigger(profile *db.Profile, section, data string) []string {
+	if section != "response_capability" || !updateTriggerSet(&data) || updateTriggerSet(profile.ResponseCapability) {
+		return nil
+	}
+
+	incidents, err := s.db.IncidentsWithoutOpenReview(profile.ID)
+	if err != nil {
+		log.Printf("Failed to find incidents to review: %v", err)
+		return nil
+	}
+
+	var ids []string
+	for i := range incidents {
+		incident := &incidents[i]
+		links := review.Links{AssetIDs: incident.AffectedAssets, ThreatIDs: incident.RelatedThreats}
+		if task := s.openReview(profile, incident, db.TriggerResponseCapability, links); task != nil {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
+
+func updateTriggerSet(data *string) bool {
+	if data == nil {
+		return false
+	}
+	var doc struct {
+		PostIncident struct {
+			ThreatModelUpdateTrigger bool `json:"threat_model_update_trigger"`
+		} `json:"post_incident"`
+	}
+	json.Unmarshal([]byte(*data), &doc)
+	return doc.PostIncident.ThreatModelUpdateTrigger
+}
+
+// reviewSelection reads {"suggestions": [...]} naming the suggestions to
+// act on. An empty body selects every pending suggestion.
+func reviewSelection(w http.ResponseWriter, r *http.Request, task *db.ReviewTask) ([]review.Suggestion, bool) {
+	if task.Status != db.ReviewOpen {
+		http.Error(w, "Review task is closed", http.StatusConflict)
+		return nil, false
+	}
+
+	var req struct {
+		Suggestions []string `json:"suggestions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if req.Suggestions == nil {
+		return task.Pending(), true
+	}
+
+	byID := make(map[string]review.Suggestion)
+	for _, suggestion := range task.Suggestions {
+		byID[suggestion.ID] = suggestion
+	}
+
+	var selected []review.Suggestion
+	for _, id := range req.Suggestions {
+		suggestion, ok := byID[id]
+		if !ok {
+			http.Error(w, "Unknown suggestion: "+id, http.StatusBadRequest)
+			return nil, false
+		}
+		if suggestion.Status != review.StatusPending {
+			http.Error(w, "Suggestion already "+suggestion.Status+": "+id, http.StatusConflict)
+			return nil, false
+		}
+		selected = append(selected, suggestion)
+	}
+	return selected, true
+}
+
+// acceptReview applies the selected suggestions to the profile. The changed
+// sections are validated, derived and checked for broken references like
+// any other save, and written with source "review" in the same transaction
+// as the task. A suggestion whose item has changed since
+// it was made is refused with 409 and can be dismissed instead. So is the
+// whole batch when one of the sections changes while it is being applied.
+func (s *Server) acceptReview(w http.ResponseWriter, r *http.Request, task *db.ReviewTask) {
+	selected, ok := reviewSelection(w, r, task)
+	if !ok {
+		return
+	}
+
+	profile, err := s.db.GetProfile(task.ProfileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	bySection := make(map[string][]review.Suggestion)
+	ids := make(map[string]bool)
+	for _, suggestion := range selected {
+		bySection[suggestion.Section] = append(bySection[suggestion.Section], suggestion)
+		ids[suggestion.ID] = true
+	}
+
+	names := make([]string, 0, len(bySection))
+	for name := range bySection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sections := make(map[string]*string)
+	for _, name := range names {
+		data, err := review.Apply(name, profile.Section(name), bySection[name])
+		var stale *review.StaleError
+		if errors.As(err, &stale) {
+			writeJSONStatus(w, http.StatusConflict, map[string]interface{}{
+				"error":         "The profile has changed since the suggestion was made",
+				"suggestion_id": stale.SuggestionID,
+				"item_id":       stale.ItemID,
+			})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		validationErrors, err := s.validator.Validate(name, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(validationErrors) > 0 {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "Validation failed",
+				"errors": validationErrors,
+			})
+			return
+		}
+
+		sections[name] = &data
+	}
+
+	// Risk scores follow threat likelihoods, so deriving the sections also
+	// rescores the stored risks when a threat changes.
+	derived, err := s.deriveSections(profile, sections)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if s.rejectScoreMismatch && len(derived.scoreCorrections) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Risk scores do not match the computed values",
+			"score_corrections": derived.scoreCorrections,
+		})
+		return
+	}
+
+	warnings := s.introducedReferences(profile, derived.merged)
+	if s.integrityMode == integrityModeError && len(warnings) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":             "Broken references",
+			"broken_references": warnings,
+		})
+		return
+	}
+
+	// Suggestions only guard the items they touch, so the sections are
+	// written only if nothing else in them has changed since they were read.
+	// The task is updated in the same transaction.
+	task.Resolve(ids, review.StatusAccepted)
+	writes, err := s.db.AcceptReview(task, derived.sections, expectedVersions(profile, derived.sections), db.SourceReview)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		writeJSONStatus(w, http.StatusConflict, map[string]interface{}{
+			"error":           "The section has changed while the suggestions were being applied",
+			"section":         conflict.Section,
+			"current_version": conflict.Current,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	version := profile.Version
+	if len(writes) > 0 {
+		version = writes.Version()
+	}
+
+	response := map[string]interface{}{
+		"task":    task,
+		"version": version,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	if len(derived.scoreCorrections) > 0 {
+		response["score_corrections"] = derived.scoreCorrections
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) dismissReview(w http.ResponseWriter, r *http.Request, task *db.ReviewTask) {
+	selected, ok := reviewSelection(w, r, task)
+	if !ok {
+		return
+	}
+
+	ids := make(map[string]bool)
+	for _, suggestion := range selected {
+		ids[suggestion.ID] = true
+	}
+
+	task.Resolve(ids, review.StatusDismissed)
+	if err := s.db.UpdateReviewTask(task); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, task)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// openIncidentReview logs an incident against threat-t1 and returns the
+// review task it opened.
+func openIncidentReview(t *testing.T, s *Server, profileID string) map[string]interface{} {
+	t.Helper()
+
+	mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+profileID+"/incidents", `{
+		"title": "Phishing wave", "description": "Staff got credential phishing", "type": "phishing",
+		"severity": "high", "occurred_at": "2026-01-05T10:00:00Z", "related_threats": ["threat-t1"]}`)
+
+	var tasks []map[string]interface{}
+	w := mustDo(t, s, http.StatusOK, "GET", "/api/profiles/"+profileID+"/reviews", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("got %d review tasks, want 1", len(tasks))
+	}
+	return tasks[0]
+}
+
+func TestAcceptReviewRescoresRisks(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", strings.Replace(testThreats, `"high"`, `"medium"`, 1))
+
+	task := openIncidentReview(t, s, id)
+	mustDo(t, s, http.StatusOK, "POST", "/api/profiles/"+id+"/reviews/"+task["id"].(string)+"/accept", "")
+
+	// medium (2) × high (3) × vulnerability 1 once the likelihood is raised.
+	risk, riskSummary := getRisk(t, s, id, "risk-r1")
+	if risk["likelihood_score"] != 3.0 || risk["risk_score"] != 6.0 {
+		t.Errorf("risk = %v/%v, want likelihood 3 and score 6", risk["likelihood_score"], risk["risk_score"])
+	}
+	if riskSummary["moderate_risks"] != 1.0 {
+		t.Errorf("risk_summary = %v, want one moderate risk", riskSummary)
+	}
+}
+
+func TestAcceptReviewRefusesStaleSuggestions(t *testing.T) {
+	s := newTestServer(t)
+	id := createScoredProfile(t, s)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", strings.Replace(testThreats, `"high"`, `"medium"`, 1))
+
+	task := openIncidentReview(t, s, id)
+	mustDo(t, s, http.StatusOK, "PUT", "/api/profiles/"+id+"/threats", strings.Replace(testThreats, `"high"`, `"low"`, 1))
+
+	w := mustDo(t, s, http.StatusConflict, "POST", "/api/profiles/"+id+"/reviews/"+task["id"].(string)+"/accept", "")
+	if body := decode(t, w); body["item_id"] != "threat-t1" {
+		t.Errorf("conflict = %v, want it to name threat-t1", body)
+	}
+}
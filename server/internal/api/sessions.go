@@ -0,0 +1,352 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultSessionTTL = 7 * 24 * time.Hour
+
+// Passwords are hashed with bcrypt, which only reads the first 72 bytes.
+const (
+	minPasswordLength = 12
+	maxPasswordLength = 72
+	bcryptCost        = 12
+)
+
+func sessionTTLFromEnv() time.Duration {
+	value := os.Getenv("ARMOR_SESSION_TTL")
+	if value == "" {
+		return defaultSessionTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Warning: invalid ARMOR_SESSION_TTL %q, using %s", value, defaultSessionTTL)
+		return defaultSessionTTL
+	}
+	return ttl
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkPasswordPolicy(password string) string {
+	if len(password) < minPasswordLength {
+		return "Password must be at least 12 characters"
+	}
+	if len(password) > maxPasswordLength {
+		return "Password must be at most 72 bytes"
+	}
+	return ""
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// comparePassword checks a password against a stored hash. Accounts without
+// a password are compared against a throwaway hash so that response times do
+// not reveal which email addresses exist.
+func comparePassword(hash, password string) bool {
+	if hash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("armor-unused-password"), bcryptCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/auth/login":
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.login(w, r)
+	case "/auth/logout":
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.logout(w, r)
+	case "/auth/me":
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.me(w, r)
+	case "/auth/password":
+		if r.Method != "PUT" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.changePassword(w, r)
+	case "/auth/sessions":
+		if r.Method != "DELETE" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.logoutOtherSessions(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hash := ""
+	if user != nil {
+		hash, err = s.db.GetPasswordHash(user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !comparePassword(hash, req.Password) {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.DeleteExpiredSessions(); err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session, err := s.db.CreateSession(hashToken(token), user.ID, time.Now().Add(s.sessionTTL))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user.LastLoginAt = &session.CreatedAt
+	writeJSON(w, map[string]interface{}{
+		"token":      token,
+		"expires_at": session.ExpiresAt,
+		"user":       user,
+	})
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+	if p.tokenHash != "" {
+		if err := s.db.DeleteSession(p.tokenHash); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+	if p.admin {
+		writeJSON(w, map[string]interface{}{"admin": true})
+		return
+	}
+	if p.apiKey != nil {
+		writeJSON(w, map[string]interface{}{"admin": false, "api_key": p.apiKey})
+		return
+	}
+
+	user, err := s.db.GetUser(p.userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orgs, err := s.db.ListUserOrganizations(p.userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"admin":         false,
+		"user":          user,
+		"organizations": orgs,
+	})
+}
+
+// changePassword lets users change their own password. Their other
+// sessions are signed out.
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+	if p.userID == "" {
+		http.Error(w, "Only user accounts have passwords", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := s.db.GetPasswordHash(p.userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !comparePassword(hash, req.CurrentPassword) {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	if !s.setPassword(w, p.userID, req.NewPassword) {
+		return
+	}
+
+	if _, err := s.db.DeleteUserSessions(p.userID, p.tokenHash); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) logoutOtherSessions(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r)
+	if p.userID == "" {
+		http.Error(w, "Only user accounts have sessions", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := s.db.DeleteUserSessions(p.userID, p.tokenHash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"revoked": revoked})
+}
+
+func (s *Server) setPassword(w http.ResponseWriter, userID, password string) bool {
+	if msg := checkPasswordPolicy(password); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return false
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if err := s.db.SetPasswordHash(userID, string(hash)); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// handleUserRoutes holds the account administration endpoints, which only
+// administrators can use: setting a user's password and signing a user out
+// everywhere, e.g. when a volunteer leaves.
+func (s *Server) handleUserRoutes(w http.ResponseWriter, r *http.Request) {
+	if !principalFrom(r).admin {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	user, err := s.db.GetUser(parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case parts[1] == "password" && r.Method == "PUT":
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !s.setPassword(w, user.ID, req.Password) {
+			return
+		}
+		if _, err := s.db.DeleteUserSessions(user.ID, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case parts[1] == "sessions" && r.Method == "DELETE":
+		revoked, err := s.db.DeleteUserSessions(user.ID, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"revoked": revoked})
+	case parts[1] == "password" || parts[1] == "sessions":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
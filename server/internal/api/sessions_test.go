@@ -0,0 +1,168 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const userPassword = "correct horse battery"
+
+// newUser makes a user with userPassword and returns their ID. The hash
+// uses the lowest bcrypt cost to keep the tests fast.
+func newUser(t *testing.T, s *Server, email string) string {
+	t.Helper()
+
+	user, err := s.db.CreateUser(email, "Test user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(userPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.SetPasswordHash(user.ID, string(hash)); err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// login signs in and returns the session token.
+func login(t *testing.T, s *Server, email, password string) string {
+	t.Helper()
+
+	w := mustDo(t, s, http.StatusOK, "POST", "/auth/login", `{"email": "`+email+`", "password": "`+password+`"}`)
+	return decode(t, w)["token"].(string)
+}
+
+func bearer(token string) []string {
+	return []string{"Authorization", "Bearer " + token}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	newUser(t, s, "ana@example.org")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		status   int
+	}{
+		{name: "correct password", email: "ana@example.org", password: userPassword, status: http.StatusOK},
+		{name: "email in another case", email: "Ana@Example.org", password: userPassword, status: http.StatusOK},
+		{name: "wrong password", email: "ana@example.org", password: "not the password", status: http.StatusUnauthorized},
+		{name: "unknown email", email: "bo@example.org", password: userPassword, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := mustDo(t, s, tt.status, "POST", "/auth/login", `{"email": "`+tt.email+`", "password": "`+tt.password+`"}`)
+			if tt.status != http.StatusOK {
+				return
+			}
+			token := decode(t, w)["token"].(string)
+			me := decode(t, mustDo(t, s, http.StatusOK, "GET", "/auth/me", "", bearer(token)...))
+			if me["admin"] != false || me["user"].(map[string]interface{})["email"] != "ana@example.org" {
+				t.Errorf("me = %v, want ana's account", me)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	newUser(t, s, "ana@example.org")
+	token := login(t, s, "ana@example.org", userPassword)
+
+	mustDo(t, s, http.StatusOK, "GET", "/auth/me", "", bearer(token)...)
+	mustDo(t, s, http.StatusNoContent, "POST", "/auth/logout", "", bearer(token)...)
+	mustDo(t, s, http.StatusUnauthorized, "GET", "/auth/me", "", bearer(token)...)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		status int
+	}{
+		{name: "within the TTL", ttl: time.Hour, status: http.StatusOK},
+		{name: "past the TTL", ttl: -time.Minute, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.sessionTTL = tt.ttl
+			newUser(t, s, "ana@example.org")
+
+			token := login(t, s, "ana@example.org", userPassword)
+			mustDo(t, s, tt.status, "GET", "/auth/me", "", bearer(token)...)
+		})
+	}
+}
+
+func TestSessionRevocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// revoke signs sessions out using the current session's token,
+		// or as the administrator when it is not needed.
+		revoke       func(t *testing.T, s *Server, userID, current string)
+		keepsCurrent bool
+		newPassword  bool
+	}{
+		{
+			name: "sign out other sessions",
+			revoke: func(t *testing.T, s *Server, userID, current string) {
+				w := mustDo(t, s, http.StatusOK, "DELETE", "/auth/sessions", "", bearer(current)...)
+				if revoked := decode(t, w)["revoked"]; revoked != 1.0 {
+					t.Errorf("revoked = %v, want 1", revoked)
+				}
+			},
+			keepsCurrent: true,
+		},
+		{
+			name: "change own password",
+			revoke: func(t *testing.T, s *Server, userID, current string) {
+				mustDo(t, s, http.StatusForbidden, "PUT", "/auth/password",
+					`{"current_password": "not the password", "new_password": "a new long password"}`, bearer(current)...)
+				mustDo(t, s, http.StatusNoContent, "PUT", "/auth/password",
+					`{"current_password": "`+userPassword+`", "new_password": "a new long password"}`, bearer(current)...)
+			},
+			keepsCurrent: true,
+			newPassword:  true,
+		},
+		{
+			name: "administrator sets the password",
+			revoke: func(t *testing.T, s *Server, userID, current string) {
+				mustDo(t, s, http.StatusNoContent, "PUT", "/api/users/"+userID+"/password", `{"password": "a new long password"}`)
+			},
+			newPassword: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			userID := newUser(t, s, "ana@example.org")
+			current := login(t, s, "ana@example.org", userPassword)
+			other := login(t, s, "ana@example.org", userPassword)
+
+			tt.revoke(t, s, userID, current)
+
+			mustDo(t, s, http.StatusUnauthorized, "GET", "/auth/me", "", bearer(other)...)
+			status := http.StatusUnauthorized
+			if tt.keepsCurrent {
+				status = http.StatusOK
+			}
+			mustDo(t, s, status, "GET", "/auth/me", "", bearer(current)...)
+
+			if tt.newPassword {
+				mustDo(t, s, http.StatusUnauthorized, "POST", "/auth/login", `{"email": "ana@example.org", "password": "`+userPassword+`"}`)
+				login(t, s, "ana@example.org", "a new long password")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// handleTemplates serves the adversary template library at
+// /api/templates/adversaries and single templates below it.
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/templates/"), "/")
+	if parts[0] != "adversaries" || len(parts) > 2 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(parts) == 1 || parts[1] == "" {
+		writeJSON(w, s.validator.AdversaryTemplates())
+		return
+	}
+
+	template, ok := s.validator.AdversaryTemplate(parts[1])
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, template)
+}
+
+// createAdversaryFromTemplate adds an adversary built from a library
+// template to the profile. The body may override the name and set the
+// profile-specific fields; everything else comes from the template.
+func (s *Server) createAdversaryFromTemplate(w http.ResponseWriter, r *http.Request, profileID, templateID string) {
+	template, ok := s.validator.AdversaryTemplate(templateID)
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name               string `json:"name"`
+		Relevance          string `json:"relevance"`
+		RelevanceRationale string `json:"relevance_rationale"`
+		CustomNotes        string `json:"custom_notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.db.GetProfile(profileID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	expectedVersion, ok := s.checkIfMatch(w, r, profile, "adversaries")
+	if !ok {
+		return
+	}
+
+	adversary, err := s.validator.NewAdversary(template, newItemID("adversary"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for field, value := range map[string]string{
+		"name":                req.Name,
+		"relevance":           req.Relevance,
+		"relevance_rationale": req.RelevanceRationale,
+		"custom_notes":        req.CustomNotes,
+	} {
+		if value != "" {
+			adversary[field] = value
+		}
+	}
+
+	section, _ := parseJSON(profile.Section("adversaries")).(map[string]interface{})
+	if section == nil {
+		section = map[string]interface{}{}
+	}
+	list, _ := section["adversaries"].([]interface{})
+	section["adversaries"] = append(list, adversary)
+
+	data, err := json.Marshal(section)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, ok := s.storeSection(w, r, profile, "adversaries", string(data), expectedVersion)
+	if !ok {
+		return
+	}
+
+	// Report the adversary as stored, with derived fields and redaction
+	// applied, so clients can go on to customize it by ID.
+	if view, ok := response["data"].(map[string]interface{}); ok {
+		list, _ := view["adversaries"].([]interface{})
+		for _, item := range list {
+			if item, ok := item.(map[string]interface{}); ok && item["adversary_id"] == adversary["adversary_id"] {
+				response["adversary"] = item
+			}
+		}
+	}
+
+	writeJSONStatus(w, http.StatusCreated, response)
+}
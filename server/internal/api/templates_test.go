@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCreateAdversaryFromTemplate(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	path := "/api/profiles/" + id + "/adversaries/from-template/"
+
+	seen := make(map[string]bool)
+	for _, template := range s.validator.AdversaryTemplates().Templates {
+		t.Run(template.TemplateID, func(t *testing.T) {
+			w := mustDo(t, s, http.StatusCreated, "POST", path+template.TemplateID, "")
+			adversary, _ := decode(t, w)["adversary"].(map[string]interface{})
+			if adversary == nil {
+				t.Fatalf("response has no adversary: %s", w.Body.String())
+			}
+
+			if adversary["template_id"] != template.TemplateID {
+				t.Errorf("template_id = %v, want %s", adversary["template_id"], template.TemplateID)
+			}
+			if adversary["name"] != template.Name {
+				t.Errorf("name = %v, want %s", adversary["name"], template.Name)
+			}
+			adversaryID, _ := adversary["adversary_id"].(string)
+			if !strings.HasPrefix(adversaryID, "adversary-") || seen[adversaryID] {
+				t.Errorf("adversary_id = %q, want a fresh adversary ID", adversaryID)
+			}
+			seen[adversaryID] = true
+
+			profile, err := s.db.GetProfile(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errors, err := s.validator.Validate("adversaries", *profile.Adversaries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errors) > 0 {
+				t.Errorf("stored adversaries fail validation: %+v", errors)
+			}
+		})
+	}
+
+	if len(seen) == 0 {
+		t.Fatal("the template library is empty")
+	}
+}
+
+func TestCreateAdversaryFromTemplateOverrides(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	template := s.validator.AdversaryTemplates().Templates[0]
+
+	body := `{"name": "Regional troll farm", "relevance": "confirmed",
+		"relevance_rationale": "Seen in our comments", "custom_notes": "Active since May"}`
+	w := mustDo(t, s, http.StatusCreated, "POST", "/api/profiles/"+id+"/adversaries/from-template/"+template.TemplateID, body)
+	adversary := decode(t, w)["adversary"].(map[string]interface{})
+
+	var want map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &want); err != nil {
+		t.Fatal(err)
+	}
+	for field, value := range want {
+		if adversary[field] != value {
+			t.Errorf("%s = %v, want %v", field, adversary[field], value)
+		}
+	}
+	if adversary["category"] != template.Category {
+		t.Errorf("category = %v, want the template's %s", adversary["category"], template.Category)
+	}
+}
+
+func TestCreateAdversaryFromTemplateErrors(t *testing.T) {
+	s := newTestServer(t)
+	id := createProfile(t, s)
+	template := s.validator.AdversaryTemplates().Templates[0].TemplateID
+	path := "/api/profiles/" + id + "/adversaries/from-template/"
+
+	etag := mustDo(t, s, http.StatusCreated, "POST", path+template, "").Header().Get("ETag")
+	mustDo(t, s, http.StatusCreated, "POST", path+template, "", "If-Match", etag)
+
+	tests := []struct {
+		name     string
+		template string
+		body     string
+		headers  []string
+		status   int
+	}{
+		{name: "unknown template", template: "no_such_template", status: http.StatusNotFound},
+		{name: "stale If-Match", template: template, headers: []string{"If-Match", etag}, status: http.StatusPreconditionFailed},
+		{name: "invalid body", template: template, body: "{", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustDo(t, s, tt.status, "POST", path+tt.template, tt.body, tt.headers...)
+		})
+	}
+
+	profile, err := s.db.GetProfile(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var section struct {
+		Adversaries []interface{} `json:"adversaries"`
+	}
+	if err := json.Unmarshal([]byte(*profile.Adversaries), &section); err != nil {
+		t.Fatal(err)
+	}
+	if len(section.Adversaries) != 2 {
+		t.Errorf("profile has %d adversaries, want the 2 created before the failures", len(section.Adversaries))
+	}
+}
@@ -0,0 +1,31 @@
+// Package catalog lists the sections a profile is made of. Storage, schema
+// validation and the API all take the section names from here.
+package catalog
+
+// CoreSections are the six methodology components every profile works through.
+var CoreSections = []string{"mission", "assets", "adversaries", "threats", "risks", "mitigations"}
+
+// ModuleSections are the optional deep-dive modules.
+var ModuleSections = []string{
+	"opsec",
+	"response_capability",
+	"technical_deep_dive",
+	"information_operations",
+	"deep_adversary_profiling",
+}
+
+// ValidSections holds every section a profile can store: the core sections
+// and the modules.
+var ValidSections = func() map[string]bool {
+	sections := make(map[string]bool, len(CoreSections)+len(ModuleSections))
+	for _, list := range [][]string{CoreSections, ModuleSections} {
+		for _, section := range list {
+			sections[section] = true
+		}
+	}
+	return sections
+}()
+
+func IsValidSection(section string) bool {
+	return ValidSections[section]
+}
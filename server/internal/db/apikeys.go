@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key permissions. read-propose keys can read the profile and submit
+// proposals for review but cannot change it directly.
+const (
+	PermissionRead        = "read"
+	PermissionReadPropose = "read-propose"
+	PermissionReadWrite   = "read-write"
+)
+
+var ValidPermissions = map[string]bool{
+	PermissionRead:        true,
+	PermissionReadPropose: true,
+	PermissionReadWrite:   true,
+}
+
+// APIKey is a credential for scripts and agents, scoped to one organization.
+// Only a hash of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organization_id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	KeyHash        string     `json:"-"`
+	Permission     string     `json:"permission"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+const apiKeyColumns = `id, organization_id, name, prefix, key_hash, permission, created_by, created_at, expires_at, last_used_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var k APIKey
+	var createdBy, expiresAt, lastUsedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&k.ID, &k.OrganizationID, &k.Name, &k.Prefix, &k.KeyHash, &k.Permission,
+		&createdBy, &createdAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	k.CreatedBy = createdBy.String
+	k.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	k.ExpiresAt = nullableTime(expiresAt)
+	k.LastUsedAt = nullableTime(lastUsedAt)
+
+	return &k, nil
+}
+
+func nullableTime(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func (db *DB) CreateAPIKey(organizationID, name, prefix, keyHash, permission, createdBy string, expiresAt *time.Time) (*APIKey, error) {
+	now := time.Now().UTC()
+	key := &APIKey{
+		ID:             uuid.New().String(),
+		OrganizationID: organizationID,
+		Name:           name,
+		Prefix:         prefix,
+		KeyHash:        keyHash,
+		Permission:     permission,
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+	}
+
+	var expires interface{}
+	if expiresAt != nil {
+		t := expiresAt.UTC()
+		key.ExpiresAt = &t
+		expires = t.Format(time.RFC3339)
+	}
+
+	var creator interface{}
+	if createdBy != "" {
+		creator = createdBy
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO api_keys (id, organization_id, name, prefix, key_hash, permission, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, key.ID, organizationID, name, prefix, keyHash, permission, creator, now.Format(time.RFC3339), expires)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (db *DB) ListAPIKeys(organizationID string) ([]APIKey, error) {
+	rows, err := db.conn.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE organization_id = ? ORDER BY created_at DESC`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+
+	return keys, rows.Err()
+}
+
+// UseAPIKey looks up an unexpired key by hash and records that it was used.
+func (db *DB) UseAPIKey(keyHash string) (*APIKey, error) {
+	k, err := scanAPIKey(db.conn.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return nil, nil
+	}
+
+	k.LastUsedAt = &now
+	if _, err := db.conn.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now.Format(time.RFC3339), k.ID); err != nil {
+		return nil, fmt.Errorf("failed to record API key use: %w", err)
+	}
+
+	return k, nil
+}
+
+func (db *DB) DeleteAPIKey(organizationID, id string) error {
+	result, err := db.conn.Exec(`DELETE FROM api_keys WHERE organization_id = ? AND id = ?`, organizationID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -3,10 +3,10 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type DB struct {
@@ -14,17 +14,26 @@ type DB struct {
 }
 
 type Profile struct {
-	ID          string     `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description,omitempty"`
-	Mission     *string    `json:"mission,omitempty"`
-	Assets      *string    `json:"assets,omitempty"`
-	Adversaries *string    `json:"adversaries,omitempty"`
-	Threats     *string    `json:"threats,omitempty"`
-	Risks       *string    `json:"risks,omitempty"`
-	Mitigations *string    `json:"mitigations,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID                     string         `json:"id"`
+	Name                   string         `json:"name"`
+	Description            string         `json:"description,omitempty"`
+	OrganizationID         string         `json:"organization_id,omitempty"`
+	Mission                *string        `json:"mission,omitempty"`
+	Assets                 *string        `json:"assets,omitempty"`
+	Adversaries            *string        `json:"adversaries,omitempty"`
+	Threats                *string        `json:"threats,omitempty"`
+	Risks                  *string        `json:"risks,omitempty"`
+	Mitigations            *string        `json:"mitigations,omitempty"`
+	Opsec                  *string        `json:"opsec,omitempty"`
+	ResponseCapability     *string        `json:"response_capability,omitempty"`
+	TechnicalDeepDive      *string        `json:"technical_deep_dive,omitempty"`
+	InformationOperations  *string        `json:"information_operations,omitempty"`
+	DeepAdversaryProfiling *string        `json:"deep_adversary_profiling,omitempty"`
+	Meta                   *string        `json:"meta,omitempty"`
+	Version                int            `json:"version"`
+	SectionVersions        map[string]int `json:"section_versions,omitempty"`
+	CreatedAt              time.Time      `json:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at"`
 }
 
 type ProfileSummary struct {
@@ -36,14 +45,72 @@ type ProfileSummary struct {
 	UpdatedAt    string  `json:"updated_at"`
 }
 
-func Open(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", path)
+// Sections returns the stored JSON for every section, keyed by section name.
+func (p *Profile) Sections() map[string]*string {
+	return map[string]*string{
+		"mission":                  p.Mission,
+		"assets":                   p.Assets,
+		"adversaries":              p.Adversaries,
+		"threats":                  p.Threats,
+		"risks":                    p.Risks,
+		"mitigations":              p.Mitigations,
+		"opsec":                    p.Opsec,
+		"response_capability":      p.ResponseCapability,
+		"technical_deep_dive":      p.TechnicalDeepDive,
+		"information_operations":   p.InformationOperations,
+		"deep_adversary_profiling": p.DeepAdversaryProfiling,
+	}
+}
+
+// Section returns the stored JSON for a single section, or nil if it is empty.
+func (p *Profile) Section(name string) *string {
+	return p.Sections()[name]
+}
+
+// SetSection replaces the JSON of a single section in memory, for working
+// out what a profile would look like before writing to it.
+func (p *Profile) SetSection(name string, data *string) {
+	switch name {
+	case "mission":
+		p.Mission = data
+	case "assets":
+		p.Assets = data
+	case "adversaries":
+		p.Adversaries = data
+	case "threats":
+		p.Threats = data
+	case "risks":
+		p.Risks = data
+	case "mitigations":
+		p.Mitigations = data
+	case "opsec":
+		p.Opsec = data
+	case "response_capability":
+		p.ResponseCapability = data
+	case "technical_deep_dive":
+		p.TechnicalDeepDive = data
+	case "information_operations":
+		p.InformationOperations = data
+	case "deep_adversary_profiling":
+		p.DeepAdversaryProfiling = data
+	}
+}
+
+// Open opens the database at path. With a key, the file is encrypted with
+// SQLCipher; without one it is plain SQLite.
+func Open(path string, key *Key) (*DB, error) {
+	conn, err := sql.Open("sqlite3", dsn(path, key))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := conn.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// A wrong key only shows up once the first page is read.
+	if err := checkReadable(conn); err != nil {
+		conn.Close()
+		if key == nil {
+			return nil, fmt.Errorf("failed to read database (is it encrypted? set ARMOR_DB_KEY or ARMOR_DB_PASSPHRASE): %w", err)
+		}
+		return nil, fmt.Errorf("failed to read database (wrong key, or the database is not encrypted yet): %w", err)
 	}
 
 	db := &DB{conn: conn}
@@ -70,14 +137,218 @@ func (db *DB) migrate() error {
 		threats TEXT,
 		risks TEXT,
 		mitigations TEXT,
+		opsec TEXT,
+		response_capability TEXT,
+		technical_deep_dive TEXT,
+		information_operations TEXT,
+		deep_adversary_profiling TEXT,
+		version INTEGER NOT NULL DEFAULT 0,
+		organization_id TEXT,
+		meta TEXT,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS profile_history (
+		id TEXT PRIMARY KEY,
+		profile_id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		section TEXT NOT NULL,
+		previous_value TEXT,
+		new_value TEXT,
+		source TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		UNIQUE (profile_id, version)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_profile_history_section ON profile_history(profile_id, section, version);
+
+	CREATE TABLE IF NOT EXISTS section_versions (
+		profile_id TEXT NOT NULL,
+		section TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		PRIMARY KEY (profile_id, section)
+	);
+
+	CREATE TABLE IF NOT EXISTS organizations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
 		created_at TEXT NOT NULL,
 		updated_at TEXT NOT NULL
 	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		password_hash TEXT,
+		created_at TEXT NOT NULL,
+		last_login_at TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		last_used_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		organization_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		permission TEXT NOT NULL,
+		created_by TEXT,
+		created_at TEXT NOT NULL,
+		expires_at TEXT,
+		last_used_at TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_api_keys_organization ON api_keys(organization_id);
+
+	CREATE TABLE IF NOT EXISTS organization_memberships (
+		user_id TEXT NOT NULL,
+		organization_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (user_id, organization_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_memberships_organization ON organization_memberships(organization_id);
+
+	CREATE TABLE IF NOT EXISTS incidents (
+		id TEXT PRIMARY KEY,
+		profile_id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		status TEXT NOT NULL,
+		occurred_at TEXT NOT NULL,
+		discovered_at TEXT NOT NULL,
+		resolved_at TEXT,
+		affected_assets TEXT NOT NULL,
+		related_threats TEXT NOT NULL,
+		response_actions TEXT NOT NULL,
+		lessons_learned TEXT,
+		created_by TEXT,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_incidents_profile ON incidents(profile_id, occurred_at);
+
+	CREATE TABLE IF NOT EXISTS review_tasks (
+		id TEXT PRIMARY KEY,
+		profile_id TEXT NOT NULL,
+		incident_id TEXT,
+		trigger TEXT NOT NULL,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		suggestions TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		closed_at TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_review_tasks_profile ON review_tasks(profile_id, status);
+
+	CREATE TABLE IF NOT EXISTS proposals (
+		id TEXT PRIMARY KEY,
+		profile_id TEXT NOT NULL,
+		section TEXT NOT NULL,
+		patch TEXT NOT NULL,
+		rationale TEXT NOT NULL,
+		base_version INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		source TEXT NOT NULL,
+		proposed_by TEXT,
+		api_key_id TEXT,
+		proposer_role TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		resolved_at TEXT,
+		resolved_by TEXT,
+		rejection_reason TEXT,
+		applied_version INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_proposals_profile ON proposals(profile_id, status);
 	`
-	_, err := db.conn.Exec(schema)
+	if _, err := db.conn.Exec(schema); err != nil {
+		return err
+	}
+
+	// Databases created by earlier releases lack the newer profile columns.
+	columns := []column{
+		{"opsec", "TEXT"},
+		{"response_capability", "TEXT"},
+		{"technical_deep_dive", "TEXT"},
+		{"information_operations", "TEXT"},
+		{"deep_adversary_profiling", "TEXT"},
+		{"version", "INTEGER NOT NULL DEFAULT 0"},
+		{"organization_id", "TEXT"},
+		{"meta", "TEXT"},
+	}
+	if err := db.addMissingColumns("profiles", columns); err != nil {
+		return err
+	}
+	if err := db.addMissingColumns("users", []column{{"password_hash", "TEXT"}}); err != nil {
+		return err
+	}
+	if err := db.addMissingColumns("incidents", []column{{"version", "INTEGER NOT NULL DEFAULT 1"}}); err != nil {
+		return err
+	}
+
+	_, err := db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_profiles_organization ON profiles(organization_id)`)
 	return err
 }
 
+type column struct {
+	name       string
+	definition string
+}
+
+func (db *DB) addMissingColumns(table string, columns []column) error {
+	rows, err := db.conn.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := db.conn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", c.name, err)
+		}
+	}
+
+	return nil
+}
+
 func (db *DB) CreateProfile(name, description string) (*Profile, error) {
 	id := uuid.New().String()
 	now := time.Now().UTC()
@@ -100,54 +371,123 @@ func (db *DB) CreateProfile(name, description string) (*Profile, error) {
 	}, nil
 }
 
-func (db *DB) GetProfile(id string) (*Profile, error) {
-	var p Profile
-	var createdAt, updatedAt string
-	var description, mission, assets, adversaries, threats, risks, mitigations sql.NullString
-
-	err := db.conn.QueryRow(`
-		SELECT id, name, description, mission, assets, adversaries, threats, risks, mitigations, created_at, updated_at
-		FROM profiles WHERE id = ?
-	`, id).Scan(&p.ID, &p.Name, &description, &mission, &assets, &adversaries, &threats, &risks, &mitigations, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
+// CreateProfileWithSections creates a profile with its metadata and writes
+// its sections in one transaction, so a failed import leaves nothing behind.
+// Each section gets a history entry with source, in name order.
+func (db *DB) CreateProfileWithSections(name, description string, meta *string, sections map[string]*string, source string) (*Profile, error) {
+	names := make([]string, 0, len(sections))
+	for section := range sections {
+		names = append(names, section)
 	}
+	sort.Strings(names)
+
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get profile: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	p.Description = description.String
-	if mission.Valid {
-		p.Mission = &mission.String
-	}
-	if assets.Valid {
-		p.Assets = &assets.String
-	}
-	if adversaries.Valid {
-		p.Adversaries = &adversaries.String
+	id := uuid.New().String()
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = tx.Exec(`
+		INSERT INTO profiles (id, name, description, meta, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, id, name, description, meta, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
 	}
-	if threats.Valid {
-		p.Threats = &threats.String
+
+	for _, section := range names {
+		if _, err := updateSectionTx(tx, id, section, sections[section], source, AnyVersion); err != nil {
+			return nil, err
+		}
 	}
-	if risks.Valid {
-		p.Risks = &risks.String
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit profile: %w", err)
 	}
-	if mitigations.Valid {
-		p.Mitigations = &mitigations.String
+
+	return db.GetProfile(id)
+}
+
+const profileColumns = `id, name, description, mission, assets, adversaries, threats, risks, mitigations,
+		opsec, response_capability, technical_deep_dive, information_operations, deep_adversary_profiling,
+		meta, version, organization_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProfile(row rowScanner) (*Profile, error) {
+	var p Profile
+	var createdAt, updatedAt string
+	var description, organizationID, meta sql.NullString
+	var mission, assets, adversaries, threats, risks, mitigations sql.NullString
+	var opsec, responseCapability, technicalDeepDive, informationOperations, deepAdversaryProfiling sql.NullString
+
+	err := row.Scan(&p.ID, &p.Name, &description,
+		&mission, &assets, &adversaries, &threats, &risks, &mitigations,
+		&opsec, &responseCapability, &technicalDeepDive, &informationOperations, &deepAdversaryProfiling,
+		&meta, &p.Version, &organizationID, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
 	}
 
+	p.Description = description.String
+	p.OrganizationID = organizationID.String
+	p.Mission = nullableString(mission)
+	p.Assets = nullableString(assets)
+	p.Adversaries = nullableString(adversaries)
+	p.Threats = nullableString(threats)
+	p.Risks = nullableString(risks)
+	p.Mitigations = nullableString(mitigations)
+	p.Opsec = nullableString(opsec)
+	p.ResponseCapability = nullableString(responseCapability)
+	p.TechnicalDeepDive = nullableString(technicalDeepDive)
+	p.InformationOperations = nullableString(informationOperations)
+	p.DeepAdversaryProfiling = nullableString(deepAdversaryProfiling)
+	p.Meta = nullableString(meta)
+
 	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 	p.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
 	return &p, nil
 }
 
+func nullableString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+// GetProfile reads a profile and its section versions in one transaction,
+// so the versions always describe the section data returned with them.
+func (db *DB) GetProfile(id string) (*Profile, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanProfile(tx.QueryRow(`SELECT `+profileColumns+` FROM profiles WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	p.SectionVersions, err = sectionVersions(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, tx.Commit()
+}
+
 func (db *DB) ListProfiles() ([]Profile, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, name, description, mission, assets, adversaries, threats, risks, mitigations, created_at, updated_at
-		FROM profiles ORDER BY updated_at DESC
-	`)
+	rows, err := db.conn.Query(`SELECT ` + profileColumns + ` FROM profiles ORDER BY updated_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list profiles: %w", err)
 	}
@@ -155,46 +495,35 @@ func (db *DB) ListProfiles() ([]Profile, error) {
 
 	var profiles []Profile
 	for rows.Next() {
-		var p Profile
-		var createdAt, updatedAt string
-		var description, mission, assets, adversaries, threats, risks, mitigations sql.NullString
-
-		err := rows.Scan(&p.ID, &p.Name, &description, &mission, &assets, &adversaries, &threats, &risks, &mitigations, &createdAt, &updatedAt)
+		p, err := scanProfile(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan profile: %w", err)
 		}
 
-		p.Description = description.String
-		if mission.Valid {
-			p.Mission = &mission.String
-		}
-		if assets.Valid {
-			p.Assets = &assets.String
-		}
-		if adversaries.Valid {
-			p.Adversaries = &adversaries.String
-		}
-		if threats.Valid {
-			p.Threats = &threats.String
-		}
-		if risks.Valid {
-			p.Risks = &risks.String
-		}
-		if mitigations.Valid {
-			p.Mitigations = &mitigations.String
-		}
-
-		p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		p.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-
-		profiles = append(profiles, p)
+		profiles = append(profiles, *p)
 	}
 
 	return profiles, nil
 }
 
 func (db *DB) DeleteProfile(id string) error {
-	result, err := db.conn.Exec(`DELETE FROM profiles WHERE id = ?`, id)
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteProfileTx(tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteProfileTx removes a profile together with its history and section
+// versions.
+func deleteProfileTx(tx *sql.Tx, id string) error {
+	result, err := tx.Exec(`DELETE FROM profiles WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete profile: %w", err)
 	}
@@ -204,6 +533,42 @@ func (db *DB) DeleteProfile(id string) error {
 		return sql.ErrNoRows
 	}
 
+	if _, err := tx.Exec(`DELETE FROM profile_history WHERE profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete profile history: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM section_versions WHERE profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete section versions: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM incidents WHERE profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete incidents: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM review_tasks WHERE profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete review tasks: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM proposals WHERE profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proposals: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMeta replaces the stored metadata of a profile. Metadata is not a
+// section, so it has no version or history of its own.
+func (db *DB) UpdateMeta(profileID string, meta *string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	result, err := db.conn.Exec(`UPDATE profiles SET meta = ?, updated_at = ? WHERE id = ?`, meta, now, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to update profile metadata: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
@@ -225,32 +590,104 @@ func (db *DB) GetSection(profileID, section string) (*string, error) {
 	return nil, nil
 }
 
-func (db *DB) UpdateSection(profileID, section, data string) error {
+// UpdateSections writes several sections in one transaction, recording a
+// history entry per section in name order. A nil value clears the section.
+// Sections named in expected are only written if they are still at that
+// version; otherwise a *VersionConflictError is returned and nothing changes.
+func (db *DB) UpdateSections(profileID string, sections map[string]*string, expected map[string]int, source string) (SectionWrites, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	writes, err := updateSectionsTx(tx, profileID, sections, expected, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit section updates: %w", err)
+	}
+
+	return writes, nil
+}
+
+func updateSectionsTx(tx *sql.Tx, profileID string, sections map[string]*string, expected map[string]int, source string) (SectionWrites, error) {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writes := make(SectionWrites, len(names))
+	for _, name := range names {
+		expectedVersion, ok := expected[name]
+		if !ok {
+			expectedVersion = AnyVersion
+		}
+		write, err := updateSectionTx(tx, profileID, name, sections[name], source, expectedVersion)
+		if err != nil {
+			return nil, err
+		}
+		writes[name] = write
+	}
+
+	return writes, nil
+}
+
+// updateSectionTx writes a section value, bumps the profile and section
+// versions and appends a history entry. A nil data clears the section.
+func updateSectionTx(tx *sql.Tx, profileID, section string, data *string, source string, expectedVersion int) (*SectionWrite, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
-	query := fmt.Sprintf(`UPDATE profiles SET %s = ?, updated_at = ? WHERE id = ?`, section)
 
-	result, err := db.conn.Exec(query, data, now, profileID)
+	// Bumping the version first takes the write lock, so the values read
+	// below cannot race with another writer.
+	result, err := tx.Exec(`UPDATE profiles SET version = version + 1, updated_at = ? WHERE id = ?`, now, profileID)
 	if err != nil {
-		return fmt.Errorf("failed to update section: %w", err)
+		return nil, fmt.Errorf("failed to update profile version: %w", err)
 	}
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return sql.ErrNoRows
+		return nil, sql.ErrNoRows
 	}
 
-	return nil
-}
+	sectionVersion, err := sectionVersionTx(tx, profileID, section)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != AnyVersion && expectedVersion != sectionVersion {
+		return nil, &VersionConflictError{Section: section, Current: sectionVersion}
+	}
 
-var ValidSections = map[string]bool{
-	"mission":     true,
-	"assets":      true,
-	"adversaries": true,
-	"threats":     true,
-	"risks":       true,
-	"mitigations": true,
-}
+	var previous sql.NullString
+	var version int
+	query := fmt.Sprintf(`SELECT %s, version FROM profiles WHERE id = ?`, section)
+	if err := tx.QueryRow(query, profileID).Scan(&previous, &version); err != nil {
+		return nil, fmt.Errorf("failed to read section: %w", err)
+	}
+
+	query = fmt.Sprintf(`UPDATE profiles SET %s = ? WHERE id = ?`, section)
+	if _, err := tx.Exec(query, data, profileID); err != nil {
+		return nil, fmt.Errorf("failed to update section: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO section_versions (profile_id, section, version) VALUES (?, ?, 1)
+		ON CONFLICT (profile_id, section) DO UPDATE SET version = version + 1
+	`, profileID, section)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update section version: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO profile_history (id, profile_id, version, section, previous_value, new_value, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), profileID, version, section, previous, data, source, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record history: %w", err)
+	}
 
-func IsValidSection(section string) bool {
-	return ValidSections[section]
+	return &SectionWrite{Version: version, SectionVersion: sectionVersion + 1}, nil
 }
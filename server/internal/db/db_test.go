@@ -0,0 +1,121 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	database, err := Open(filepath.Join(t.TempDir(), "armor.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestUpdateSectionsExpectedVersions(t *testing.T) {
+	database := openTestDB(t)
+
+	profile, err := database.CreateProfile("Test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assets, threats := `{"assets": []}`, `{"threats": []}`
+	writes, err := database.UpdateSections(profile.ID, map[string]*string{"assets": &assets, "threats": &threats}, nil, SourceAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writes["assets"].SectionVersion != 1 || writes["threats"].SectionVersion != 1 || writes.Version() != 2 {
+		t.Fatalf("writes = %+v %+v, want both sections at 1 and profile at 2", writes["assets"], writes["threats"])
+	}
+
+	tests := []struct {
+		name     string
+		expected map[string]int
+		conflict string
+	}{
+		{name: "stale section", expected: map[string]int{"assets": 1, "threats": 0}, conflict: "threats"},
+		{name: "current versions", expected: map[string]int{"assets": 1, "threats": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := `{"assets": [], "notes": "changed"}`
+			_, err := database.UpdateSections(profile.ID, map[string]*string{"assets": &changed, "threats": &threats}, tt.expected, SourceAPI)
+
+			var conflict *VersionConflictError
+			if tt.conflict == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.As(err, &conflict) || conflict.Section != tt.conflict {
+				t.Fatalf("err = %v, want a conflict on %s", err, tt.conflict)
+			}
+
+			// Nothing is written when any section conflicts.
+			stored, err := database.GetSection(profile.ID, "assets")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *stored != assets {
+				t.Errorf("assets = %s, want the earlier value", *stored)
+			}
+		})
+	}
+}
+
+func TestGetProfileVersionsMatchData(t *testing.T) {
+	database := openTestDB(t)
+
+	created, err := database.CreateProfile("Test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only assets are written, so every profile version bump is an assets
+	// version bump and the stored data names the version it was saved at.
+	const writes = 50
+	done := make(chan error, 1)
+	go func() {
+		for i := 1; i <= writes; i++ {
+			assets := fmt.Sprintf(`{"assets": [], "notes": "write %d"}`, i)
+			if _, err := database.UpdateSections(created.ID, map[string]*string{"assets": &assets}, nil, SourceAPI); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for finished := false; !finished; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			finished = true
+		default:
+		}
+
+		profile, err := database.GetProfile(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version := profile.SectionVersions["assets"]
+		if profile.Version-created.Version != version {
+			t.Fatalf("profile version %d with assets version %d, want them in step from %d", profile.Version, version, created.Version)
+		}
+		if version > 0 && !strings.Contains(*profile.Assets, fmt.Sprintf(`"write %d"`, version)) {
+			t.Fatalf("assets version %d with data %s", version, *profile.Assets)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	sqlcipher "github.com/mutecomm/go-sqlcipher/v4"
+)
+
+// Key is a SQLCipher key: either 32 raw bytes, used as is, or a passphrase,
+// which SQLCipher stretches with PBKDF2 and the salt stored in the file.
+type Key struct {
+	raw        []byte
+	passphrase string
+}
+
+// ParseKey decodes a base64 encoded 256-bit key.
+func ParseKey(encoded string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(raw))
+	}
+	return &Key{raw: raw}, nil
+}
+
+func PassphraseKey(passphrase string) (*Key, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	return &Key{passphrase: passphrase}, nil
+}
+
+// pragma renders the key as the value of SQLCipher's PRAGMA key, which the
+// driver wraps in double quotes.
+func (k *Key) pragma() string {
+	if k.raw != nil {
+		return "x'" + hex.EncodeToString(k.raw) + "'"
+	}
+	return strings.ReplaceAll(k.passphrase, `"`, `""`)
+}
+
+func dsn(path string, key *Key) string {
+	if key == nil {
+		return path
+	}
+	return path + "?_pragma_key=" + url.QueryEscape(key.pragma()) + "&_pragma_cipher_page_size=4096"
+}
+
+// IsEncrypted reports whether the database file at path is encrypted. A
+// missing or empty file is not.
+func IsEncrypted(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return sqlcipher.IsEncrypted(path)
+}
+
+// checkReadable reads the schema so that a wrong key, or a key for a
+// plaintext file, fails at startup rather than on the first request.
+func checkReadable(conn *sql.DB) error {
+	var count int
+	return conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&count)
+}
+
+// Rekey re-encrypts the database at path from current to next. current is
+// nil for a plaintext database, which is encrypted by exporting it to a new
+// file that then replaces the original.
+func Rekey(path string, current, next *Key) error {
+	if next == nil {
+		return errors.New("a new key is required")
+	}
+
+	encrypted, err := IsEncrypted(path)
+	if err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if encrypted && current == nil {
+		return errors.New("database is encrypted but no current key was given")
+	}
+	if !encrypted && current != nil {
+		return errors.New("database is not encrypted but a current key was given")
+	}
+
+	if !encrypted {
+		return encryptPlaintext(path, next)
+	}
+
+	conn, err := sql.Open("sqlite3", dsn(path, current))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	if err := checkReadable(conn); err != nil {
+		return fmt.Errorf("failed to read database with the current key: %w", err)
+	}
+	if _, err := conn.Exec(fmt.Sprintf(`PRAGMA rekey = "%s"`, next.pragma())); err != nil {
+		return fmt.Errorf("failed to rekey database: %w", err)
+	}
+
+	return verifyKey(path, next)
+}
+
+func encryptPlaintext(path string, next *Key) error {
+	tmp := path + ".rekey"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale %s: %w", tmp, err)
+	}
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	// ATTACH and sqlcipher_export must run on the same connection.
+	conn.SetMaxOpenConns(1)
+
+	attach := fmt.Sprintf(`ATTACH DATABASE '%s' AS encrypted KEY "%s"`,
+		strings.ReplaceAll(tmp, "'", "''"), next.pragma())
+	if _, err := conn.Exec(attach); err != nil {
+		return fmt.Errorf("failed to create encrypted copy: %w", err)
+	}
+	if _, err := conn.Exec(`PRAGMA encrypted.cipher_page_size = 4096`); err != nil {
+		return fmt.Errorf("failed to configure encrypted copy: %w", err)
+	}
+	if _, err := conn.Exec(`SELECT sqlcipher_export('encrypted')`); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to export database: %w", err)
+	}
+	if _, err := conn.Exec(`DETACH DATABASE encrypted`); err != nil {
+		return fmt.Errorf("failed to detach encrypted copy: %w", err)
+	}
+	conn.Close()
+
+	if err := verifyKey(tmp, next); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+func verifyKey(path string, key *Key) error {
+	conn, err := sql.Open("sqlite3", dsn(path, key))
+	if err != nil {
+		return fmt.Errorf("failed to reopen database: %w", err)
+	}
+	defer conn.Close()
+
+	if err := checkReadable(conn); err != nil {
+		return fmt.Errorf("database is not readable with the new key: %w", err)
+	}
+	return nil
+}
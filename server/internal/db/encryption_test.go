@@ -0,0 +1,159 @@
+package db
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func rawKey(t *testing.T, fill byte) *Key {
+	t.Helper()
+
+	key, err := ParseKey(base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func passphraseKey(t *testing.T, passphrase string) *Key {
+	t.Helper()
+
+	key, err := PassphraseKey(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// createProfileFile makes a database at path with one profile and returns
+// the profile ID.
+func createProfileFile(t *testing.T, path string, key *Key) string {
+	t.Helper()
+
+	database, err := Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	profile, err := database.CreateProfile("Encrypted", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile.ID
+}
+
+// readProfileName opens the database at path and returns the profile's name.
+func readProfileName(path string, key *Key, id string) (string, error) {
+	database, err := Open(path, key)
+	if err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	profile, err := database.GetProfile(id)
+	if err != nil || profile == nil {
+		return "", err
+	}
+	return profile.Name, nil
+}
+
+func TestRekey(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *Key
+		next    *Key
+	}{
+		{name: "raw key to raw key", current: rawKey(t, 'a'), next: rawKey(t, 'b')},
+		{name: "passphrase to raw key", current: passphraseKey(t, `a "quoted" passphrase`), next: rawKey(t, 'b')},
+		{name: "raw key to passphrase", current: rawKey(t, 'a'), next: passphraseKey(t, "new passphrase")},
+		{name: "plaintext to raw key", next: rawKey(t, 'b')},
+		{name: "plaintext to passphrase", next: passphraseKey(t, "new passphrase")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "armor.db")
+			id := createProfileFile(t, path, tt.current)
+
+			encrypted, err := IsEncrypted(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypted != (tt.current != nil) {
+				t.Fatalf("IsEncrypted = %v before rekeying", encrypted)
+			}
+
+			if err := Rekey(path, tt.current, tt.next); err != nil {
+				t.Fatal(err)
+			}
+
+			if encrypted, err := IsEncrypted(path); err != nil || !encrypted {
+				t.Fatalf("IsEncrypted = %v, %v after rekeying, want true", encrypted, err)
+			}
+			if _, err := readProfileName(path, tt.current, id); err == nil {
+				t.Error("the old key still opens the database")
+			}
+			if name, err := readProfileName(path, tt.next, id); err != nil || name != "Encrypted" {
+				t.Errorf("reading with the new key = %q, %v, want the profile", name, err)
+			}
+		})
+	}
+}
+
+func TestRekeyRefusals(t *testing.T) {
+	key := rawKey(t, 'a')
+
+	tests := []struct {
+		name      string
+		fileKey   *Key
+		current   *Key
+		next      *Key
+		wantError string
+	}{
+		{name: "no new key", fileKey: key, current: key, wantError: "new key is required"},
+		{name: "encrypted without current key", fileKey: key, next: rawKey(t, 'b'), wantError: "no current key"},
+		{name: "plaintext with current key", current: key, next: rawKey(t, 'b'), wantError: "not encrypted"},
+		{name: "wrong current key", fileKey: key, current: rawKey(t, 'c'), next: rawKey(t, 'b'), wantError: "current key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "armor.db")
+			id := createProfileFile(t, path, tt.fileKey)
+
+			err := Rekey(path, tt.current, tt.next)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Fatalf("Rekey error = %v, want one mentioning %q", err, tt.wantError)
+			}
+
+			// A refused rekey leaves the data readable with the original key.
+			if name, err := readProfileName(path, tt.fileKey, id); err != nil || name != "Encrypted" {
+				t.Errorf("reading with the original key = %q, %v", name, err)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		valid   bool
+	}{
+		{name: "32 bytes", encoded: base64.StdEncoding.EncodeToString(make([]byte, 32)), valid: true},
+		{name: "surrounding whitespace", encoded: " " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n", valid: true},
+		{name: "16 bytes", encoded: base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{name: "not base64", encoded: "not base64!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKey(tt.encoded); (err == nil) != tt.valid {
+				t.Errorf("ParseKey error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
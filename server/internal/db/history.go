@@ -0,0 +1,177 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Change sources recorded in the profile history.
+const (
+	SourceWeb      = "web"
+	SourceAgent    = "agent"
+	SourceAPI      = "api"
+	SourceImport   = "import"
+	SourceRestore  = "restore"
+	SourceReview   = "review"
+	SourceProposal = "proposal"
+)
+
+// ValidSources are the sources a client may declare for its own changes.
+// Restores, accepted review suggestions and accepted proposals are labelled
+// by the server.
+var ValidSources = map[string]bool{
+	SourceWeb:    true,
+	SourceAgent:  true,
+	SourceAPI:    true,
+	SourceImport: true,
+}
+
+type HistoryEntry struct {
+	ID            string    `json:"id"`
+	ProfileID     string    `json:"profile_id"`
+	Version       int       `json:"version"`
+	Section       string    `json:"section"`
+	PreviousValue *string   `json:"previous_value,omitempty"`
+	NewValue      *string   `json:"new_value,omitempty"`
+	Source        string    `json:"source"`
+	CreatedAt     time.Time `json:"timestamp"`
+}
+
+type HistoryFilter struct {
+	Section string
+	Since   time.Time
+	Limit   int
+}
+
+const historyColumns = `id, profile_id, version, section, previous_value, new_value, source, created_at`
+
+func scanHistoryEntry(row rowScanner) (*HistoryEntry, error) {
+	var e HistoryEntry
+	var previousValue, newValue sql.NullString
+	var createdAt string
+
+	err := row.Scan(&e.ID, &e.ProfileID, &e.Version, &e.Section, &previousValue, &newValue, &e.Source, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	e.PreviousValue = nullableString(previousValue)
+	e.NewValue = nullableString(newValue)
+	e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &e, nil
+}
+
+// ListHistory returns a profile's changes, newest first.
+func (db *DB) ListHistory(profileID string, filter HistoryFilter) ([]HistoryEntry, error) {
+	query := `SELECT ` + historyColumns + ` FROM profile_history WHERE profile_id = ?`
+	args := []interface{}{profileID}
+
+	if filter.Section != "" {
+		query += ` AND section = ?`
+		args = append(args, filter.Section)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UTC().Format(time.RFC3339))
+	}
+	query += ` ORDER BY version DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		e, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan history entry: %w", err)
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetHistoryEntry returns the change that produced the given profile version.
+func (db *DB) GetHistoryEntry(profileID string, version int) (*HistoryEntry, error) {
+	row := db.conn.QueryRow(`SELECT `+historyColumns+` FROM profile_history WHERE profile_id = ? AND version = ?`, profileID, version)
+
+	e, err := scanHistoryEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history entry: %w", err)
+	}
+
+	return e, nil
+}
+
+// GetSectionAtVersion reconstructs a section's value as it was once the
+// profile reached the given version.
+func (db *DB) GetSectionAtVersion(profileID, section string, version int) (*string, error) {
+	var value sql.NullString
+
+	// The most recent change at or before the version holds the value.
+	err := db.conn.QueryRow(`
+		SELECT new_value FROM profile_history
+		WHERE profile_id = ? AND section = ? AND version <= ?
+		ORDER BY version DESC LIMIT 1
+	`, profileID, section, version).Scan(&value)
+	if err == nil {
+		return nullableString(value), nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get section at version: %w", err)
+	}
+
+	// Otherwise the first change after it recorded what the value used to be,
+	// which also covers data written before history was kept.
+	err = db.conn.QueryRow(`
+		SELECT previous_value FROM profile_history
+		WHERE profile_id = ? AND section = ? AND version > ?
+		ORDER BY version ASC LIMIT 1
+	`, profileID, section, version).Scan(&value)
+	if err == nil {
+		return nullableString(value), nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get section at version: %w", err)
+	}
+
+	// The section has never changed, so the stored value is still current.
+	return db.GetSection(profileID, section)
+}
+
+// ChangedSections lists the sections modified after fromVersion up to and
+// including toVersion.
+func (db *DB) ChangedSections(profileID string, fromVersion, toVersion int) ([]string, error) {
+	rows, err := db.conn.Query(`
+		SELECT DISTINCT section FROM profile_history
+		WHERE profile_id = ? AND version > ? AND version <= ?
+		ORDER BY section
+	`, profileID, fromVersion, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed sections: %w", err)
+	}
+	defer rows.Close()
+
+	var sections []string
+	for rows.Next() {
+		var section string
+		if err := rows.Scan(&section); err != nil {
+			return nil, fmt.Errorf("failed to scan section: %w", err)
+		}
+		sections = append(sections, section)
+	}
+
+	return sections, rows.Err()
+}
@@ -0,0 +1,357 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrIncidentChanged is returned when an incident is updated from a copy
+// that another write has since replaced.
+var ErrIncidentChanged = errors.New("incident has changed")
+
+// Incident types, severities and statuses.
+var (
+	ValidIncidentTypes = map[string]bool{
+		"phishing":           true,
+		"account_compromise": true,
+		"data_breach":        true,
+		"malware":            true,
+		"physical":           true,
+		"harassment":         true,
+		"disinformation":     true,
+		"other":              true,
+	}
+
+	ValidSeverities = map[string]bool{
+		"critical": true,
+		"high":     true,
+		"medium":   true,
+		"low":      true,
+	}
+)
+
+const (
+	IncidentOpen          = "open"
+	IncidentInvestigating = "investigating"
+	IncidentResolved      = "resolved"
+	IncidentClosed        = "closed"
+)
+
+var ValidIncidentStatuses = map[string]bool{
+	IncidentOpen:          true,
+	IncidentInvestigating: true,
+	IncidentResolved:      true,
+	IncidentClosed:        true,
+}
+
+const (
+	ActionPending    = "pending"
+	ActionInProgress = "in_progress"
+	ActionCompleted  = "completed"
+)
+
+var ValidActionStatuses = map[string]bool{
+	ActionPending:    true,
+	ActionInProgress: true,
+	ActionCompleted:  true,
+}
+
+// Incident is a security incident logged against a profile. Affected assets
+// and related threats hold asset and threat IDs from the profile sections.
+type Incident struct {
+	ID              string           `json:"id"`
+	ProfileID       string           `json:"profile_id"`
+	Title           string           `json:"title"`
+	Description     string           `json:"description"`
+	Type            string           `json:"type"`
+	Severity        string           `json:"severity"`
+	Status          string           `json:"status"`
+	OccurredAt      time.Time        `json:"occurred_at"`
+	DiscoveredAt    time.Time        `json:"discovered_at"`
+	ResolvedAt      *time.Time       `json:"resolved_at"`
+	AffectedAssets  []string         `json:"affected_assets"`
+	RelatedThreats  []string         `json:"related_threats"`
+	ResponseActions []ResponseAction `json:"response_actions"`
+	LessonsLearned  *string          `json:"lessons_learned"`
+	CreatedBy       string           `json:"created_by,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	// Version counts the writes to the incident, so an update made from a
+	// stale copy is refused instead of overwriting the response actions.
+	Version int `json:"version"`
+}
+
+// ResponseAction is a step taken in response to an incident. Actions are
+// stored with their incident.
+type ResponseAction struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	AssignedTo  *string    `json:"assigned_to"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Action returns the response action with the given ID, or nil.
+func (i *Incident) Action(id string) *ResponseAction {
+	for n := range i.ResponseActions {
+		if i.ResponseActions[n].ID == id {
+			return &i.ResponseActions[n]
+		}
+	}
+	return nil
+}
+
+type IncidentFilter struct {
+	Statuses   []string
+	Severities []string
+	Type       string
+	Since      time.Time
+	Limit      int
+}
+
+const incidentColumns = `id, profile_id, title, description, type, severity, status, occurred_at, discovered_at,
+	resolved_at, affected_assets, related_threats, response_actions, lessons_learned, created_by, created_at, updated_at, version`
+
+func scanIncident(row rowScanner) (*Incident, error) {
+	var i Incident
+	var occurredAt, discoveredAt, createdAt, updatedAt string
+	var resolvedAt, lessonsLearned, createdBy sql.NullString
+	var affectedAssets, relatedThreats, responseActions string
+
+	err := row.Scan(&i.ID, &i.ProfileID, &i.Title, &i.Description, &i.Type, &i.Severity, &i.Status,
+		&occurredAt, &discoveredAt, &resolvedAt, &affectedAssets, &relatedThreats, &responseActions,
+		&lessonsLearned, &createdBy, &createdAt, &updatedAt, &i.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	i.OccurredAt, _ = time.Parse(time.RFC3339, occurredAt)
+	i.DiscoveredAt, _ = time.Parse(time.RFC3339, discoveredAt)
+	i.ResolvedAt = nullableTime(resolvedAt)
+	i.LessonsLearned = nullableString(lessonsLearned)
+	i.CreatedBy = createdBy.String
+	i.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	i.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	if err := json.Unmarshal([]byte(affectedAssets), &i.AffectedAssets); err != nil {
+		return nil, fmt.Errorf("invalid affected assets: %w", err)
+	}
+	if err := json.Unmarshal([]byte(relatedThreats), &i.RelatedThreats); err != nil {
+		return nil, fmt.Errorf("invalid related threats: %w", err)
+	}
+	if err := json.Unmarshal([]byte(responseActions), &i.ResponseActions); err != nil {
+		return nil, fmt.Errorf("invalid response actions: %w", err)
+	}
+
+	return &i, nil
+}
+
+// incidentValues returns the mutable columns of an incident in the order
+// used by CreateIncident and UpdateIncident.
+func incidentValues(i *Incident) ([]interface{}, error) {
+	if i.AffectedAssets == nil {
+		i.AffectedAssets = []string{}
+	}
+	if i.RelatedThreats == nil {
+		i.RelatedThreats = []string{}
+	}
+	if i.ResponseActions == nil {
+		i.ResponseActions = []ResponseAction{}
+	}
+
+	affectedAssets, err := json.Marshal(i.AffectedAssets)
+	if err != nil {
+		return nil, err
+	}
+	relatedThreats, err := json.Marshal(i.RelatedThreats)
+	if err != nil {
+		return nil, err
+	}
+	responseActions, err := json.Marshal(i.ResponseActions)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolvedAt interface{}
+	if i.ResolvedAt != nil {
+		resolvedAt = i.ResolvedAt.UTC().Format(time.RFC3339)
+	}
+	var lessonsLearned interface{}
+	if i.LessonsLearned != nil {
+		lessonsLearned = *i.LessonsLearned
+	}
+
+	return []interface{}{
+		i.Title, i.Description, i.Type, i.Severity, i.Status,
+		i.OccurredAt.UTC().Format(time.RFC3339), i.DiscoveredAt.UTC().Format(time.RFC3339), resolvedAt,
+		string(affectedAssets), string(relatedThreats), string(responseActions), lessonsLearned,
+	}, nil
+}
+
+// CreateIncident stores a new incident for a profile, filling in its ID and
+// timestamps.
+func (db *DB) CreateIncident(profileID string, incident *Incident) error {
+	now := time.Now().UTC()
+	incident.ID = uuid.New().String()
+	incident.ProfileID = profileID
+	incident.CreatedAt = now
+	incident.UpdatedAt = now
+	incident.Version = 1
+
+	values, err := incidentValues(incident)
+	if err != nil {
+		return fmt.Errorf("failed to encode incident: %w", err)
+	}
+
+	var creator interface{}
+	if incident.CreatedBy != "" {
+		creator = incident.CreatedBy
+	}
+
+	args := append([]interface{}{incident.ID, profileID}, values...)
+	args = append(args, creator, now.Format(time.RFC3339), now.Format(time.RFC3339), incident.Version)
+
+	_, err = db.conn.Exec(`
+		INSERT INTO incidents (id, profile_id, title, description, type, severity, status, occurred_at, discovered_at,
+			resolved_at, affected_assets, related_threats, response_actions, lessons_learned, created_by, created_at, updated_at,
+			version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create incident: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetIncident(profileID, id string) (*Incident, error) {
+	row := db.conn.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE profile_id = ? AND id = ?`, profileID, id)
+
+	incident, err := scanIncident(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+
+	return incident, nil
+}
+
+// ListIncidents returns a profile's incidents, most recent first.
+func (db *DB) ListIncidents(profileID string, filter IncidentFilter) ([]Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE profile_id = ?`
+	args := []interface{}{profileID}
+
+	if len(filter.Statuses) > 0 {
+		query += ` AND status IN (?` + strings.Repeat(`, ?`, len(filter.Statuses)-1) + `)`
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if len(filter.Severities) > 0 {
+		query += ` AND severity IN (?` + strings.Repeat(`, ?`, len(filter.Severities)-1) + `)`
+		for _, severity := range filter.Severities {
+			args = append(args, severity)
+		}
+	}
+	if filter.Type != "" {
+		query += ` AND type = ?`
+		args = append(args, filter.Type)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND occurred_at >= ?`
+		args = append(args, filter.Since.UTC().Format(time.RFC3339))
+	}
+	query += ` ORDER BY occurred_at DESC, created_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		incidents = append(incidents, *incident)
+	}
+
+	return incidents, rows.Err()
+}
+
+// UpdateIncident writes every mutable field of an incident, including its
+// response actions, and bumps its version and update time. The write only
+// goes ahead if the stored incident is still at the version that was read;
+// otherwise ErrIncidentChanged is returned.
+func (db *DB) UpdateIncident(incident *Incident) error {
+	updatedAt := time.Now().UTC()
+
+	values, err := incidentValues(incident)
+	if err != nil {
+		return fmt.Errorf("failed to encode incident: %w", err)
+	}
+	args := append(values, updatedAt.Format(time.RFC3339), incident.ProfileID, incident.ID, incident.Version)
+
+	result, err := db.conn.Exec(`
+		UPDATE incidents SET title = ?, description = ?, type = ?, severity = ?, status = ?, occurred_at = ?,
+			discovered_at = ?, resolved_at = ?, affected_assets = ?, related_threats = ?, response_actions = ?,
+			lessons_learned = ?, updated_at = ?, version = version + 1
+		WHERE profile_id = ? AND id = ? AND version = ?
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update incident: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists int
+		err := db.conn.QueryRow(`SELECT COUNT(*) FROM incidents WHERE profile_id = ? AND id = ?`, incident.ProfileID, incident.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check incident: %w", err)
+		}
+		if exists == 0 {
+			return sql.ErrNoRows
+		}
+		return ErrIncidentChanged
+	}
+
+	incident.UpdatedAt = updatedAt
+	incident.Version++
+	return nil
+}
+
+// DeleteIncident removes an incident and its open review tasks. Closed
+// tasks are kept as a record of the changes they made.
+func (db *DB) DeleteIncident(profileID, id string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM incidents WHERE profile_id = ? AND id = ?`, profileID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete incident: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM review_tasks WHERE profile_id = ? AND incident_id = ? AND status = ?`, profileID, id, ReviewOpen); err != nil {
+		return fmt.Errorf("failed to delete review tasks: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpdateIncidentFromStaleCopy(t *testing.T) {
+	database := openTestDB(t)
+
+	profile, err := database.CreateProfile("Test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	incident := &Incident{Title: "Laptop stolen", Description: "Taxi", Type: "physical", Severity: "medium",
+		Status: IncidentOpen, OccurredAt: time.Now(), DiscoveredAt: time.Now()}
+	if err := database.CreateIncident(profile.ID, incident); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two writers read the incident, then each adds an action.
+	first, err := database.GetIncident(profile.ID, incident.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := database.GetIncident(profile.ID, incident.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.ResponseActions = append(first.ResponseActions, ResponseAction{ID: "a1", Description: "Wipe", Status: ActionPending})
+	if err := database.UpdateIncident(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 2 {
+		t.Errorf("version = %d, want 2", first.Version)
+	}
+
+	second.ResponseActions = append(second.ResponseActions, ResponseAction{ID: "a2", Description: "Report", Status: ActionPending})
+	if err := database.UpdateIncident(second); !errors.Is(err, ErrIncidentChanged) {
+		t.Fatalf("err = %v, want ErrIncidentChanged", err)
+	}
+
+	stored, err := database.GetIncident(profile.ID, incident.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.ResponseActions) != 1 || stored.ResponseActions[0].ID != "a1" {
+		t.Errorf("response actions = %+v, want only a1", stored.ResponseActions)
+	}
+
+	if err := database.DeleteIncident(profile.ID, incident.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.UpdateIncident(stored); err != sql.ErrNoRows {
+		t.Errorf("err = %v, want sql.ErrNoRows for a deleted incident", err)
+	}
+}
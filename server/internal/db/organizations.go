@@ -0,0 +1,507 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Membership roles, from most to least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var ValidRoles = map[string]bool{
+	RoleOwner:  true,
+	RoleAdmin:  true,
+	RoleEditor: true,
+	RoleViewer: true,
+}
+
+var (
+	ErrSlugTaken     = errors.New("slug already in use")
+	ErrEmailTaken    = errors.New("email already in use")
+	ErrAlreadyMember = errors.New("user is already a member")
+)
+
+// Organization is the top-level entity. Each organization owns exactly one
+// profile, created alongside it.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	ProfileID string    `json:"profile_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type User struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+type Membership struct {
+	UserID         string    `json:"user_id"`
+	OrganizationID string    `json:"organization_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Member is a user together with their role in one organization.
+type Member struct {
+	User
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// OrganizationRole is an organization together with a user's role in it.
+type OrganizationRole struct {
+	Organization
+	Role string `json:"role,omitempty"`
+}
+
+const organizationColumns = `o.id, o.name, o.slug, COALESCE(p.id, ''), o.created_at, o.updated_at`
+
+const organizationFrom = `organizations o LEFT JOIN profiles p ON p.organization_id = o.id`
+
+func scanOrganization(row rowScanner, extra ...interface{}) (*Organization, error) {
+	var o Organization
+	var createdAt, updatedAt string
+
+	dest := append([]interface{}{&o.ID, &o.Name, &o.Slug, &o.ProfileID, &createdAt, &updatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	o.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	o.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &o, nil
+}
+
+// CreateOrganization creates an organization and its empty profile.
+func (db *DB) CreateOrganization(name, slug string) (*Organization, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM organizations WHERE slug = ?`, slug).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check slug: %w", err)
+	}
+	if exists > 0 {
+		return nil, ErrSlugTaken
+	}
+
+	now := time.Now().UTC()
+	nowStr := now.Format(time.RFC3339)
+	org := &Organization{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Slug:      slug,
+		ProfileID: uuid.New().String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO organizations (id, name, slug, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, org.ID, org.Name, org.Slug, nowStr, nowStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO profiles (id, name, description, organization_id, created_at, updated_at)
+		VALUES (?, ?, '', ?, ?, ?)
+	`, org.ProfileID, org.Name, org.ID, nowStr, nowStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization profile: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit organization: %w", err)
+	}
+
+	return org, nil
+}
+
+func (db *DB) GetOrganization(id string) (*Organization, error) {
+	row := db.conn.QueryRow(`SELECT `+organizationColumns+` FROM `+organizationFrom+` WHERE o.id = ?`, id)
+
+	o, err := scanOrganization(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return o, nil
+}
+
+func (db *DB) GetOrganizationBySlug(slug string) (*Organization, error) {
+	row := db.conn.QueryRow(`SELECT `+organizationColumns+` FROM `+organizationFrom+` WHERE o.slug = ?`, slug)
+
+	o, err := scanOrganization(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return o, nil
+}
+
+// ListOrganizations returns every organization, for callers that are not
+// limited to their own memberships.
+func (db *DB) ListOrganizations() ([]OrganizationRole, error) {
+	rows, err := db.conn.Query(`SELECT ` + organizationColumns + ` FROM ` + organizationFrom + ` ORDER BY o.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []OrganizationRole{}
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, OrganizationRole{Organization: *o})
+	}
+
+	return orgs, rows.Err()
+}
+
+// ListUserOrganizations returns the organizations a user belongs to, with
+// their role in each.
+func (db *DB) ListUserOrganizations(userID string) ([]OrganizationRole, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+organizationColumns+`, m.role
+		FROM `+organizationFrom+`
+		JOIN organization_memberships m ON m.organization_id = o.id
+		WHERE m.user_id = ?
+		ORDER BY o.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []OrganizationRole{}
+	for rows.Next() {
+		var role string
+		o, err := scanOrganization(rows, &role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, OrganizationRole{Organization: *o, Role: role})
+	}
+
+	return orgs, rows.Err()
+}
+
+// UpdateOrganization renames an organization. The profile name follows the
+// organization name.
+func (db *DB) UpdateOrganization(id, name, slug string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM organizations WHERE slug = ? AND id != ?`, slug, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check slug: %w", err)
+	}
+	if exists > 0 {
+		return ErrSlugTaken
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	result, err := tx.Exec(`UPDATE organizations SET name = ?, slug = ?, updated_at = ? WHERE id = ?`, name, slug, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`UPDATE profiles SET name = ? WHERE organization_id = ?`, name, id); err != nil {
+		return fmt.Errorf("failed to rename organization profile: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteOrganization removes an organization, its memberships, API keys
+// and profile.
+func (db *DB) DeleteOrganization(id string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM organizations WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM organization_memberships WHERE organization_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete memberships: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM api_keys WHERE organization_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete API keys: %w", err)
+	}
+
+	var profileIDs []string
+	rows, err := tx.Query(`SELECT id FROM profiles WHERE organization_id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to find organization profile: %w", err)
+	}
+	for rows.Next() {
+		var profileID string
+		if err := rows.Scan(&profileID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan profile id: %w", err)
+		}
+		profileIDs = append(profileIDs, profileID)
+	}
+	rows.Close()
+
+	for _, profileID := range profileIDs {
+		if err := deleteProfileTx(tx, profileID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const userColumns = `id, email, name, created_at, last_login_at`
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	var createdAt string
+	var lastLoginAt sql.NullString
+
+	if err := row.Scan(&u.ID, &u.Email, &u.Name, &createdAt, &lastLoginAt); err != nil {
+		return nil, err
+	}
+
+	u.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	if lastLoginAt.Valid {
+		t, _ := time.Parse(time.RFC3339, lastLoginAt.String)
+		u.LastLoginAt = &t
+	}
+
+	return &u, nil
+}
+
+// NormalizeEmail lowercases and trims an address so lookups are
+// case-insensitive.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (db *DB) CreateUser(email, name string) (*User, error) {
+	email = NormalizeEmail(email)
+
+	existing, err := db.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrEmailTaken
+	}
+
+	now := time.Now().UTC()
+	user := &User{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Name:      name,
+		CreatedAt: now,
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO users (id, email, name, created_at)
+		VALUES (?, ?, ?, ?)
+	`, user.ID, user.Email, user.Name, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (db *DB) GetUser(id string) (*User, error) {
+	u, err := scanUser(db.conn.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+func (db *DB) GetUserByEmail(email string) (*User, error) {
+	u, err := scanUser(db.conn.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, NormalizeEmail(email)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+func (db *DB) AddMember(organizationID, userID, role string) (*Membership, error) {
+	existing, err := db.GetMembership(organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyMember
+	}
+
+	now := time.Now().UTC()
+	_, err = db.conn.Exec(`
+		INSERT INTO organization_memberships (user_id, organization_id, role, created_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, organizationID, role, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return &Membership{UserID: userID, OrganizationID: organizationID, Role: role, CreatedAt: now}, nil
+}
+
+func (db *DB) GetMembership(organizationID, userID string) (*Membership, error) {
+	var m Membership
+	var createdAt string
+
+	err := db.conn.QueryRow(`
+		SELECT user_id, organization_id, role, created_at
+		FROM organization_memberships
+		WHERE organization_id = ? AND user_id = ?
+	`, organizationID, userID).Scan(&m.UserID, &m.OrganizationID, &m.Role, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	m.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &m, nil
+}
+
+func (db *DB) ListMembers(organizationID string) ([]Member, error) {
+	rows, err := db.conn.Query(`
+		SELECT u.id, u.email, u.name, u.created_at, u.last_login_at, m.role, m.created_at
+		FROM organization_memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = ?
+		ORDER BY u.name, u.email
+	`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		var createdAt, joinedAt string
+		var lastLoginAt sql.NullString
+		if err := rows.Scan(&m.ID, &m.Email, &m.Name, &createdAt, &lastLoginAt, &m.Role, &joinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		m.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if lastLoginAt.Valid {
+			t, _ := time.Parse(time.RFC3339, lastLoginAt.String)
+			m.LastLoginAt = &t
+		}
+		m.JoinedAt, _ = time.Parse(time.RFC3339, joinedAt)
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (db *DB) UpdateMemberRole(organizationID, userID, role string) error {
+	result, err := db.conn.Exec(`
+		UPDATE organization_memberships SET role = ? WHERE organization_id = ? AND user_id = ?
+	`, role, organizationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update member: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DB) RemoveMember(organizationID, userID string) error {
+	result, err := db.conn.Exec(`
+		DELETE FROM organization_memberships WHERE organization_id = ? AND user_id = ?
+	`, organizationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountOwners returns how many owners an organization has, so the last one
+// cannot be removed or demoted.
+func (db *DB) CountOwners(organizationID string) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM organization_memberships WHERE organization_id = ? AND role = ?
+	`, organizationID, RoleOwner).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count owners: %w", err)
+	}
+	return count, nil
+}
+
+// GetProfileOrganization returns the organization a profile belongs to, or
+// "" for profiles created before organizations existed. found is false when
+// the profile does not exist.
+func (db *DB) GetProfileOrganization(profileID string) (organizationID string, found bool, err error) {
+	var value sql.NullString
+	err = db.conn.QueryRow(`SELECT organization_id FROM profiles WHERE id = ?`, profileID).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get profile organization: %w", err)
+	}
+	return value.String, true, nil
+}
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ProposalPending  = "pending"
+	ProposalAccepted = "accepted"
+	ProposalRejected = "rejected"
+)
+
+var ValidProposalStatuses = map[string]bool{
+	ProposalPending:  true,
+	ProposalAccepted: true,
+	ProposalRejected: true,
+}
+
+// ErrProposalResolved is returned when a proposal that is no longer pending
+// is accepted or rejected.
+var ErrProposalResolved = errors.New("proposal is not pending")
+
+// Proposal is a JSON Patch against one section, waiting for someone with
+// write access to accept or reject it. BaseVersion is the section version
+// the patch was written against. ProposerRole is the proposer's role in the
+// profile, which decides whether the patch addresses the redacted view.
+type Proposal struct {
+	ID              string          `json:"id"`
+	ProfileID       string          `json:"profile_id"`
+	Section         string          `json:"section"`
+	Patch           json.RawMessage `json:"patch"`
+	Rationale       string          `json:"rationale"`
+	BaseVersion     int             `json:"base_version"`
+	Status          string          `json:"status"`
+	Source          string          `json:"source"`
+	ProposedBy      string          `json:"proposed_by,omitempty"`
+	APIKeyID        string          `json:"api_key_id,omitempty"`
+	ProposerRole    string          `json:"-"`
+	CreatedAt       time.Time       `json:"created_at"`
+	ResolvedAt      *time.Time      `json:"resolved_at"`
+	ResolvedBy      string          `json:"resolved_by,omitempty"`
+	RejectionReason *string         `json:"rejection_reason"`
+	AppliedVersion  *int            `json:"applied_version"`
+}
+
+type ProposalFilter struct {
+	Status  string
+	Section string
+}
+
+const proposalColumns = `id, profile_id, section, patch, rationale, base_version, status, source, proposed_by, api_key_id,
+	proposer_role, created_at, resolved_at, resolved_by, rejection_reason, applied_version`
+
+func scanProposal(row rowScanner) (*Proposal, error) {
+	var p Proposal
+	var patch, createdAt string
+	var proposedBy, apiKeyID, resolvedAt, resolvedBy, rejectionReason sql.NullString
+	var appliedVersion sql.NullInt64
+
+	err := row.Scan(&p.ID, &p.ProfileID, &p.Section, &patch, &p.Rationale, &p.BaseVersion, &p.Status, &p.Source,
+		&proposedBy, &apiKeyID, &p.ProposerRole, &createdAt, &resolvedAt, &resolvedBy, &rejectionReason, &appliedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Patch = json.RawMessage(patch)
+	p.ProposedBy = proposedBy.String
+	p.APIKeyID = apiKeyID.String
+	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	p.ResolvedAt = nullableTime(resolvedAt)
+	p.ResolvedBy = resolvedBy.String
+	p.RejectionReason = nullableString(rejectionReason)
+	if appliedVersion.Valid {
+		version := int(appliedVersion.Int64)
+		p.AppliedVersion = &version
+	}
+
+	return &p, nil
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// CreateProposal stores a new pending proposal, filling in its ID and
+// creation time.
+func (db *DB) CreateProposal(profileID string, proposal *Proposal) error {
+	now := time.Now().UTC()
+	proposal.ID = uuid.New().String()
+	proposal.ProfileID = profileID
+	proposal.Status = ProposalPending
+	proposal.CreatedAt = now
+
+	_, err := db.conn.Exec(`
+		INSERT INTO proposals (id, profile_id, section, patch, rationale, base_version, status, source,
+			proposed_by, api_key_id, proposer_role, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, proposal.ID, profileID, proposal.Section, string(proposal.Patch), proposal.Rationale, proposal.BaseVersion,
+		proposal.Status, proposal.Source, nullIfEmpty(proposal.ProposedBy), nullIfEmpty(proposal.APIKeyID),
+		proposal.ProposerRole, now.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to create proposal: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetProposal(profileID, id string) (*Proposal, error) {
+	row := db.conn.QueryRow(`SELECT `+proposalColumns+` FROM proposals WHERE profile_id = ? AND id = ?`, profileID, id)
+
+	proposal, err := scanProposal(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal: %w", err)
+	}
+
+	return proposal, nil
+}
+
+// ListProposals returns a profile's proposals in the order they were made.
+func (db *DB) ListProposals(profileID string, filter ProposalFilter) ([]Proposal, error) {
+	query := `SELECT ` + proposalColumns + ` FROM proposals WHERE profile_id = ?`
+	args := []interface{}{profileID}
+
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Section != "" {
+		query += ` AND section = ?`
+		args = append(args, filter.Section)
+	}
+	query += ` ORDER BY created_at, rowid`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proposals: %w", err)
+	}
+	defer rows.Close()
+
+	proposals := []Proposal{}
+	for rows.Next() {
+		proposal, err := scanProposal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan proposal: %w", err)
+		}
+		proposals = append(proposals, *proposal)
+	}
+
+	return proposals, rows.Err()
+}
+
+// GetProposals returns the proposals with the given IDs in the order they
+// were made. IDs that do not belong to the profile are left out.
+func (db *DB) GetProposals(profileID string, ids []string) ([]Proposal, error) {
+	if len(ids) == 0 {
+		return []Proposal{}, nil
+	}
+
+	args := []interface{}{profileID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := db.conn.Query(`SELECT `+proposalColumns+` FROM proposals WHERE profile_id = ? AND id IN (?`+
+		strings.Repeat(`, ?`, len(ids)-1)+`) ORDER BY created_at, rowid`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposals: %w", err)
+	}
+	defer rows.Close()
+
+	proposals := []Proposal{}
+	for rows.Next() {
+		proposal, err := scanProposal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan proposal: %w", err)
+		}
+		proposals = append(proposals, *proposal)
+	}
+
+	return proposals, rows.Err()
+}
+
+// AcceptProposals writes the sections produced by a set of proposals and
+// marks the proposals accepted, all in one transaction. Each section is only
+// written if it is still at its expected version; otherwise a
+// *VersionConflictError is returned and nothing changes.
+func (db *DB) AcceptProposals(profileID string, ids []string, sections map[string]*string, expected map[string]int, source, resolvedBy string) (SectionWrites, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	writes, err := updateSectionsTx(tx, profileID, sections, expected, source)
+	if err != nil {
+		return nil, err
+	}
+	version := writes.Version()
+
+	if err := resolveProposalsTx(tx, profileID, ids, ProposalAccepted, resolvedBy, nil, &version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit proposals: %w", err)
+	}
+
+	return writes, nil
+}
+
+// RejectProposals marks pending proposals rejected with an optional reason.
+func (db *DB) RejectProposals(profileID string, ids []string, reason *string, resolvedBy string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := resolveProposalsTx(tx, profileID, ids, ProposalRejected, resolvedBy, reason, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit proposals: %w", err)
+	}
+	return nil
+}
+
+func resolveProposalsTx(tx *sql.Tx, profileID string, ids []string, status, resolvedBy string, reason *string, appliedVersion *int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var rejectionReason, applied interface{}
+	if reason != nil {
+		rejectionReason = *reason
+	}
+	if appliedVersion != nil {
+		applied = *appliedVersion
+	}
+
+	for _, id := range ids {
+		result, err := tx.Exec(`
+			UPDATE proposals SET status = ?, resolved_at = ?, resolved_by = ?, rejection_reason = ?, applied_version = ?
+			WHERE profile_id = ? AND id = ? AND status = ?
+		`, status, now, nullIfEmpty(resolvedBy), rejectionReason, applied, profileID, id, ProposalPending)
+		if err != nil {
+			return fmt.Errorf("failed to update proposal: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrProposalResolved
+		}
+	}
+
+	return nil
+}
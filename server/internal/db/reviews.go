@@ -0,0 +1,270 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/review"
+	"github.com/google/uuid"
+)
+
+// Review task triggers: a new incident, new links on an existing one, or
+// turning on response_capability's threat_model_update_trigger.
+const (
+	TriggerIncident           = "incident"
+	TriggerIncidentLinks      = "incident_links"
+	TriggerResponseCapability = "response_capability"
+)
+
+const (
+	ReviewOpen   = "open"
+	ReviewClosed = "closed"
+)
+
+// ReviewTask asks facilitators to revisit the threat model after an
+// incident. Its suggestions are accepted or dismissed one by one, and the
+// task closes when none are left pending.
+type ReviewTask struct {
+	ID          string              `json:"id"`
+	ProfileID   string              `json:"profile_id"`
+	IncidentID  *string             `json:"incident_id"`
+	Trigger     string              `json:"trigger"`
+	Title       string              `json:"title"`
+	Status      string              `json:"status"`
+	Suggestions []review.Suggestion `json:"suggestions"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	ClosedAt    *time.Time          `json:"closed_at"`
+}
+
+// Pending returns the suggestions that have been neither accepted nor
+// dismissed.
+func (t *ReviewTask) Pending() []review.Suggestion {
+	var pending []review.Suggestion
+	for _, s := range t.Suggestions {
+		if s.Status == review.StatusPending {
+			pending = append(pending, s)
+		}
+	}
+	return pending
+}
+
+// Resolve marks suggestions as accepted or dismissed and closes the task
+// once nothing is pending.
+func (t *ReviewTask) Resolve(ids map[string]bool, status string) {
+	for i := range t.Suggestions {
+		if ids[t.Suggestions[i].ID] {
+			t.Suggestions[i].Status = status
+		}
+	}
+	if len(t.Pending()) == 0 && t.Status == ReviewOpen {
+		now := time.Now().UTC()
+		t.Status = ReviewClosed
+		t.ClosedAt = &now
+	}
+}
+
+type ReviewFilter struct {
+	Status     string
+	IncidentID string
+}
+
+const reviewTaskColumns = `id, profile_id, incident_id, trigger, title, status, suggestions, created_at, updated_at, closed_at`
+
+func scanReviewTask(row rowScanner) (*ReviewTask, error) {
+	var t ReviewTask
+	var incidentID, closedAt sql.NullString
+	var suggestions, createdAt, updatedAt string
+
+	err := row.Scan(&t.ID, &t.ProfileID, &incidentID, &t.Trigger, &t.Title, &t.Status, &suggestions,
+		&createdAt, &updatedAt, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	t.IncidentID = nullableString(incidentID)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	t.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	t.ClosedAt = nullableTime(closedAt)
+
+	if err := json.Unmarshal([]byte(suggestions), &t.Suggestions); err != nil {
+		return nil, fmt.Errorf("invalid suggestions: %w", err)
+	}
+
+	return &t, nil
+}
+
+// CreateReviewTask stores a new open task for a profile, filling in its ID
+// and timestamps.
+func (db *DB) CreateReviewTask(profileID string, task *ReviewTask) error {
+	now := time.Now().UTC()
+	task.ID = uuid.New().String()
+	task.ProfileID = profileID
+	task.Status = ReviewOpen
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	if task.Suggestions == nil {
+		task.Suggestions = []review.Suggestion{}
+	}
+
+	suggestions, err := json.Marshal(task.Suggestions)
+	if err != nil {
+		return fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+
+	var incidentID interface{}
+	if task.IncidentID != nil {
+		incidentID = *task.IncidentID
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO review_tasks (id, profile_id, incident_id, trigger, title, status, suggestions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, task.ID, profileID, incidentID, task.Trigger, task.Title, task.Status, string(suggestions),
+		now.Format(time.RFC3339), now.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to create review task: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetReviewTask(profileID, id string) (*ReviewTask, error) {
+	row := db.conn.QueryRow(`SELECT `+reviewTaskColumns+` FROM review_tasks WHERE profile_id = ? AND id = ?`, profileID, id)
+
+	task, err := scanReviewTask(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review task: %w", err)
+	}
+
+	return task, nil
+}
+
+// ListReviewTasks returns a profile's review tasks, newest first.
+func (db *DB) ListReviewTasks(profileID string, filter ReviewFilter) ([]ReviewTask, error) {
+	query := `SELECT ` + reviewTaskColumns + ` FROM review_tasks WHERE profile_id = ?`
+	args := []interface{}{profileID}
+
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.IncidentID != "" {
+		query += ` AND incident_id = ?`
+		args = append(args, filter.IncidentID)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []ReviewTask{}
+	for rows.Next() {
+		task, err := scanReviewTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review task: %w", err)
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// UpdateReviewTask writes a task's status and suggestions.
+func (db *DB) UpdateReviewTask(task *ReviewTask) error {
+	return updateReviewTask(db.conn, task)
+}
+
+// AcceptReview writes the sections produced by accepting suggestions and
+// the task with their new status in one transaction, so a task is never
+// left open with its changes applied. Each section is only written if it is
+// still at its expected version; otherwise a *VersionConflictError is
+// returned and nothing changes.
+func (db *DB) AcceptReview(task *ReviewTask, sections map[string]*string, expected map[string]int, source string) (SectionWrites, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	writes, err := updateSectionsTx(tx, task.ProfileID, sections, expected, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateReviewTask(tx, task); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit review: %w", err)
+	}
+
+	return writes, nil
+}
+
+// execer is a database connection or transaction.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func updateReviewTask(conn execer, task *ReviewTask) error {
+	task.UpdatedAt = time.Now().UTC()
+
+	suggestions, err := json.Marshal(task.Suggestions)
+	if err != nil {
+		return fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+
+	var closedAt interface{}
+	if task.ClosedAt != nil {
+		closedAt = task.ClosedAt.UTC().Format(time.RFC3339)
+	}
+
+	result, err := conn.Exec(`
+		UPDATE review_tasks SET status = ?, suggestions = ?, updated_at = ?, closed_at = ?
+		WHERE profile_id = ? AND id = ?
+	`, task.Status, string(suggestions), task.UpdatedAt.Format(time.RFC3339), closedAt, task.ProfileID, task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update review task: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// IncidentsWithoutOpenReview returns the profile's unresolved incidents
+// that have no open review task.
+func (db *DB) IncidentsWithoutOpenReview(profileID string) ([]Incident, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+incidentColumns+` FROM incidents
+		WHERE profile_id = ? AND status IN (?, ?)
+			AND id NOT IN (SELECT incident_id FROM review_tasks WHERE profile_id = ? AND status = ? AND incident_id IS NOT NULL)
+		ORDER BY occurred_at
+	`, profileID, IncidentOpen, IncidentInvestigating, profileID, ReviewOpen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []Incident
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		incidents = append(incidents, *incident)
+	}
+
+	return incidents, rows.Err()
+}
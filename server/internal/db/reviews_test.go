@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/review"
+)
+
+func TestAcceptReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int
+		conflict bool
+	}{
+		{name: "current section", expected: 1},
+		{name: "stale section", expected: 0, conflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDB(t)
+
+			profile, err := database.CreateProfile("Test", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			threats := `{"threats": [{"threat_id": "t", "likelihood": "low"}]}`
+			if _, err := database.UpdateSections(profile.ID, map[string]*string{"threats": &threats}, nil, SourceAPI); err != nil {
+				t.Fatal(err)
+			}
+
+			task := &ReviewTask{Trigger: TriggerIncident, Title: "Review", Suggestions: []review.Suggestion{
+				{ID: "suggestion-1", Section: "threats", ItemID: "t", Status: review.StatusPending},
+			}}
+			if err := database.CreateReviewTask(profile.ID, task); err != nil {
+				t.Fatal(err)
+			}
+
+			raised := `{"threats": [{"threat_id": "t", "likelihood": "medium"}]}`
+			task.Resolve(map[string]bool{"suggestion-1": true}, review.StatusAccepted)
+			_, err = database.AcceptReview(task, map[string]*string{"threats": &raised}, map[string]int{"threats": tt.expected}, SourceReview)
+
+			var conflict *VersionConflictError
+			if tt.conflict != errors.As(err, &conflict) {
+				t.Fatalf("err = %v, want conflict %v", err, tt.conflict)
+			} else if !tt.conflict && err != nil {
+				t.Fatal(err)
+			}
+
+			// The section and the task change together or not at all.
+			stored, err := database.GetReviewTask(profile.ID, task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			section, err := database.GetSection(profile.ID, "threats")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantStatus, wantSection := ReviewClosed, raised
+			if tt.conflict {
+				wantStatus, wantSection = ReviewOpen, threats
+			}
+			if stored.Status != wantStatus || *section != wantSection {
+				t.Errorf("task %s with threats %s, want %s with %s", stored.Status, *section, wantStatus, wantSection)
+			}
+		})
+	}
+}
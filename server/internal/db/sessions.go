@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Session is a login. Only a hash of the session token is stored, so a copy
+// of the database cannot be used to sign in.
+type Session struct {
+	TokenHash  string    `json:"-"`
+	UserID     string    `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func (db *DB) CreateSession(tokenHash, userID string, expiresAt time.Time) (*Session, error) {
+	now := time.Now().UTC()
+	nowStr := now.Format(time.RFC3339)
+
+	_, err := db.conn.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tokenHash, userID, nowStr, expiresAt.UTC().Format(time.RFC3339), nowStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if _, err := db.conn.Exec(`UPDATE users SET last_login_at = ? WHERE id = ?`, nowStr, userID); err != nil {
+		return nil, fmt.Errorf("failed to record login: %w", err)
+	}
+
+	return &Session{
+		TokenHash:  tokenHash,
+		UserID:     userID,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt.UTC(),
+		LastUsedAt: now,
+	}, nil
+}
+
+// GetSession returns an unexpired session and records that it was used.
+// Expired sessions are deleted when they are presented.
+func (db *DB) GetSession(tokenHash string) (*Session, error) {
+	var s Session
+	var createdAt, expiresAt, lastUsedAt string
+
+	err := db.conn.QueryRow(`
+		SELECT token_hash, user_id, created_at, expires_at, last_used_at
+		FROM sessions WHERE token_hash = ?
+	`, tokenHash).Scan(&s.TokenHash, &s.UserID, &createdAt, &expiresAt, &lastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	s.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	s.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+
+	now := time.Now().UTC()
+	if !now.Before(s.ExpiresAt) {
+		if err := db.DeleteSession(tokenHash); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	s.LastUsedAt = now
+	if _, err := db.conn.Exec(`UPDATE sessions SET last_used_at = ? WHERE token_hash = ?`, now.Format(time.RFC3339), tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return &s, nil
+}
+
+func (db *DB) DeleteSession(tokenHash string) error {
+	if _, err := db.conn.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSessions signs a user out everywhere, optionally keeping one
+// session (the caller's own) alive.
+func (db *DB) DeleteUserSessions(userID, exceptTokenHash string) (int, error) {
+	result, err := db.conn.Exec(`DELETE FROM sessions WHERE user_id = ? AND token_hash != ?`, userID, exceptTokenHash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+func (db *DB) DeleteExpiredSessions() error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := db.conn.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordHash returns the stored password hash for a user, or "" if no
+// password has been set.
+func (db *DB) GetPasswordHash(userID string) (string, error) {
+	var hash sql.NullString
+	err := db.conn.QueryRow(`SELECT password_hash FROM users WHERE id = ?`, userID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	return hash.String, nil
+}
+
+func (db *DB) SetPasswordHash(userID, hash string) error {
+	result, err := db.conn.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, hash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
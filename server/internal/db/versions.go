@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AnyVersion disables the optimistic concurrency check on a section write.
+const AnyVersion = -1
+
+// SectionWrite describes the versions produced by a section update.
+type SectionWrite struct {
+	Version        int `json:"version"`
+	SectionVersion int `json:"section_version"`
+}
+
+// SectionWrites are the writes of a multi-section update, keyed by section.
+type SectionWrites map[string]*SectionWrite
+
+// Version is the profile version after the last of the writes.
+func (w SectionWrites) Version() int {
+	version := 0
+	for _, write := range w {
+		if write.Version > version {
+			version = write.Version
+		}
+	}
+	return version
+}
+
+// VersionConflictError is returned when a conditional write finds the
+// section at a different version than the caller expected.
+type VersionConflictError struct {
+	Section string
+	Current int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("section %s is at version %d", e.Section, e.Current)
+}
+
+// GetSectionVersions returns the version counter of every section that has
+// been written. Sections missing from the map are at version 0.
+func (db *DB) GetSectionVersions(profileID string) (map[string]int, error) {
+	return sectionVersions(db.conn, profileID)
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func sectionVersions(conn queryer, profileID string) (map[string]int, error) {
+	rows, err := conn.Query(`SELECT section, version FROM section_versions WHERE profile_id = ?`, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get section versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[string]int)
+	for rows.Next() {
+		var section string
+		var version int
+		if err := rows.Scan(&section, &version); err != nil {
+			return nil, fmt.Errorf("failed to scan section version: %w", err)
+		}
+		versions[section] = version
+	}
+
+	return versions, rows.Err()
+}
+
+func sectionVersionTx(tx *sql.Tx, profileID, section string) (int, error) {
+	var version int
+	err := tx.QueryRow(`SELECT version FROM section_versions WHERE profile_id = ? AND section = ?`, profileID, section).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get section version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,123 @@
+// Package jsondiff computes structural differences between decoded JSON
+// documents. Changes are addressed with RFC 6901 JSON Pointers so they can be
+// rendered for review or turned into JSON Patch operations.
+package jsondiff
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Change struct {
+	Op       string      `json:"op"`
+	Path     string      `json:"path"`
+	OldValue interface{} `json:"old_value,omitempty"`
+	NewValue interface{} `json:"new_value,omitempty"`
+}
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Diff returns the changes that turn a into b. Both values are expected to be
+// the output of json.Unmarshal into an interface{}. Arrays are compared
+// position by position, so inserting an element in the middle of a list is
+// reported as a run of replacements followed by an add.
+func Diff(a, b interface{}) []Change {
+	changes := []Change{}
+	diff("", a, b, &changes)
+	return changes
+}
+
+func diff(path string, a, b interface{}, changes *[]Change) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		diffObjects(path, av, bv, changes)
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		diffArrays(path, av, bv, changes)
+		return
+	}
+
+	if a == nil && b == nil {
+		return
+	}
+	if a == nil {
+		*changes = append(*changes, Change{Op: OpAdd, Path: path, NewValue: b})
+		return
+	}
+	if b == nil {
+		*changes = append(*changes, Change{Op: OpRemove, Path: path, OldValue: a})
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Op: OpReplace, Path: path, OldValue: a, NewValue: b})
+	}
+}
+
+func diffObjects(path string, a, b map[string]interface{}, changes *[]Change) {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		childPath := path + "/" + EscapePointer(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inA:
+			*changes = append(*changes, Change{Op: OpAdd, Path: childPath, NewValue: bv})
+		case !inB:
+			*changes = append(*changes, Change{Op: OpRemove, Path: childPath, OldValue: av})
+		default:
+			diff(childPath, av, bv, changes)
+		}
+	}
+}
+
+func diffArrays(path string, a, b []interface{}, changes *[]Change) {
+	shared := len(a)
+	if len(b) < shared {
+		shared = len(b)
+	}
+
+	for i := 0; i < shared; i++ {
+		diff(path+"/"+strconv.Itoa(i), a[i], b[i], changes)
+	}
+	for i := shared; i < len(b); i++ {
+		*changes = append(*changes, Change{Op: OpAdd, Path: path + "/" + strconv.Itoa(i), NewValue: b[i]})
+	}
+	// Remove trailing elements from the end so the indexes stay valid when
+	// the changes are applied in order.
+	for i := len(a) - 1; i >= shared; i-- {
+		*changes = append(*changes, Change{Op: OpRemove, Path: path + "/" + strconv.Itoa(i), OldValue: a[i]})
+	}
+}
+
+// EscapePointer escapes a single reference token for use in a JSON Pointer.
+func EscapePointer(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
@@ -0,0 +1,142 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+func decode(t *testing.T, data string) interface{} {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Change
+	}{
+		{
+			name: "equal",
+			a:    `{"a": [1, {"b": null}]}`,
+			b:    `{"a": [1, {"b": null}]}`,
+			want: []Change{},
+		},
+		{
+			name: "keys added, removed and replaced in key order",
+			a:    `{"c": 1, "b": "x", "a": true}`,
+			b:    `{"c": 2, "d": "y", "a": true}`,
+			want: []Change{
+				{Op: OpRemove, Path: "/b", OldValue: "x"},
+				{Op: OpReplace, Path: "/c", OldValue: 1.0, NewValue: 2.0},
+				{Op: OpAdd, Path: "/d", NewValue: "y"},
+			},
+		},
+		{
+			name: "nested",
+			a:    `{"assets": [{"asset_id": "a1", "value": "low"}]}`,
+			b:    `{"assets": [{"asset_id": "a1", "value": "high"}]}`,
+			want: []Change{{Op: OpReplace, Path: "/assets/0/value", OldValue: "low", NewValue: "high"}},
+		},
+		{
+			name: "array grown",
+			a:    `[1]`,
+			b:    `[1, 2, 3]`,
+			want: []Change{{Op: OpAdd, Path: "/1", NewValue: 2.0}, {Op: OpAdd, Path: "/2", NewValue: 3.0}},
+		},
+		{
+			name: "array shrunk from the end",
+			a:    `[1, 2, 3]`,
+			b:    `[1]`,
+			want: []Change{{Op: OpRemove, Path: "/2", OldValue: 3.0}, {Op: OpRemove, Path: "/1", OldValue: 2.0}},
+		},
+		{
+			name: "insertion is positional",
+			a:    `["x", "z"]`,
+			b:    `["x", "y", "z"]`,
+			want: []Change{{Op: OpReplace, Path: "/1", OldValue: "z", NewValue: "y"}, {Op: OpAdd, Path: "/2", NewValue: "z"}},
+		},
+		{
+			name: "type change",
+			a:    `{"a": {"b": 1}}`,
+			b:    `{"a": [1]}`,
+			want: []Change{{Op: OpReplace, Path: "/a", OldValue: map[string]interface{}{"b": 1.0}, NewValue: []interface{}{1.0}}},
+		},
+		{
+			name: "escaped keys",
+			a:    `{"a/b": 1, "c~d": 1}`,
+			b:    `{"a/b": 2, "c~d": 2}`,
+			want: []Change{
+				{Op: OpReplace, Path: "/a~1b", OldValue: 1.0, NewValue: 2.0},
+				{Op: OpReplace, Path: "/c~0d", OldValue: 1.0, NewValue: 2.0},
+			},
+		},
+		{
+			name: "from nothing",
+			a:    `null`,
+			b:    `{"a": 1}`,
+			want: []Change{{Op: OpAdd, Path: "", NewValue: map[string]interface{}{"a": 1.0}}},
+		},
+		{
+			name: "to nothing",
+			a:    `{"a": 1}`,
+			b:    `null`,
+			want: []Change{{Op: OpRemove, Path: "", OldValue: map[string]interface{}{"a": 1.0}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(decode(t, tt.a), decode(t, tt.b))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffAppliesAsPatch checks that the changes, applied in order as JSON
+// Patch operations, turn a into b.
+func TestDiffAppliesAsPatch(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{`{"a": 1, "b": [1, 2, 3], "c": {"d": "e"}}`, `{"a": 2, "b": [1], "c": {"f": "g"}, "h": null}`},
+		{`{"list": [{"id": 1}, {"id": 2}]}`, `{"list": [{"id": 2}, {"id": 1}, {"id": 3}]}`},
+		{`{"a~b": {"c/d": 1}}`, `{"a~b": {"c/d": 2}}`},
+	}
+
+	for _, tt := range tests {
+		changes := Diff(decode(t, tt.a), decode(t, tt.b))
+
+		operations := make([]map[string]interface{}, 0, len(changes))
+		for _, c := range changes {
+			op := map[string]interface{}{"op": c.Op, "path": c.Path}
+			if c.Op != OpRemove {
+				op["value"] = c.NewValue
+			}
+			operations = append(operations, op)
+		}
+		data, err := json.Marshal(operations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patched, err := patch.Apply([]byte(tt.a))
+		if err != nil {
+			t.Fatalf("applying %s: %v", data, err)
+		}
+		if !reflect.DeepEqual(decode(t, string(patched)), decode(t, tt.b)) {
+			t.Errorf("patched %s = %s, want %s", tt.a, patched, tt.b)
+		}
+	}
+}
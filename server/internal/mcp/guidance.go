@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+// Answer types for interview questions.
+const (
+	answerText        = "text"
+	answerSelect      = "select"
+	answerMultiselect = "multiselect"
+	answerScale       = "scale"
+)
+
+// sectionCompleteness reads a section's completeness from the profile, so
+// the server's configured weights apply.
+func (s *Server) sectionCompleteness(ctx context.Context, sess *session, section string) (*validator.SectionCompleteness, error) {
+	profile, _, err := s.getProfile(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+
+	if completeness := profile.completeness(section); completeness != nil {
+		return completeness, nil
+	}
+	return &validator.SectionCompleteness{Section: section, Missing: []validator.MissingField{}}, nil
+}
+
+// missingField is a field the section still lacks, described by its schema.
+type missingField struct {
+	validator.Property
+	Level string `json:"level"`
+}
+
+func (s *Server) getSectionGuidance(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	raw, ok := s.validator.Schema(section)
+	if !ok {
+		return mcp.NewToolResultError("Section has no schema"), nil
+	}
+	var schema struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Properties  map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+
+	completeness, err := s.sectionCompleteness(ctx, sess, section)
+	if err != nil {
+		return result(nil, err)
+	}
+
+	missing := []missingField{}
+	for _, field := range completeness.Missing {
+		property, _ := s.validator.Property(section, field.Path)
+		property.Path = field.Path
+		missing = append(missing, missingField{Property: property, Level: field.Level})
+	}
+
+	itemFields := make(map[string][]validator.Field)
+	for name, property := range schema.Properties {
+		if property.Type != "array" {
+			continue
+		}
+		if fields := s.validator.ItemFields(section, name); len(fields) > 0 {
+			itemFields[name] = fields
+		}
+	}
+
+	return jsonResult(map[string]interface{}{
+		"section":     section,
+		"title":       schema.Title,
+		"description": schema.Description,
+		"percentage":  completeness.Percentage,
+		"missing":     missing,
+		"item_fields": itemFields,
+		"schema":      "armor://schemas/" + section,
+	})
+}
+
+// question asks the user for one missing field. FieldPath is the JSON
+// Pointer the answer belongs at.
+type question struct {
+	ID         string   `json:"id"`
+	Question   string   `json:"question"`
+	Help       string   `json:"help,omitempty"`
+	FieldPath  string   `json:"field_path"`
+	AnswerType string   `json:"answer_type"`
+	Options    []string `json:"options,omitempty"`
+	Minimum    *float64 `json:"minimum,omitempty"`
+	Maximum    *float64 `json:"maximum,omitempty"`
+	Required   bool     `json:"required"`
+}
+
+// getInterviewQuestions turns the fields a section is missing into
+// questions, required fields first. Already answered fields are skipped
+// because completeness only lists what is missing.
+func (s *Server) getInterviewQuestions(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	completeness, err := s.sectionCompleteness(ctx, sess, section)
+	if err != nil {
+		return result(nil, err)
+	}
+
+	missing := append([]validator.MissingField(nil), completeness.Missing...)
+	sort.SliceStable(missing, func(i, j int) bool {
+		return missing[i].Level == validator.LevelRequired && missing[j].Level != validator.LevelRequired
+	})
+
+	questions := []question{}
+	for i, field := range missing {
+		property, _ := s.validator.Property(section, field.Path)
+
+		q := question{
+			ID:         "q" + strconv.Itoa(i+1),
+			Question:   questionText(field.Path, property.Title),
+			Help:       property.Description,
+			FieldPath:  field.Path,
+			AnswerType: answerText,
+			Required:   field.Level == validator.LevelRequired,
+		}
+		switch {
+		case len(property.Enum) > 0:
+			q.AnswerType = answerSelect
+			q.Options = property.Enum
+		case len(property.ItemEnum) > 0:
+			q.AnswerType = answerMultiselect
+			q.Options = property.ItemEnum
+		case (property.Type == "integer" || property.Type == "number") && property.Minimum != nil && property.Maximum != nil:
+			q.AnswerType = answerScale
+			q.Minimum, q.Maximum = property.Minimum, property.Maximum
+		}
+		questions = append(questions, q)
+	}
+
+	return jsonResult(map[string]interface{}{
+		"section":   section,
+		"questions": questions,
+	})
+}
+
+// questionText phrases a question for a field such as "/assets/2/owner",
+// naming the list item it belongs to.
+func questionText(path, title string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	name := title
+	if name == "" {
+		name = strings.ReplaceAll(segments[len(segments)-1], "_", " ")
+	}
+
+	for i := len(segments) - 1; i > 0; i-- {
+		if index, err := strconv.Atoi(segments[i]); err == nil {
+			list := strings.ReplaceAll(segments[i-1], "_", " ")
+			return fmt.Sprintf("Please provide the %s for %s item %d.", name, list, index+1)
+		}
+	}
+	return fmt.Sprintf("Please provide the %s.", name)
+}
+
+// addResources exposes the section schemas as armor://schemas/<section>.
+func (s *Server) addResources() {
+	for _, section := range sectionNames() {
+		raw, ok := s.validator.Schema(section)
+		if !ok {
+			continue
+		}
+		uri := "armor://schemas/" + section
+		text := string(raw)
+
+		s.mcp.AddResource(mcp.NewResource(uri, section+" schema",
+			mcp.WithResourceDescription("JSON schema of the "+section+" section"),
+			mcp.WithMIMEType("application/schema+json"),
+		), func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{
+				URI:      uri,
+				MIMEType: "application/schema+json",
+				Text:     text,
+			}}, nil
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Package mcp serves a profile to AI agents over the Model Context Protocol.
+// Agents authenticate with an organization's API key and work on that
+// organization's profile. Tools that read or change the profile go through
+// the REST handler in-process, so agents get the same validation, redaction,
+// derived fields and permission checks as any other client, and their
+// changes are recorded with source "agent".
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+const instructions = `ARMOR holds one organization's threat model profile: mission, assets, adversaries, threats, risks and mitigations, plus optional modules. Start with armor_get_overview, then read sections with armor_get_section. Use the guidance tools to work out what is missing and what to ask. Changes are proposed with armor_propose_change or armor_propose_add_item and wait for a person to accept them; read-write keys may also save directly with armor_update_section. Check data with armor_validate before proposing it.`
+
+// ErrUnauthorized is returned when an API key is unknown, expired or not
+// tied to an organization with a profile.
+var ErrUnauthorized = errors.New("invalid API key")
+
+// Server is an MCP server for the profiles in a database.
+type Server struct {
+	db        *db.DB
+	validator *validator.Validator
+	api       http.Handler
+	mcp       *server.MCPServer
+}
+
+// New creates an MCP server. Profile tools are served by handler, which is
+// the REST API.
+func New(database *db.DB, val *validator.Validator, handler http.Handler) *Server {
+	s := &Server{
+		db:        database,
+		validator: val,
+		api:       handler,
+		mcp: server.NewMCPServer("armor", "1.0.0",
+			server.WithToolCapabilities(false),
+			server.WithResourceCapabilities(false, false),
+			server.WithInstructions(instructions),
+			server.WithRecovery(),
+		),
+	}
+
+	s.addTools()
+	s.addResources()
+
+	return s
+}
+
+// session is the API key an agent connected with and the organization it
+// belongs to.
+type session struct {
+	key          string
+	apiKey       *db.APIKey
+	organization *db.Organization
+}
+
+func (sess *session) profilePath() string {
+	return "/api/profiles/" + sess.organization.ProfileID
+}
+
+type sessionKey struct{}
+
+func withSession(ctx context.Context, sess *session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sess)
+}
+
+func sessionFrom(ctx context.Context) *session {
+	sess, _ := ctx.Value(sessionKey{}).(*session)
+	return sess
+}
+
+// authenticate resolves an API key to a session. Keys are looked up by the
+// same hash the REST API stores.
+func (s *Server) authenticate(key string) (*session, error) {
+	if !strings.HasPrefix(key, "armor_") {
+		return nil, ErrUnauthorized
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	apiKey, err := s.db.UseAPIKey(hex.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, ErrUnauthorized
+	}
+
+	organization, err := s.db.GetOrganization(apiKey.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if organization == nil || organization.ProfileID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	return &session{key: key, apiKey: apiKey, organization: organization}, nil
+}
+
+// ServeStdio serves one agent on standard input and output, authenticated
+// with the given API key for the whole session.
+func (s *Server) ServeStdio(key string) error {
+	sess, err := s.authenticate(key)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("MCP session for organization %s (%s key %s)", sess.organization.Slug, sess.apiKey.Permission, sess.apiKey.Prefix)
+	return server.ServeStdio(s.mcp, server.WithStdioContextFunc(func(ctx context.Context) context.Context {
+		return withSession(ctx, sess)
+	}))
+}
+
+// Handler serves the HTTP transports: SSE at /sse with messages posted to
+// /message, and streamable HTTP at /mcp. Every request must carry an API
+// key as a bearer token.
+func (s *Server) Handler() http.Handler {
+	sse := server.NewSSEServer(s.mcp)
+	streamable := server.NewStreamableHTTPServer(s.mcp)
+
+	mux := http.NewServeMux()
+	mux.Handle("/sse", sse.SSEHandler())
+	mux.Handle("/message", sse.MessageHandler())
+	mux.Handle("/mcp", streamable)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		sess, err := s.authenticate(key)
+		if errors.Is(err, ErrUnauthorized) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mux.ServeHTTP(w, r.WithContext(withSession(r.Context(), sess)))
+	})
+}
+
+// toolHandler is a tool implementation for an authenticated session.
+type toolHandler func(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// addTool registers a tool whose calls are refused without a session and
+// logged with the key that made them.
+func (s *Server) addTool(tool mcp.Tool, handler toolHandler) {
+	s.mcp.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sess := sessionFrom(ctx)
+		if sess == nil {
+			return mcp.NewToolResultError("Unauthorized"), nil
+		}
+
+		log.Printf("MCP %s by key %s for organization %s", tool.Name, sess.apiKey.Prefix, sess.organization.Slug)
+		return handler(ctx, sess, req)
+	})
+}
+
+// apiError is a REST response with an error status. Its message is the
+// response body, which agents can act on as they would over HTTP.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.status, http.StatusText(e.status), e.message)
+}
+
+// recorder collects a REST response in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header { return rec.header }
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// call makes a REST request on behalf of a session and returns the response
+// body. Extra headers, such as If-Match, are given as name, value pairs.
+func (s *Server) call(ctx context.Context, sess *session, method, path string, body interface{}, headers ...string) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, path, reader)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+sess.key)
+	r.Header.Set("X-Armor-Source", db.SourceAgent)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := &recorder{header: make(http.Header)}
+	s.api.ServeHTTP(rec, r)
+
+	if rec.status >= 400 {
+		return nil, &apiError{status: rec.status, message: strings.TrimSpace(rec.body.String())}
+	}
+	return rec.body.Bytes(), nil
+}
+
+// result turns a response body or error into a tool result. REST errors are
+// reported to the agent as tool errors; anything else fails the call.
+func result(body []byte, err error) (*mcp.CallToolResult, error) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return mcp.NewToolResultError(apiErr.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(body)), nil
+}
+
+// jsonResult encodes a value as a tool result.
+func jsonResult(value interface{}) (*mcp.CallToolResult, error) {
+	encoded, err := json.Marshal(value)
+	return result(encoded, err)
+}
@@ -0,0 +1,300 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/HyphaGroup/armor/server/internal/api"
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+// restLog records the REST requests tools make.
+type restLog struct {
+	mu       sync.Mutex
+	requests []string
+	sources  []string
+}
+
+func (l *restLog) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		l.requests = append(l.requests, r.Method+" "+r.URL.RequestURI())
+		l.sources = append(l.sources, r.Header.Get("X-Armor-Source"))
+		l.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *restLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests, l.sources = nil, nil
+}
+
+type testEnv struct {
+	server   *Server
+	database *db.DB
+	org      *db.Organization
+	log      *restLog
+	sessions map[string]*session
+}
+
+// newTestEnv serves an organization's profile over MCP through the real
+// REST API, recording every request the tools make.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("ARMOR_PASSWORD", "test-password")
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "armor.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	schemas, err := filepath.Abs(filepath.Join("..", "..", "..", "schemas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := validator.New(schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	org, err := database.CreateOrganization("Acme", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &restLog{}
+	return &testEnv{
+		server:   New(database, val, log.wrap(api.NewServer(database, val))),
+		database: database,
+		org:      org,
+		log:      log,
+		sessions: make(map[string]*session),
+	}
+}
+
+// session authenticates with an API key of a permission, issuing the key
+// on first use.
+func (env *testEnv) session(t *testing.T, permission string) *session {
+	t.Helper()
+	if sess := env.sessions[permission]; sess != nil {
+		return sess
+	}
+
+	key := "armor_" + permission + "-key"
+	sum := sha256.Sum256([]byte(key))
+	if _, err := env.database.CreateAPIKey(env.org.ID, permission, key[:12], hex.EncodeToString(sum[:]), permission, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := env.server.authenticate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.sessions[permission] = sess
+	return sess
+}
+
+// callTool calls a tool as a session and returns its result text.
+func (env *testEnv) callTool(t *testing.T, sess *session, name string, args map[string]interface{}) (string, bool) {
+	t.Helper()
+
+	tool := env.server.mcp.GetTool(name)
+	if tool == nil {
+		t.Fatalf("tool %s is not registered", name)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	result, err := tool.Handler(withSession(context.Background(), sess), req)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	var text strings.Builder
+	for _, content := range result.Content {
+		if content, ok := content.(mcp.TextContent); ok {
+			text.WriteString(content.Text)
+		}
+	}
+	return text.String(), result.IsError
+}
+
+func TestToolsCallREST(t *testing.T) {
+	env := newTestEnv(t)
+	sess := env.session(t, db.PermissionReadWrite)
+	profile := "/api/profiles/" + env.org.ProfileID
+
+	assets := map[string]interface{}{"assets": []interface{}{map[string]interface{}{
+		"asset_id": "asset-a1", "name": "Donor list", "category": "donor_supporter_data", "value": "medium",
+	}}}
+	if _, isError := env.callTool(t, sess, "armor_update_section", map[string]interface{}{"section": "assets", "data": assets}); isError {
+		t.Fatal("saving the assets failed")
+	}
+
+	incident := &db.Incident{Title: "Laptop stolen", Description: "Taxi", Type: "physical", Severity: "medium",
+		Status: db.IncidentOpen, OccurredAt: time.Now(), DiscoveredAt: time.Now()}
+	if err := env.database.CreateIncident(env.org.ProfileID, incident); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tool string
+		args map[string]interface{}
+		want []string
+	}{
+		{tool: "armor_get_overview", want: []string{"GET " + profile, "GET " + profile + "/proposals", "GET " + profile + "/incidents?"}},
+		{tool: "armor_get_section", args: map[string]interface{}{"section": "assets"}, want: []string{"GET " + profile}},
+		{tool: "armor_get_full_profile", want: []string{"GET " + profile}},
+		{tool: "armor_get_gaps", want: []string{"GET " + profile + "/analysis/gaps"}},
+		{tool: "armor_analyze_coverage", want: []string{"GET " + profile + "/analysis/coverage"}},
+		{tool: "armor_validate", args: map[string]interface{}{"section": "assets", "data": assets}, want: []string{"POST " + profile + "/assets/validate"}},
+		{tool: "armor_list_proposals", args: map[string]interface{}{"status": "all"}, want: []string{"GET " + profile + "/proposals?status=all"}},
+		{
+			tool: "armor_propose_change",
+			args: map[string]interface{}{"section": "assets", "rationale": "More valuable", "patch": []interface{}{
+				map[string]interface{}{"op": "replace", "path": "/assets/0/value", "value": "high"},
+			}},
+			want: []string{"POST " + profile + "/proposals"},
+		},
+		{
+			tool: "armor_propose_add_item",
+			args: map[string]interface{}{"section": "assets", "list_path": "assets", "rationale": "Missing", "item": map[string]interface{}{
+				"name": "Payroll", "category": "financial_data", "value": "high",
+			}},
+			want: []string{"GET " + profile + "/assets", "POST " + profile + "/proposals"},
+		},
+		{tool: "armor_update_section", args: map[string]interface{}{"section": "assets", "data": assets, "expected_version": 1}, want: []string{"PUT " + profile + "/assets"}},
+		{tool: "armor_list_incidents", args: map[string]interface{}{"limit": 5}, want: []string{"GET " + profile + "/incidents?limit=5"}},
+		{tool: "armor_get_incident", args: map[string]interface{}{"id": incident.ID}, want: []string{"GET " + profile + "/incidents/" + incident.ID}},
+		{
+			tool: "armor_create_incident",
+			args: map[string]interface{}{"title": "Phishing", "description": "Fake login page", "type": "phishing",
+				"severity": "high", "occurred_at": "2026-01-05T10:00:00Z", "affected_assets": []interface{}{"asset-a1"}},
+			want: []string{"POST " + profile + "/incidents"},
+		},
+		{tool: "armor_suggest_incident_updates", args: map[string]interface{}{"incident_id": incident.ID}, want: []string{"GET " + profile + "/incidents/" + incident.ID + "/suggestions"}},
+		{tool: "armor_get_section_guidance", args: map[string]interface{}{"section": "assets"}, want: []string{"GET " + profile}},
+		{tool: "armor_get_interview_questions", args: map[string]interface{}{"section": "assets"}, want: []string{"GET " + profile}},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.tool] = true
+		t.Run(tt.tool, func(t *testing.T) {
+			env.log.reset()
+			if text, isError := env.callTool(t, sess, tt.tool, tt.args); isError {
+				t.Fatalf("tool error: %s", text)
+			}
+
+			for _, want := range tt.want {
+				found := false
+				for _, request := range env.log.requests {
+					if request == want || strings.HasSuffix(want, "?") && strings.HasPrefix(request, want) {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("requests = %v, want %s", env.log.requests, want)
+				}
+			}
+			for i, source := range env.log.sources {
+				if source != db.SourceAgent {
+					t.Errorf("%s has X-Armor-Source %q, want %q", env.log.requests[i], source, db.SourceAgent)
+				}
+			}
+		})
+	}
+
+	for name := range env.server.mcp.ListTools() {
+		if !covered[name] {
+			t.Errorf("tool %s is not covered", name)
+		}
+	}
+}
+
+func TestToolPermissions(t *testing.T) {
+	env := newTestEnv(t)
+	if _, isError := env.callTool(t, env.session(t, db.PermissionReadWrite), "armor_update_section",
+		map[string]interface{}{"section": "assets", "data": map[string]interface{}{"assets": []interface{}{}}}); isError {
+		t.Fatal("saving the assets failed")
+	}
+
+	patch := map[string]interface{}{"section": "assets", "rationale": "Track the donor list", "patch": []interface{}{
+		map[string]interface{}{"op": "add", "path": "/assets/-", "value": map[string]interface{}{
+			"asset_id": "asset-a1", "name": "Donor list", "category": "donor_supporter_data", "value": "medium",
+		}},
+	}}
+	update := map[string]interface{}{"section": "assets", "data": map[string]interface{}{"assets": []interface{}{}}}
+	incident := map[string]interface{}{"title": "Phishing", "description": "Fake login page", "type": "phishing",
+		"severity": "high", "occurred_at": "2026-01-05T10:00:00Z"}
+
+	tests := []struct {
+		permission string
+		tool       string
+		args       map[string]interface{}
+		allowed    bool
+	}{
+		{permission: db.PermissionRead, tool: "armor_propose_change", args: patch},
+		{permission: db.PermissionRead, tool: "armor_update_section", args: update},
+		{permission: db.PermissionReadPropose, tool: "armor_propose_change", args: patch, allowed: true},
+		{permission: db.PermissionReadPropose, tool: "armor_update_section", args: update},
+		{permission: db.PermissionReadPropose, tool: "armor_create_incident", args: incident},
+		{permission: db.PermissionReadWrite, tool: "armor_create_incident", args: incident, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission+" "+tt.tool, func(t *testing.T) {
+			sess := env.session(t, tt.permission)
+			env.log.reset()
+			text, isError := env.callTool(t, sess, tt.tool, tt.args)
+			if isError == tt.allowed {
+				t.Fatalf("result = %s (error %v), want allowed %v", text, isError, tt.allowed)
+			}
+			if !tt.allowed && len(env.log.requests) > 0 {
+				t.Errorf("a refused call reached the REST API: %v", env.log.requests)
+			}
+		})
+	}
+}
+
+func TestRESTErrorsBecomeToolErrors(t *testing.T) {
+	env := newTestEnv(t)
+	sess := env.session(t, db.PermissionReadWrite)
+
+	tests := []struct {
+		name string
+		tool string
+		args map[string]interface{}
+		want string
+	}{
+		{name: "unknown incident", tool: "armor_get_incident", args: map[string]interface{}{"id": "missing"}, want: "404"},
+		{name: "invalid section data", tool: "armor_update_section", args: map[string]interface{}{"section": "assets", "data": map[string]interface{}{"assets": "nope"}}, want: "400"},
+		{name: "stale expected version", tool: "armor_update_section", args: map[string]interface{}{"section": "assets", "data": map[string]interface{}{"assets": []interface{}{}}, "expected_version": 7}, want: "412"},
+		{name: "patch that does not apply", tool: "armor_propose_change", args: map[string]interface{}{"section": "assets", "rationale": "x", "patch": []interface{}{
+			map[string]interface{}{"op": "remove", "path": "/assets/3"},
+		}}, want: "422"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := env.callTool(t, sess, tt.tool, tt.args)
+			if !isError || !strings.HasPrefix(text, tt.want) {
+				t.Errorf("result = %q (error %v), want a tool error starting with %s", text, isError, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/db"
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+func sectionNames() []string {
+	names := make([]string, 0, len(catalog.ValidSections))
+	for name := range catalog.ValidSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sectionParam() mcp.ToolOption {
+	return mcp.WithString("section", mcp.Required(), mcp.Enum(sectionNames()...), mcp.Description("Profile section"))
+}
+
+func (s *Server) addTools() {
+	s.addTool(mcp.NewTool("armor_get_overview",
+		mcp.WithDescription("Organization, profile completeness, last update, pending proposals and open incidents."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getOverview)
+
+	s.addTool(mcp.NewTool("armor_get_section",
+		mcp.WithDescription("One profile section with its version and completeness."),
+		sectionParam(),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getSection)
+
+	s.addTool(mcp.NewTool("armor_get_full_profile",
+		mcp.WithDescription("Every section of the profile. Large; prefer armor_get_section."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.passthrough(""))
+
+	s.addTool(mcp.NewTool("armor_get_gaps",
+		mcp.WithDescription("Quality gaps in the profile and suggested next steps."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.passthrough("/analysis/gaps"))
+
+	s.addTool(mcp.NewTool("armor_analyze_coverage",
+		mcp.WithDescription("Assets without threats, threats without risks, risks without mitigations and similar coverage holes."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.passthrough("/analysis/coverage"))
+
+	s.addTool(mcp.NewTool("armor_validate",
+		mcp.WithDescription("Check section data against the schema and cross-section references without saving or proposing it."),
+		sectionParam(),
+		mcp.WithObject("data", mcp.Required(), mcp.Description("Complete section value")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.validate)
+
+	s.addTool(mcp.NewTool("armor_list_proposals",
+		mcp.WithDescription("Proposals with the diff each would make to the section now."),
+		mcp.WithString("status", mcp.Enum("pending", "accepted", "rejected", "all"), mcp.Description("Defaults to pending")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.listProposals)
+
+	s.addTool(mcp.NewTool("armor_propose_change",
+		mcp.WithDescription("Propose a change to a section as a JSON Patch (RFC 6902). A person accepts or rejects it."),
+		sectionParam(),
+		mcp.WithArray("patch", mcp.Required(), mcp.Description(`JSON Patch operations, e.g. [{"op": "replace", "path": "/threats/0/likelihood", "value": "high"}]`),
+			mcp.Items(map[string]interface{}{"type": "object"})),
+		mcp.WithString("rationale", mcp.Required(), mcp.Description("Why the change should be made")),
+		mcp.WithDestructiveHintAnnotation(false),
+	), s.proposeChange)
+
+	s.addTool(mcp.NewTool("armor_propose_add_item",
+		mcp.WithDescription("Propose adding one item to a list in a section. Items of referenced lists get an ID if they have none."),
+		sectionParam(),
+		mcp.WithString("list_path", mcp.Required(), mcp.Description(`Slash separated path of the list, e.g. "assets" or "mitigations/0/actions"`)),
+		mcp.WithObject("item", mcp.Required(), mcp.Description("The item to add")),
+		mcp.WithString("rationale", mcp.Required(), mcp.Description("Why the item should be added")),
+		mcp.WithDestructiveHintAnnotation(false),
+	), s.proposeAddItem)
+
+	s.addTool(mcp.NewTool("armor_update_section",
+		mcp.WithDescription("Save a whole section directly. Only read-write keys may do this; others must propose changes."),
+		sectionParam(),
+		mcp.WithObject("data", mcp.Required(), mcp.Description("Complete section value")),
+		mcp.WithNumber("expected_version", mcp.Description("Refuse the save if the section is no longer at this version")),
+		mcp.WithDestructiveHintAnnotation(true),
+	), s.updateSection)
+
+	s.addTool(mcp.NewTool("armor_list_incidents",
+		mcp.WithDescription("Incidents, most recent first, with counts of open and recent ones."),
+		mcp.WithArray("status", mcp.WithStringItems(mcp.Enum(keys(db.ValidIncidentStatuses)...))),
+		mcp.WithArray("severity", mcp.WithStringItems(mcp.Enum(keys(db.ValidSeverities)...))),
+		mcp.WithNumber("limit"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.listIncidents)
+
+	s.addTool(mcp.NewTool("armor_get_incident",
+		mcp.WithDescription("One incident with its response actions."),
+		mcp.WithString("id", mcp.Required()),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getIncident)
+
+	s.addTool(mcp.NewTool("armor_create_incident",
+		mcp.WithDescription("Log a security incident. This opens a review task for the linked assets and threats. Only read-write keys may do this."),
+		mcp.WithString("title", mcp.Required()),
+		mcp.WithString("description", mcp.Required()),
+		mcp.WithString("type", mcp.Required(), mcp.Enum(keys(db.ValidIncidentTypes)...)),
+		mcp.WithString("severity", mcp.Required(), mcp.Enum(keys(db.ValidSeverities)...)),
+		mcp.WithString("occurred_at", mcp.Required(), mcp.Description("RFC 3339 time, e.g. 2026-01-31T09:00:00Z")),
+		mcp.WithString("discovered_at", mcp.Description("RFC 3339 time; defaults to now")),
+		mcp.WithArray("affected_assets", mcp.WithStringItems(), mcp.Description("Asset IDs from the assets section")),
+		mcp.WithArray("related_threats", mcp.WithStringItems(), mcp.Description("Threat IDs from the threats section")),
+		mcp.WithDestructiveHintAnnotation(false),
+	), s.createIncident)
+
+	s.addTool(mcp.NewTool("armor_suggest_incident_updates",
+		mcp.WithDescription("Threat model changes suggested by an incident's linked assets and threats. Propose the ones that apply."),
+		mcp.WithString("incident_id", mcp.Required()),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.suggestIncidentUpdates)
+
+	s.addTool(mcp.NewTool("armor_get_section_guidance",
+		mcp.WithDescription("What a section captures, the fields of its items and what is still missing."),
+		sectionParam(),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getSectionGuidance)
+
+	s.addTool(mcp.NewTool("armor_get_interview_questions",
+		mcp.WithDescription("Questions to ask the user to fill in the fields a section is still missing, required ones first."),
+		sectionParam(),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getInterviewQuestions)
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// passthrough returns a tool that reads a profile path from the REST API.
+func (s *Server) passthrough(path string) toolHandler {
+	return func(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return result(s.call(ctx, sess, "GET", sess.profilePath()+path, nil))
+	}
+}
+
+// rawArgument returns a tool argument re-encoded as JSON.
+func rawArgument(req mcp.CallToolRequest, name string) (json.RawMessage, error) {
+	value, ok := req.GetArguments()[name]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("%s is required", name)
+	}
+	return json.Marshal(value)
+}
+
+// profileResponse is the part of GET /api/profiles/:id the tools use. The
+// sections themselves are picked out of the raw response.
+type profileResponse struct {
+	Name            string                        `json:"name"`
+	Version         int                           `json:"version"`
+	UpdatedAt       time.Time                     `json:"updated_at"`
+	Completeness    validator.ProfileCompleteness `json:"completeness"`
+	SectionVersions map[string]int                `json:"section_versions"`
+}
+
+// completeness returns a section's entry among the core sections or the
+// optional modules.
+func (p *profileResponse) completeness(section string) *validator.SectionCompleteness {
+	for _, list := range [][]validator.SectionCompleteness{p.Completeness.Sections, p.Completeness.Modules} {
+		for i := range list {
+			if list[i].Section == section {
+				return &list[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) getProfile(ctx context.Context, sess *session) (*profileResponse, map[string]json.RawMessage, error) {
+	body, err := s.call(ctx, sess, "GET", sess.profilePath(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var profile profileResponse
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &profile); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, err
+	}
+	return &profile, raw, nil
+}
+
+func (s *Server) getOverview(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	profile, _, err := s.getProfile(ctx, sess)
+	if err != nil {
+		return result(nil, err)
+	}
+
+	var proposals, incidents []json.RawMessage
+	body, err := s.call(ctx, sess, "GET", sess.profilePath()+"/proposals", nil)
+	if err == nil {
+		err = json.Unmarshal(body, &proposals)
+	}
+	if err != nil {
+		return result(nil, err)
+	}
+	body, err = s.call(ctx, sess, "GET", sess.profilePath()+"/incidents?status="+db.IncidentOpen+","+db.IncidentInvestigating, nil)
+	if err == nil {
+		err = json.Unmarshal(body, &incidents)
+	}
+	if err != nil {
+		return result(nil, err)
+	}
+
+	sections := make(map[string]float64)
+	for _, list := range [][]validator.SectionCompleteness{profile.Completeness.Sections, profile.Completeness.Modules} {
+		for _, completeness := range list {
+			sections[completeness.Section] = completeness.Percentage
+		}
+	}
+
+	return jsonResult(map[string]interface{}{
+		"organization": map[string]string{
+			"name": sess.organization.Name,
+			"slug": sess.organization.Slug,
+		},
+		"profile": map[string]interface{}{
+			"name":    profile.Name,
+			"version": profile.Version,
+		},
+		"permission": sess.apiKey.Permission,
+		"completeness": map[string]interface{}{
+			"overall":  profile.Completeness.Overall,
+			"sections": sections,
+		},
+		"last_updated":       profile.UpdatedAt,
+		"pending_proposals":  len(proposals),
+		"open_incidents":     len(incidents),
+		"available_sections": sectionNames(),
+	})
+}
+
+func (s *Server) getSection(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	profile, raw, err := s.getProfile(ctx, sess)
+	if err != nil {
+		return result(nil, err)
+	}
+
+	data := raw[section]
+	if data == nil {
+		data = json.RawMessage("null")
+	}
+
+	return jsonResult(map[string]interface{}{
+		"section":      section,
+		"data":         data,
+		"version":      profile.SectionVersions[section],
+		"completeness": profile.completeness(section),
+	})
+}
+
+func (s *Server) validate(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	data, err := rawArgument(req, "data")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return result(s.call(ctx, sess, "POST", sess.profilePath()+"/"+section+"/validate", data))
+}
+
+func (s *Server) listProposals(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := sess.profilePath() + "/proposals"
+	if status := req.GetString("status", ""); status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+	return result(s.call(ctx, sess, "GET", path, nil))
+}
+
+// canPropose reports whether a session may create proposals. Read-only keys
+// may not; the REST API refuses them too, but the message here is clearer.
+func canPropose(sess *session) bool {
+	return sess.apiKey.Permission == db.PermissionReadPropose || sess.apiKey.Permission == db.PermissionReadWrite
+}
+
+func (s *Server) proposeChange(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !canPropose(sess) {
+		return mcp.NewToolResultError("Read-only sessions cannot propose changes"), nil
+	}
+
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	rationale, err := req.RequireString("rationale")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	patch, err := rawArgument(req, "patch")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return result(s.call(ctx, sess, "POST", sess.profilePath()+"/proposals", map[string]interface{}{
+		"section":   section,
+		"patch":     patch,
+		"rationale": rationale,
+	}))
+}
+
+// proposeAddItem proposes appending an item to a list. A list that does not
+// exist yet is created with the item in it. Items of the lists other
+// sections reference, such as assets, are given an ID if they have none.
+func (s *Server) proposeAddItem(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !canPropose(sess) {
+		return mcp.NewToolResultError("Read-only sessions cannot propose changes"), nil
+	}
+
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	listPath, err := req.RequireString("list_path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	rationale, err := req.RequireString("rationale")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	item, ok := req.GetArguments()["item"].(map[string]interface{})
+	if !ok {
+		return mcp.NewToolResultError("item must be an object"), nil
+	}
+
+	listPath = strings.Trim(listPath, "/")
+	if list, field, kind, ok := validator.ItemIDField(section); ok && listPath == list {
+		if id, _ := item[field].(string); id == "" {
+			item[field] = kind + "-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+		}
+	}
+
+	body, err := s.call(ctx, sess, "GET", sess.profilePath()+"/"+section, nil)
+	if err != nil {
+		return result(nil, err)
+	}
+	var current struct {
+		Data interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &current); err != nil {
+		return nil, err
+	}
+
+	pointer := "/" + listPath
+	operation := map[string]interface{}{"op": "add", "path": pointer + "/-", "value": item}
+	if _, exists := lookup(current.Data, strings.Split(listPath, "/")); !exists {
+		operation = map[string]interface{}{"op": "add", "path": pointer, "value": []interface{}{item}}
+	}
+
+	body, err = s.call(ctx, sess, "POST", sess.profilePath()+"/proposals", map[string]interface{}{
+		"section":   section,
+		"patch":     []interface{}{operation},
+		"rationale": rationale,
+	})
+	if err != nil {
+		return result(nil, err)
+	}
+
+	return jsonResult(map[string]interface{}{
+		"proposal": json.RawMessage(body),
+		"item":     item,
+	})
+}
+
+// lookup follows slash separated keys and array indices through a decoded
+// JSON document.
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			doc = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func (s *Server) updateSection(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	switch sess.apiKey.Permission {
+	case db.PermissionReadWrite:
+	case db.PermissionReadPropose:
+		return mcp.NewToolResultError("Read-propose sessions can only create proposals; use armor_propose_change"), nil
+	default:
+		return mcp.NewToolResultError("Read-only sessions cannot change the profile"), nil
+	}
+
+	section, err := req.RequireString("section")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	data, err := rawArgument(req, "data")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var headers []string
+	if version, err := req.RequireInt("expected_version"); err == nil {
+		headers = append(headers, "If-Match", fmt.Sprintf(`"%s-%d"`, section, version))
+	}
+
+	return result(s.call(ctx, sess, "PUT", sess.profilePath()+"/"+section, data, headers...))
+}
+
+// listIncidents filters incidents through the REST API and counts the open
+// and recent ones across all of them.
+func (s *Server) listIncidents(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := url.Values{}
+	if statuses := req.GetStringSlice("status", nil); len(statuses) > 0 {
+		query.Set("status", strings.Join(statuses, ","))
+	}
+	if severities := req.GetStringSlice("severity", nil); len(severities) > 0 {
+		query.Set("severity", strings.Join(severities, ","))
+	}
+	if limit := req.GetInt("limit", 0); limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	body, err := s.call(ctx, sess, "GET", sess.profilePath()+"/incidents?"+query.Encode(), nil)
+	if err != nil {
+		return result(nil, err)
+	}
+	var incidents []db.Incident
+	if err := json.Unmarshal(body, &incidents); err != nil {
+		return nil, err
+	}
+
+	all := incidents
+	if len(query) > 0 {
+		body, err := s.call(ctx, sess, "GET", sess.profilePath()+"/incidents", nil)
+		if err != nil {
+			return result(nil, err)
+		}
+		if err := json.Unmarshal(body, &all); err != nil {
+			return nil, err
+		}
+	}
+
+	openCount, recentCount := 0, 0
+	since := time.Now().AddDate(0, 0, -30)
+	for _, incident := range all {
+		if incident.Status == db.IncidentOpen || incident.Status == db.IncidentInvestigating {
+			openCount++
+		}
+		if incident.OccurredAt.After(since) {
+			recentCount++
+		}
+	}
+
+	return jsonResult(map[string]interface{}{
+		"incidents":    incidents,
+		"open_count":   openCount,
+		"recent_count": recentCount,
+	})
+}
+
+func (s *Server) getIncident(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return result(s.call(ctx, sess, "GET", sess.profilePath()+"/incidents/"+url.PathEscape(id), nil))
+}
+
+// suggestIncidentUpdates works out the same suggestions a review task would
+// carry, without opening one.
+func (s *Server) suggestIncidentUpdates(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("incident_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return result(s.call(ctx, sess, "GET", sess.profilePath()+"/incidents/"+url.PathEscape(id)+"/suggestions", nil))
+}
+
+// createIncident logs an incident, which opens a review task for the assets
+// and threats it links. Only read-write keys may log incidents.
+func (s *Server) createIncident(ctx context.Context, sess *session, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if sess.apiKey.Permission != db.PermissionReadWrite {
+		return mcp.NewToolResultError("Only read-write sessions can log incidents"), nil
+	}
+
+	body := make(map[string]interface{})
+	for _, name := range []string{"title", "description", "type", "severity", "occurred_at"} {
+		value, err := req.RequireString(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		body[name] = value
+	}
+	if discoveredAt := req.GetString("discovered_at", ""); discoveredAt != "" {
+		body["discovered_at"] = discoveredAt
+	}
+	if assets := req.GetStringSlice("affected_assets", nil); len(assets) > 0 {
+		body["affected_assets"] = assets
+	}
+	if threats := req.GetStringSlice("related_threats", nil); len(threats) > 0 {
+		body["related_threats"] = threats
+	}
+
+	return result(s.call(ctx, sess, "POST", sess.profilePath()+"/incidents", body))
+}
@@ -0,0 +1,497 @@
+// Package register converts the array sections of a profile to and from
+// spreadsheet rows, one row per item, with a column per flat schema field.
+package register
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+// Register is a list of items inside a section. List is a slash separated
+// path, with "*" for the items of an enclosing array. Nested lists carry
+// the ID of their parent item in a leading column named after Parent.
+type Register struct {
+	Name    string
+	Section string
+	List    string
+	IDField string
+	Parent  string
+}
+
+var registers = []Register{
+	{Name: "assets", Section: "assets", List: "assets", IDField: "asset_id"},
+	{Name: "threats", Section: "threats", List: "threats", IDField: "threat_id"},
+	{Name: "risks", Section: "risks", List: "risks", IDField: "risk_id"},
+	{Name: "mitigations", Section: "mitigations", List: "mitigations", IDField: "mitigation_id"},
+	{Name: "mitigation-actions", Section: "mitigations", List: "mitigations/*/actions", Parent: "mitigation_id"},
+}
+
+func Lookup(name string) (Register, bool) {
+	for _, reg := range registers {
+		if reg.Name == name {
+			return reg, true
+		}
+	}
+	return Register{}, false
+}
+
+// ListSeparator joins list fields in a single cell.
+const ListSeparator = "; "
+
+// Table is a register laid out as a header and typed cell values.
+type Table struct {
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Export lays out the items of the register in section as a table with a
+// column per field.
+func (reg Register) Export(section *string, fields []validator.Field) Table {
+	table := Table{}
+	if reg.Parent != "" {
+		table.Header = append(table.Header, reg.Parent)
+	}
+	for _, f := range fields {
+		table.Header = append(table.Header, f.Path)
+	}
+
+	doc := decode(section)
+	for _, parent := range reg.parents(doc) {
+		for _, item := range objects(parent.list) {
+			var row []interface{}
+			if reg.Parent != "" {
+				row = append(row, parent.id)
+			}
+			for _, f := range fields {
+				row = append(row, cell(lookup(item, f.Path), f))
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	}
+
+	return table
+}
+
+// Mapping ties spreadsheet columns to fields.
+type Mapping struct {
+	Fields  map[int]validator.Field
+	Parent  int
+	Ignored []string
+}
+
+// MapColumns matches header cells to fields. overrides maps a header to a
+// field path and wins over matching by name. Otherwise a header matches a
+// field path or, when unambiguous, the last part of one, ignoring case and
+// treating spaces and hyphens as underscores.
+func (reg Register) MapColumns(header []string, fields []validator.Field, overrides map[string]string) (Mapping, error) {
+	m := Mapping{Fields: make(map[int]validator.Field), Parent: -1}
+
+	byPath := make(map[string]validator.Field)
+	byLeaf := make(map[string][]validator.Field)
+	for _, f := range fields {
+		byPath[normalize(f.Path)] = f
+		leaf := f.Path[strings.LastIndex(f.Path, ".")+1:]
+		byLeaf[normalize(leaf)] = append(byLeaf[normalize(leaf)], f)
+	}
+
+	for override, path := range overrides {
+		if _, ok := byPath[normalize(path)]; !ok && path != reg.Parent {
+			return m, fmt.Errorf("unknown field %q for column %q", path, override)
+		}
+	}
+
+	used := make(map[string]int)
+	for i, title := range header {
+		key := normalize(title)
+		if path, ok := overrides[strings.TrimSpace(title)]; ok {
+			key = normalize(path)
+		}
+		if key == "" {
+			continue
+		}
+
+		if reg.Parent != "" && key == reg.Parent {
+			m.Parent = i
+			continue
+		}
+
+		f, ok := byPath[key]
+		if !ok && len(byLeaf[key]) == 1 {
+			f, ok = byLeaf[key][0], true
+		}
+		if !ok {
+			m.Ignored = append(m.Ignored, title)
+			continue
+		}
+		if previous, ok := used[f.Path]; ok {
+			return m, fmt.Errorf("columns %q and %q both map to %s", header[previous], title, f.Path)
+		}
+		used[f.Path] = i
+		m.Fields[i] = f
+	}
+
+	if reg.Parent != "" && m.Parent < 0 {
+		return m, fmt.Errorf("a %s column is required", reg.Parent)
+	}
+	if reg.IDField != "" {
+		if _, ok := used[reg.IDField]; !ok {
+			return m, fmt.Errorf("a %s column is required", reg.IDField)
+		}
+	}
+
+	return m, nil
+}
+
+// columns returns the mapped column indexes in spreadsheet order.
+func (m Mapping) columns() []int {
+	columns := make([]int, 0, len(m.Fields))
+	for col := range m.Fields {
+		columns = append(columns, col)
+	}
+	sort.Ints(columns)
+	return columns
+}
+
+// Row is one spreadsheet row turned into an item. Number is the row number
+// as a spreadsheet shows it, counting the header as row 1.
+type Row struct {
+	Number int                    `json:"row"`
+	Parent string                 `json:"-"`
+	Item   map[string]interface{} `json:"-"`
+	Errors []RowError             `json:"errors,omitempty"`
+}
+
+type RowError struct {
+	Column  string `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// ParseRows turns records, header included, into items. A row whose ID
+// matches a stored item starts from that item, so fields without a column,
+// such as lists of objects, survive the round trip. An empty cell clears
+// its field, and a cell holding keep, such as a redaction mask, leaves it
+// as stored. Blank rows are skipped.
+func (reg Register) ParseRows(records [][]string, m Mapping, stored *string, keep string) []Row {
+	existing := make(map[string]map[string]interface{})
+	doc := decode(stored)
+	for _, parent := range reg.parents(doc) {
+		for _, item := range objects(parent.list) {
+			key := parent.id + "\x00"
+			if reg.IDField != "" {
+				id, _ := item[reg.IDField].(string)
+				if id == "" {
+					continue
+				}
+				key += id
+			}
+			if _, ok := existing[key]; !ok {
+				existing[key] = item
+			}
+		}
+	}
+
+	var rows []Row
+	for i, record := range records {
+		if i == 0 || blank(record) {
+			continue
+		}
+		row := Row{Number: i + 1, Item: map[string]interface{}{}}
+
+		if reg.Parent != "" {
+			row.Parent = strings.TrimSpace(value(record, m.Parent))
+			if row.Parent == "" {
+				row.Errors = append(row.Errors, RowError{Column: reg.Parent, Message: reg.Parent + " is required"})
+			}
+		}
+
+		columns := m.columns()
+		for _, col := range columns {
+			if f := m.Fields[col]; reg.IDField != "" && f.Path == reg.IDField {
+				if item, ok := existing[row.Parent+"\x00"+strings.TrimSpace(value(record, col))]; ok {
+					row.Item = clone(item)
+				}
+			}
+		}
+
+		for _, col := range columns {
+			f := m.Fields[col]
+			text := strings.TrimSpace(value(record, col))
+			if keep != "" && text == keep {
+				continue
+			}
+			if text == "" {
+				remove(row.Item, f.Path)
+				continue
+			}
+			v, err := parse(text, f)
+			if err != nil {
+				row.Errors = append(row.Errors, RowError{Column: f.Path, Message: err.Error()})
+				continue
+			}
+			set(row.Item, f.Path, v)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// RowDocument builds a section from stored with the register reduced to
+// the single item of row, so the section schema can check the row on its
+// own. It returns the JSON pointer of the item within the document, which
+// prefixes the errors that belong to the row.
+func (reg Register) RowDocument(stored *string, row Row) (string, string, error) {
+	doc := decode(stored)
+	pointer, err := reg.replace(doc, map[string][]interface{}{row.Parent: {row.Item}}, true)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", "", err
+	}
+	return string(data), pointer + "/0", nil
+}
+
+// Assemble replaces the whole register in stored with rows, in order, and
+// returns the new section JSON. For nested registers every parent's list is
+// replaced, and parents without rows lose theirs.
+func (reg Register) Assemble(stored *string, rows []Row) (string, error) {
+	doc := decode(stored)
+	lists := make(map[string][]interface{})
+	for _, row := range rows {
+		lists[row.Parent] = append(lists[row.Parent], row.Item)
+	}
+	if _, err := reg.replace(doc, lists, false); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// replace sets the register lists in doc. Lists are keyed by parent ID, or
+// by "" for top-level registers. With only set, parents without a list are
+// removed, leaving a document with just the rows being checked. It returns
+// the pointer of the first list it set.
+func (reg Register) replace(doc map[string]interface{}, lists map[string][]interface{}, only bool) (string, error) {
+	if reg.Parent == "" {
+		doc[reg.List] = nonNil(lists[""])
+		return "/" + reg.List, nil
+	}
+
+	parentList, field := reg.parentPath()
+	var parents []interface{}
+	found := make(map[string]bool)
+	for _, parent := range objects(doc[parentList]) {
+		id, _ := parent[reg.Parent].(string)
+		items, ok := lists[id]
+		if only && !ok {
+			continue
+		}
+		found[id] = true
+		parent = clone(parent)
+		if ok {
+			parent[field] = items
+		} else {
+			delete(parent, field)
+		}
+		parents = append(parents, parent)
+	}
+	for id := range lists {
+		if !found[id] {
+			return "", fmt.Errorf("%s %q not found", reg.Parent, id)
+		}
+	}
+	doc[parentList] = nonNil(parents)
+	return "/" + parentList + "/0/" + field, nil
+}
+
+type parentItems struct {
+	id   string
+	list interface{}
+}
+
+// parents returns the lists that make up the register, with the ID of the
+// item holding each one for nested registers.
+func (reg Register) parents(doc map[string]interface{}) []parentItems {
+	if reg.Parent == "" {
+		return []parentItems{{list: doc[reg.List]}}
+	}
+	parentList, field := reg.parentPath()
+	var result []parentItems
+	for _, parent := range objects(doc[parentList]) {
+		id, _ := parent[reg.Parent].(string)
+		result = append(result, parentItems{id: id, list: parent[field]})
+	}
+	return result
+}
+
+func (reg Register) parentPath() (string, string) {
+	parts := strings.Split(reg.List, "/")
+	return parts[0], parts[len(parts)-1]
+}
+
+// parse converts a cell to the JSON type of its field.
+func parse(text string, f validator.Field) (interface{}, error) {
+	switch f.Type {
+	case validator.FieldInteger:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a whole number", text)
+		}
+		return n, nil
+	case validator.FieldNumber:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return n, nil
+	case validator.FieldBoolean:
+		switch strings.ToLower(text) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not yes or no", text)
+	case validator.FieldList:
+		var items []interface{}
+		for _, part := range strings.Split(text, strings.TrimSpace(ListSeparator)) {
+			if part = strings.TrimSpace(part); part != "" {
+				items = append(items, part)
+			}
+		}
+		return items, nil
+	default:
+		return text, nil
+	}
+}
+
+// cell renders a field value for a spreadsheet, keeping numbers and
+// booleans typed.
+func cell(v interface{}, f validator.Field) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		var parts []string
+		for _, item := range value {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ListSeparator)
+	case float64:
+		if f.Type == validator.FieldInteger && value == float64(int64(value)) {
+			return int64(value)
+		}
+		return value
+	case string, bool:
+		return value
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
+
+func lookup(item map[string]interface{}, path string) interface{} {
+	var current interface{} = item
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+func set(item map[string]interface{}, path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := item[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			item[key] = child
+		}
+		item = child
+	}
+	item[keys[len(keys)-1]] = v
+}
+
+func remove(item map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := item[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		item = child
+	}
+	delete(item, keys[len(keys)-1])
+}
+
+func decode(data *string) map[string]interface{} {
+	doc := map[string]interface{}{}
+	if data != nil {
+		json.Unmarshal([]byte(*data), &doc)
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	return doc
+}
+
+func objects(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	var result []map[string]interface{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func clone(item map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(item)
+	var copied map[string]interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+func nonNil(list []interface{}) []interface{} {
+	if list == nil {
+		return []interface{}{}
+	}
+	return list
+}
+
+func normalize(title string) string {
+	title = strings.ToLower(strings.TrimSpace(title))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(title)
+}
+
+func value(record []string, col int) string {
+	if col < 0 || col >= len(record) {
+		return ""
+	}
+	return record[col]
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
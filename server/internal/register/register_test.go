@@ -0,0 +1,180 @@
+package register
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+var assetFields = []validator.Field{
+	{Path: "asset_id", Type: validator.FieldString, Required: true},
+	{Path: "name", Type: validator.FieldString},
+	{Path: "value", Type: validator.FieldString},
+	{Path: "tags", Type: validator.FieldList},
+	{Path: "protection.encrypted", Type: validator.FieldBoolean},
+	{Path: "protection.copies", Type: validator.FieldInteger},
+}
+
+func TestMapColumns(t *testing.T) {
+	assets, _ := Lookup("assets")
+	actions, _ := Lookup("mitigation-actions")
+
+	tests := []struct {
+		name      string
+		reg       Register
+		header    []string
+		overrides map[string]string
+		fields    map[int]string
+		ignored   []string
+		wantErr   bool
+	}{
+		{
+			name:    "paths, leaves and spelling",
+			reg:     assets,
+			header:  []string{"Asset ID", "name", "Encrypted", "Protection.Copies", "Notes"},
+			fields:  map[int]string{0: "asset_id", 1: "name", 2: "protection.encrypted", 3: "protection.copies"},
+			ignored: []string{"Notes"},
+		},
+		{
+			name:      "override",
+			reg:       assets,
+			header:    []string{"asset_id", "Who"},
+			overrides: map[string]string{"Who": "name"},
+			fields:    map[int]string{0: "asset_id", 1: "name"},
+		},
+		{name: "ID column missing", reg: assets, header: []string{"name"}, wantErr: true},
+		{name: "two columns for one field", reg: assets, header: []string{"asset_id", "name", "Name"}, wantErr: true},
+		{name: "unknown override", reg: assets, header: []string{"asset_id"}, overrides: map[string]string{"x": "owner"}, wantErr: true},
+		{name: "parent column missing", reg: actions, header: []string{"name"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.reg.MapColumns(tt.header, assetFields, tt.overrides)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("MapColumns(%v) = %+v, want error", tt.header, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fields := make(map[int]string)
+			for col, f := range m.Fields {
+				fields[col] = f.Path
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+			if !reflect.DeepEqual(m.Ignored, tt.ignored) {
+				t.Errorf("ignored = %v, want %v", m.Ignored, tt.ignored)
+			}
+		})
+	}
+}
+
+func TestParseRowsAndAssemble(t *testing.T) {
+	reg, _ := Lookup("assets")
+	stored := `{"assets": [
+		{"asset_id": "a1", "name": "Laptop", "value": "high", "locations": [{"site": "office"}]},
+		{"asset_id": "a2", "name": "Server"}
+	], "notes": "kept"}`
+
+	records := [][]string{
+		{"asset_id", "name", "value", "tags", "protection.encrypted", "protection.copies"},
+		{"a1", "Work laptop", "[REDACTED]", "field; travel", "yes", "2"},
+		{"", "", "", "", "", ""},
+		{"a3", "Phone", "", "", "maybe", "two"},
+		{"a4", "Drive", "low", "", "", ""},
+	}
+	m, err := reg.MapColumns(records[0], assetFields, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := reg.ParseRows(records, m, &stored, "[REDACTED]")
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3 with the blank one skipped", len(rows))
+	}
+
+	first := rows[0].Item
+	want := map[string]interface{}{
+		"asset_id":   "a1",
+		"name":       "Work laptop",
+		"value":      "high",
+		"locations":  []interface{}{map[string]interface{}{"site": "office"}},
+		"tags":       []interface{}{"field", "travel"},
+		"protection": map[string]interface{}{"encrypted": true, "copies": 2},
+	}
+	if rows[0].Number != 2 || !reflect.DeepEqual(first, want) {
+		t.Errorf("row %d = %v, want %v", rows[0].Number, first, want)
+	}
+
+	if rows[1].Number != 4 || len(rows[1].Errors) != 2 {
+		t.Errorf("row %d errors = %+v, want one per invalid cell", rows[1].Number, rows[1].Errors)
+	}
+
+	assembled, err := reg.Assemble(&stored, []Row{rows[0], rows[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Assets []map[string]interface{} `json:"assets"`
+		Notes  string                   `json:"notes"`
+	}
+	if err := json.Unmarshal([]byte(assembled), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Assets) != 2 || doc.Assets[0]["asset_id"] != "a1" || doc.Assets[1]["asset_id"] != "a4" {
+		t.Errorf("assets = %v, want a1 and a4 replacing the register", doc.Assets)
+	}
+	if doc.Notes != "kept" {
+		t.Errorf("notes = %q, want the rest of the section kept", doc.Notes)
+	}
+}
+
+func TestNestedRegister(t *testing.T) {
+	reg, _ := Lookup("mitigation-actions")
+	fields := []validator.Field{{Path: "action", Type: validator.FieldString}}
+	stored := `{"mitigations": [
+		{"mitigation_id": "m1", "actions": [{"action": "old"}]},
+		{"mitigation_id": "m2", "actions": [{"action": "dropped"}]}
+	]}`
+
+	table := reg.Export(&stored, fields)
+	if !reflect.DeepEqual(table.Header, []string{"mitigation_id", "action"}) || len(table.Rows) != 2 {
+		t.Fatalf("table = %+v", table)
+	}
+
+	records := [][]string{{"mitigation_id", "action"}, {"m1", "first"}, {"m1", "second"}}
+	m, err := reg.MapColumns(records[0], fields, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assembled, err := reg.Assemble(&stored, reg.ParseRows(records, m, &stored, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Mitigations []map[string]interface{} `json:"mitigations"`
+	}
+	if err := json.Unmarshal([]byte(assembled), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if actions := doc.Mitigations[0]["actions"].([]interface{}); len(actions) != 2 {
+		t.Errorf("m1 actions = %v, want two", actions)
+	}
+	if _, ok := doc.Mitigations[1]["actions"]; ok {
+		t.Errorf("m2 actions = %v, want them removed", doc.Mitigations[1]["actions"])
+	}
+
+	unknown := [][]string{{"mitigation_id", "action"}, {"m9", "orphan"}}
+	if _, err := reg.Assemble(&stored, reg.ParseRows(unknown, m, &stored, "")); err == nil {
+		t.Error("Assemble accepted a row for an unknown mitigation")
+	}
+}
@@ -0,0 +1,165 @@
+package register
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Spreadsheet formats.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var ContentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// formulaPrefixes start a formula when a spreadsheet program opens a CSV
+// file, even in a cell meant as text.
+const formulaPrefixes = "=+-@\t\r"
+
+// WriteCSV writes the table as CSV. Text that a spreadsheet program would
+// run as a formula is prefixed with an apostrophe, which ReadCSV strips.
+func (t Table) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := make([]string, len(t.Header))
+	for i, title := range t.Header {
+		header[i] = escapeFormula(title)
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			if text, ok := v.(string); ok {
+				record[i] = escapeFormula(text)
+			} else {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteXLSX writes the table to a workbook with a single sheet named after
+// the register, keeping numbers and booleans typed.
+func (t Table) WriteXLSX(w io.Writer, sheet string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(t.Header))
+	for i, title := range t.Header {
+		header[i] = title
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	if err := f.SetRowStyle(sheet, 1, 1, bold); err != nil {
+		return err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+
+	for i, row := range t.Rows {
+		start, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, start, &row); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// ReadCSV reads CSV records, undoing the formula escaping of WriteCSV.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+	records, err := in.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) > 0 && len(records[0]) > 0 {
+		// Spreadsheet programs often prepend a byte order mark.
+		records[0][0] = trimBOM(records[0][0])
+	}
+	for _, record := range records {
+		for i, text := range record {
+			record[i] = unescapeFormula(text)
+		}
+	}
+	return records, nil
+}
+
+// ReadXLSX reads the sheet named after the register, or the first sheet
+// when there is none.
+func ReadXLSX(r io.Reader, sheet string) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %w", err)
+	}
+	defer f.Close()
+
+	name := f.GetSheetName(0)
+	for _, candidate := range f.GetSheetList() {
+		if candidate == sheet {
+			name = sheet
+		}
+	}
+	return f.GetRows(name)
+}
+
+// escapeFormula prefixes text that would start a formula with an
+// apostrophe. Text that already looks escaped gets another one, so that
+// unescapeFormula restores it exactly.
+func escapeFormula(text string) string {
+	if isFormula(text) {
+		return "'" + text
+	}
+	return text
+}
+
+func unescapeFormula(text string) string {
+	if strings.HasPrefix(text, "'") && isFormula(text[1:]) {
+		return text[1:]
+	}
+	return text
+}
+
+func isFormula(text string) bool {
+	for strings.HasPrefix(text, "'") {
+		text = text[1:]
+	}
+	return text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0]))
+}
+
+func trimBOM(s string) string {
+	if len(s) >= 3 && s[:3] == "\xef\xbb\xbf" {
+		return s[3:]
+	}
+	return s
+}
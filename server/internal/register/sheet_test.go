@@ -0,0 +1,59 @@
+package register
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVFormulaEscaping(t *testing.T) {
+	tests := []struct {
+		text    string
+		written string
+	}{
+		{text: "=HYPERLINK(\"http://example.com\")", written: "'=HYPERLINK"},
+		{text: "+1 555 0100", written: "'+1 555 0100"},
+		{text: "-2", written: "'-2"},
+		{text: "@SUM(A1)", written: "'@SUM(A1)"},
+		{text: "\tindented", written: "'\tindented"},
+		{text: "'=already escaped", written: "''=already escaped"},
+		{text: "'quoted", written: "'quoted"},
+		{text: "plain text", written: "plain text"},
+		{text: "", written: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			table := Table{Header: []string{"name", "score"}, Rows: [][]interface{}{{tt.text, -1.5}}}
+
+			var buf bytes.Buffer
+			if err := table.WriteCSV(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.written) {
+				t.Errorf("CSV %q does not contain %q", buf.String(), tt.written)
+			}
+			if !strings.Contains(buf.String(), ",-1.5") {
+				t.Errorf("CSV %q escaped a number", buf.String())
+			}
+
+			records, err := ReadCSV(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := records[1][0]; got != tt.text {
+				t.Errorf("read back %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestReadCSVByteOrderMark(t *testing.T) {
+	records, err := ReadCSV(strings.NewReader("\xef\xbb\xbfasset_id,name\na1,Laptop\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0][0] != "asset_id" {
+		t.Errorf("header = %q, want asset_id", records[0][0])
+	}
+}
@@ -0,0 +1,323 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pdfLineHeight = 4.5
+	pdfFontSize   = 9
+)
+
+// PDF lays out the same content as the default HTML template. Custom HTML
+// templates do not apply to it; the brand does.
+func (rr *Renderer) PDF(w io.Writer, r *Report) error {
+	r.Brand = rr.brand
+	p := newPDFWriter(r.Brand)
+
+	p.cover(r)
+
+	p.heading("Completeness")
+	p.paragraph(fmt.Sprintf("Overall completeness of the core sections: %.0f%%.", r.Completeness.Overall))
+	var completeness [][]string
+	for _, s := range r.Completeness.Sections {
+		completeness = append(completeness, []string{label(s.Section), fmt.Sprintf("%.0f%%", s.Percentage), fmt.Sprint(len(s.Missing))})
+	}
+	for _, s := range r.Completeness.Modules {
+		if s.Filled > 0 {
+			completeness = append(completeness, []string{label(s.Section) + " (module)", fmt.Sprintf("%.0f%%", s.Percentage), fmt.Sprint(len(s.Missing))})
+		}
+	}
+	p.table([]string{"Section", "Complete", "Missing fields"}, []float64{0.6, 0.2, 0.2}, completeness)
+
+	p.heading("Mission")
+	if r.Mission.Statement != "" {
+		p.paragraph(r.Mission.Statement)
+	} else {
+		p.muted("No mission statement recorded.")
+	}
+	if len(r.Mission.CoreActivities) > 0 {
+		p.subheading("Core activities")
+		var rows [][]string
+		for _, a := range r.Mission.CoreActivities {
+			rows = append(rows, []string{a.Activity, a.Description, label(a.Criticality)})
+		}
+		p.table([]string{"Activity", "Description", "Criticality"}, []float64{0.3, 0.55, 0.15}, rows)
+	}
+	if len(r.Mission.ImpactAreas) > 0 {
+		p.subheading("Impact areas")
+		var rows [][]string
+		for _, a := range r.Mission.ImpactAreas {
+			rows = append(rows, []string{label(a.Area), fmt.Sprint(a.Priority), a.Description})
+		}
+		p.table([]string{"Area", "Priority", "Description"}, []float64{0.25, 0.1, 0.65}, rows)
+	}
+
+	p.heading("Asset inventory")
+	if len(r.Assets) == 0 {
+		p.muted("No assets recorded.")
+	}
+	var assets [][]string
+	for _, a := range r.Assets {
+		assets = append(assets, []string{withDetail(a.Name, a.Description), label(a.Category), label(a.Value), a.Owner})
+	}
+	p.table([]string{"Asset", "Category", "Value", "Owner"}, []float64{0.5, 0.18, 0.1, 0.22}, assets)
+
+	p.heading("Adversary profiles")
+	if len(r.Adversaries) == 0 {
+		p.muted("No adversaries recorded.")
+	}
+	for _, a := range r.Adversaries {
+		p.subheading(a.Name)
+		summary := label(a.Category)
+		if a.Relevance != "" {
+			summary += ", relevance: " + label(a.Relevance)
+		}
+		p.muted(summary)
+		if a.Details.Description != "" {
+			p.paragraph(a.Details.Description)
+		}
+		if a.RelevanceRationale != "" {
+			p.paragraph("Why relevant: " + a.RelevanceRationale)
+		}
+		if len(a.Details.Motivation) > 0 {
+			p.paragraph("Motivation: " + strings.Join(a.Details.Motivation, ", "))
+		}
+	}
+
+	p.heading("Threats")
+	if len(r.Threats) == 0 {
+		p.muted("No threats recorded.")
+	}
+	var threats [][]string
+	for _, t := range r.Threats {
+		threats = append(threats, []string{withDetail(t.Name, t.Description), label(t.Category), label(t.Likelihood),
+			strings.Join(t.AssetNames, ", "), strings.Join(t.AdversaryNames, ", ")})
+	}
+	p.table([]string{"Threat", "Category", "Likelihood", "Targets", "Adversaries"}, []float64{0.36, 0.16, 0.1, 0.19, 0.19}, threats)
+
+	p.heading("Risk register")
+	if len(r.Risks) == 0 {
+		p.muted("No risks recorded.")
+	} else {
+		var counts []string
+		for _, c := range r.RiskCounts {
+			counts = append(counts, fmt.Sprintf("%s: %d", label(c.Level), c.Count))
+		}
+		p.paragraph(strings.Join(counts, "   "))
+	}
+	var risks [][]string
+	for _, risk := range r.Risks {
+		risks = append(risks, []string{risk.Scenario, risk.AssetName, risk.ThreatName,
+			fmt.Sprintf("%d x %d x %d", risk.AssetValueScore, risk.LikelihoodScore, risk.VulnerabilityScore),
+			fmt.Sprint(risk.RiskScore), label(risk.RiskLevel), label(risk.Status)})
+	}
+	p.table([]string{"Risk", "Asset", "Threat", "A x L x V", "Score", "Level", "Status"}, []float64{0.32, 0.15, 0.15, 0.1, 0.07, 0.1, 0.11}, risks)
+
+	p.heading("Mitigation roadmap")
+	for _, bucket := range r.Roadmap {
+		p.subheading(bucket.Name)
+		if len(bucket.Mitigations) == 0 {
+			p.muted("None.")
+			continue
+		}
+		var rows [][]string
+		for _, m := range bucket.Mitigations {
+			rows = append(rows, []string{withDetail(m.Title, m.Description), label(m.Priority), m.Owner, m.Timeline.TargetCompletion, label(m.Status)})
+		}
+		p.table([]string{"Mitigation", "Priority", "Owner", "Target", "Status"}, []float64{0.46, 0.1, 0.18, 0.13, 0.13}, rows)
+	}
+
+	if err := p.doc.Error(); err != nil {
+		return fmt.Errorf("failed to render PDF: %w", err)
+	}
+	return p.doc.Output(w)
+}
+
+// pdfWriter keeps the document together with the helpers that lay out text
+// in the brand's colours, translating UTF-8 for the built-in fonts.
+type pdfWriter struct {
+	doc       *fpdf.Fpdf
+	tr        func(string) string
+	brand     Brand
+	r, g, b   int
+	textWidth float64
+}
+
+func newPDFWriter(brand Brand) *pdfWriter {
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetMargins(15, 15, 15)
+	doc.SetAutoPageBreak(true, 18)
+	doc.SetTitle(brand.Title, true)
+	doc.SetCreator("ARMOR", true)
+	doc.AliasNbPages("")
+
+	p := &pdfWriter{doc: doc, tr: doc.UnicodeTranslatorFromDescriptor(""), brand: brand}
+	p.r, p.g, p.b, _ = parseHexColor(brand.AccentColor)
+	width, _ := doc.GetPageSize()
+	left, _, right, _ := doc.GetMargins()
+	p.textWidth = width - left - right
+
+	doc.SetFooterFunc(func() {
+		doc.SetY(-12)
+		doc.SetFont("Helvetica", "", 8)
+		doc.SetTextColor(110, 110, 110)
+		doc.CellFormat(p.textWidth*0.8, 5, p.tr(brand.Footer), "", 0, "L", false, 0, "")
+		doc.CellFormat(p.textWidth*0.2, 5, fmt.Sprintf("%d / {nb}", doc.PageNo()), "", 0, "R", false, 0, "")
+	})
+	doc.AddPage()
+	return p
+}
+
+func (p *pdfWriter) cover(r *Report) {
+	doc := p.doc
+	if r.Brand.logo != nil {
+		imageType := "PNG"
+		if r.Brand.logoType == "image/jpeg" {
+			imageType = "JPG"
+		}
+		options := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+		doc.RegisterImageOptionsReader("logo", options, bytes.NewReader(r.Brand.logo))
+		left, top, _, _ := doc.GetMargins()
+		doc.ImageOptions("logo", left+p.textWidth-40, top, 40, 0, false, options, 0, "")
+	}
+
+	doc.SetFont("Helvetica", "B", 20)
+	doc.SetTextColor(p.r, p.g, p.b)
+	doc.MultiCell(p.textWidth-45, 9, p.tr(r.Brand.Title), "", "L", false)
+	doc.SetFont("Helvetica", "B", 12)
+	doc.SetTextColor(34, 34, 34)
+	title := r.Organization
+	if r.Brand.Subtitle != "" {
+		title += " - " + r.Brand.Subtitle
+	}
+	doc.MultiCell(p.textWidth-45, 6, p.tr(title), "", "L", false)
+	if r.Description != "" {
+		p.muted(r.Description)
+	}
+	p.muted(fmt.Sprintf("Generated %s, profile version %d, schema %s",
+		r.GeneratedAt.Format("2 January 2006"), r.Version, r.SchemaVersion))
+
+	doc.SetDrawColor(p.r, p.g, p.b)
+	doc.SetLineWidth(0.8)
+	y := doc.GetY() + 2
+	left, _, _, _ := doc.GetMargins()
+	doc.Line(left, y, left+p.textWidth, y)
+	doc.SetLineWidth(0.2)
+	doc.SetY(y + 2)
+}
+
+func (p *pdfWriter) heading(text string) {
+	p.keepWithNext(20)
+	p.doc.Ln(4)
+	p.doc.SetFont("Helvetica", "B", 14)
+	p.doc.SetTextColor(p.r, p.g, p.b)
+	p.doc.CellFormat(p.textWidth, 8, p.tr(text), "B", 1, "L", false, 0, "")
+	p.doc.Ln(2)
+}
+
+func (p *pdfWriter) subheading(text string) {
+	p.keepWithNext(15)
+	p.doc.Ln(1)
+	p.doc.SetFont("Helvetica", "B", 11)
+	p.doc.SetTextColor(34, 34, 34)
+	p.doc.MultiCell(p.textWidth, 6, p.tr(text), "", "L", false)
+}
+
+func (p *pdfWriter) paragraph(text string) {
+	p.doc.SetFont("Helvetica", "", pdfFontSize)
+	p.doc.SetTextColor(34, 34, 34)
+	p.doc.MultiCell(p.textWidth, pdfLineHeight, p.tr(text), "", "L", false)
+	p.doc.Ln(1)
+}
+
+func (p *pdfWriter) muted(text string) {
+	p.doc.SetFont("Helvetica", "", pdfFontSize)
+	p.doc.SetTextColor(110, 110, 110)
+	p.doc.MultiCell(p.textWidth, pdfLineHeight, p.tr(text), "", "L", false)
+}
+
+// table draws rows with wrapped cells, repeating the header on every page.
+// widths are fractions of the text width.
+func (p *pdfWriter) table(header []string, widths []float64, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+
+	cols := make([]float64, len(widths))
+	for i, fraction := range widths {
+		cols[i] = fraction * p.textWidth
+	}
+
+	drawHeader := func() {
+		p.doc.SetFont("Helvetica", "B", pdfFontSize)
+		p.doc.SetFillColor(238, 238, 238)
+		p.doc.SetTextColor(34, 34, 34)
+		for i, text := range header {
+			p.doc.CellFormat(cols[i], 6, p.tr(text), "B", 0, "L", true, 0, "")
+		}
+		p.doc.Ln(-1)
+	}
+
+	p.keepWithNext(20)
+	drawHeader()
+	p.doc.SetFont("Helvetica", "", pdfFontSize)
+	p.doc.SetDrawColor(221, 221, 221)
+
+	for _, row := range rows {
+		lines := make([][][]byte, len(row))
+		height := 0.0
+		for i, text := range row {
+			lines[i] = p.doc.SplitLines([]byte(p.tr(text)), cols[i]-2)
+			if h := float64(len(lines[i]))*pdfLineHeight + 2; h > height {
+				height = h
+			}
+		}
+
+		if p.remaining() < height {
+			p.doc.AddPage()
+			drawHeader()
+			p.doc.SetFont("Helvetica", "", pdfFontSize)
+		}
+
+		x, y := p.doc.GetXY()
+		for i := range row {
+			p.doc.SetXY(x, y+1)
+			for _, line := range lines[i] {
+				p.doc.SetX(x)
+				p.doc.CellFormat(cols[i], pdfLineHeight, string(line), "", 2, "L", false, 0, "")
+			}
+			x += cols[i]
+		}
+		left, _, _, _ := p.doc.GetMargins()
+		p.doc.Line(left, y+height, left+p.textWidth, y+height)
+		p.doc.SetXY(left, y+height)
+	}
+	p.doc.Ln(2)
+}
+
+// keepWithNext starts a new page when less than height is left, so headings
+// do not end up alone at the bottom of a page.
+func (p *pdfWriter) keepWithNext(height float64) {
+	if p.remaining() < height {
+		p.doc.AddPage()
+	}
+}
+
+func (p *pdfWriter) remaining() float64 {
+	_, pageHeight := p.doc.GetPageSize()
+	_, _, _, bottom := p.doc.GetMargins()
+	return pageHeight - bottom - p.doc.GetY()
+}
+
+func withDetail(name, detail string) string {
+	if detail == "" {
+		return name
+	}
+	return name + "\n" + detail
+}
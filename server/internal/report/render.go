@@ -0,0 +1,141 @@
+package report
+
+import (
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed templates/report.html
+var templates embed.FS
+
+// Brand is the organization-specific look of a report: the title, the
+// wording around it, an accent colour and an optional logo.
+type Brand struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Footer   string `json:"footer"`
+
+	// AccentColor is a hex colour such as "#1f4e79".
+	AccentColor string `json:"accent_color"`
+
+	// Logo is a PNG or JPEG file, read once at startup and embedded in the
+	// report so rendering never goes to the network.
+	Logo string `json:"logo"`
+
+	logo     []byte
+	logoType string
+}
+
+var DefaultBrand = Brand{
+	Title:       "Threat Model Report",
+	Footer:      "Confidential. Prepared with ARMOR.",
+	AccentColor: "#1f4e79",
+}
+
+// LoadBrand reads a brand from a JSON file. Fields left out keep their
+// default, and a relative logo path is resolved against the file.
+func LoadBrand(path string) (Brand, error) {
+	brand := DefaultBrand
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return brand, err
+	}
+	if err := json.Unmarshal(contents, &brand); err != nil {
+		return brand, fmt.Errorf("invalid brand file: %w", err)
+	}
+
+	if _, _, _, ok := parseHexColor(brand.AccentColor); !ok {
+		return brand, fmt.Errorf("invalid accent_color %q", brand.AccentColor)
+	}
+
+	if brand.Logo != "" {
+		logo := brand.Logo
+		if !filepath.IsAbs(logo) {
+			logo = filepath.Join(filepath.Dir(path), logo)
+		}
+		brand.logo, err = os.ReadFile(logo)
+		if err != nil {
+			return brand, fmt.Errorf("failed to read logo: %w", err)
+		}
+		brand.logoType = http.DetectContentType(brand.logo)
+		if brand.logoType != "image/png" && brand.logoType != "image/jpeg" {
+			return brand, fmt.Errorf("logo must be PNG or JPEG, got %s", brand.logoType)
+		}
+	}
+
+	return brand, nil
+}
+
+// LogoURL is the logo as a data URL, or empty without a logo.
+func (b Brand) LogoURL() template.URL {
+	if b.logo == nil {
+		return ""
+	}
+	return template.URL("data:" + b.logoType + ";base64," + base64.StdEncoding.EncodeToString(b.logo))
+}
+
+// Renderer turns reports into HTML and PDF documents.
+type Renderer struct {
+	html  *template.Template
+	brand Brand
+}
+
+// NewRenderer uses the HTML template at templatePath, or the built-in one
+// when it is empty. The template is executed with a *Report and can use the
+// functions in templateFuncs.
+func NewRenderer(templatePath string, brand Brand) (*Renderer, error) {
+	tmpl := template.New("report.html").Funcs(templateFuncs)
+
+	var err error
+	if templatePath == "" {
+		tmpl, err = tmpl.ParseFS(templates, "templates/report.html")
+	} else {
+		tmpl, err = tmpl.New(filepath.Base(templatePath)).ParseFiles(templatePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+
+	return &Renderer{html: tmpl, brand: brand}, nil
+}
+
+func (rr *Renderer) HTML(w io.Writer, r *Report) error {
+	r.Brand = rr.brand
+	return rr.html.Execute(w, r)
+}
+
+var templateFuncs = template.FuncMap{
+	"label":   label,
+	"percent": func(value float64) string { return fmt.Sprintf("%.0f%%", value) },
+	"join":    strings.Join,
+	"date":    func(value interface{ Format(string) string }) string { return value.Format("2 January 2006") },
+	"css":     func(value string) template.CSS { return template.CSS(value) },
+}
+
+// label turns an enum value such as "nation_state" into "Nation state".
+func label(value string) string {
+	if value == "" {
+		return ""
+	}
+	value = strings.ReplaceAll(value, "_", " ")
+	return strings.ToUpper(value[:1]) + value[1:]
+}
+
+func parseHexColor(value string) (r, g, b int, ok bool) {
+	if len(value) != 7 || value[0] != '#' {
+		return 0, 0, 0, false
+	}
+	if _, err := fmt.Sscanf(value[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		return 0, 0, 0, false
+	}
+	return r, g, b, true
+}
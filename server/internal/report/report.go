@@ -0,0 +1,292 @@
+// Package report renders a profile as a printable threat model report, in
+// HTML from a template or as a PDF, without fetching anything over the
+// network.
+package report
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/HyphaGroup/armor/server/internal/validator"
+)
+
+// Report is everything a report template can show. Sections that have not
+// been filled in are empty rather than nil.
+type Report struct {
+	Organization  string
+	Description   string
+	Version       int
+	SchemaVersion string
+	GeneratedAt   time.Time
+	Brand         Brand
+
+	Mission      Mission
+	Assets       []Asset
+	Adversaries  []Adversary
+	Threats      []Threat
+	Risks        []Risk
+	RiskCounts   []LevelCount
+	Roadmap      []RoadmapBucket
+	Completeness validator.ProfileCompleteness
+}
+
+type Mission struct {
+	Statement      string         `json:"mission_statement"`
+	CoreActivities []CoreActivity `json:"core_activities"`
+	ImpactAreas    []ImpactArea   `json:"impact_areas"`
+}
+
+type CoreActivity struct {
+	Activity    string `json:"activity"`
+	Description string `json:"description"`
+	Criticality string `json:"criticality"`
+}
+
+type ImpactArea struct {
+	Area        string `json:"area"`
+	Priority    int    `json:"priority"`
+	Description string `json:"description"`
+}
+
+type Asset struct {
+	ID          string `json:"asset_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Value       string `json:"value"`
+	Owner       string `json:"owner"`
+}
+
+type Adversary struct {
+	ID                 string `json:"adversary_id"`
+	Name               string `json:"name"`
+	Category           string `json:"category"`
+	Relevance          string `json:"relevance"`
+	RelevanceRationale string `json:"relevance_rationale"`
+	Details            struct {
+		Description string   `json:"description"`
+		Motivation  []string `json:"motivation"`
+		Resources   string   `json:"resources"`
+		Persistence string   `json:"persistence"`
+	} `json:"adversary_details"`
+	Capability struct {
+		Technical         string   `json:"technical_capability"`
+		SocialEngineering string   `json:"social_engineering_capability"`
+		InformationOps    string   `json:"information_operations_capability"`
+		Physical          string   `json:"physical_capability"`
+		Legal             string   `json:"legal_capability"`
+		TypicalTechniques []string `json:"typical_techniques"`
+	} `json:"capability"`
+}
+
+type Threat struct {
+	ID                  string   `json:"threat_id"`
+	Name                string   `json:"name"`
+	Description         string   `json:"description"`
+	Category            string   `json:"category"`
+	Likelihood          string   `json:"likelihood"`
+	TargetedAssets      []string `json:"targeted_assets"`
+	RelevantAdversaries []string `json:"relevant_adversaries"`
+
+	// Names of the linked items, resolved when the report is built.
+	AssetNames     []string `json:"-"`
+	AdversaryNames []string `json:"-"`
+}
+
+type Risk struct {
+	ID                 string `json:"risk_id"`
+	Scenario           string `json:"scenario"`
+	AssetID            string `json:"asset_id"`
+	ThreatID           string `json:"threat_id"`
+	AssetValueScore    int    `json:"asset_value_score"`
+	LikelihoodScore    int    `json:"likelihood_score"`
+	VulnerabilityScore int    `json:"vulnerability_score"`
+	RiskScore          int    `json:"risk_score"`
+	RiskLevel          string `json:"risk_level"`
+	Status             string `json:"status"`
+	MitigationID       string `json:"mitigation_id"`
+
+	AssetName  string `json:"-"`
+	ThreatName string `json:"-"`
+}
+
+type Mitigation struct {
+	ID          string   `json:"mitigation_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	RiskIDs     []string `json:"risk_ids"`
+	Priority    string   `json:"priority"`
+	Effort      string   `json:"effort"`
+	Status      string   `json:"status"`
+	Owner       string   `json:"owner"`
+	Timeline    struct {
+		TargetCompletion string `json:"target_completion"`
+	} `json:"timeline"`
+}
+
+// RoadmapBucket is one group of the mitigation roadmap, following the
+// action_plan_summary lists. A mitigation listed in several buckets appears
+// in each.
+type RoadmapBucket struct {
+	Key         string
+	Name        string
+	Mitigations []Mitigation
+}
+
+type LevelCount struct {
+	Level string
+	Count int
+}
+
+// roadmapBuckets are the action_plan_summary lists in report order.
+var roadmapBuckets = []struct{ key, name string }{
+	{"immediate_actions", "Immediate actions"},
+	{"short_term_actions", "Short-term actions"},
+	{"long_term_actions", "Long-term actions"},
+	{"quick_wins", "Quick wins"},
+}
+
+var riskLevels = []string{"critical", "high", "moderate", "low"}
+
+// Build assembles a report from the stored section JSON. Values that do not
+// match the expected shape are left out rather than failing the report.
+func Build(name, description string, version int, sections map[string]*string, completeness validator.ProfileCompleteness, now time.Time) *Report {
+	r := &Report{
+		Organization: name,
+		Description:  description,
+		Version:      version,
+		GeneratedAt:  now,
+		Completeness: completeness,
+	}
+
+	decode(sections["mission"], &r.Mission)
+
+	var assets struct {
+		Assets []Asset `json:"assets"`
+	}
+	decode(sections["assets"], &assets)
+	r.Assets = assets.Assets
+
+	var adversaries struct {
+		Adversaries []Adversary `json:"adversaries"`
+	}
+	decode(sections["adversaries"], &adversaries)
+	r.Adversaries = adversaries.Adversaries
+
+	var threats struct {
+		Threats []Threat `json:"threats"`
+	}
+	decode(sections["threats"], &threats)
+	r.Threats = threats.Threats
+
+	var risks struct {
+		Risks []Risk `json:"risks"`
+	}
+	decode(sections["risks"], &risks)
+	r.Risks = risks.Risks
+
+	var mitigations struct {
+		Mitigations       []Mitigation        `json:"mitigations"`
+		ActionPlanSummary map[string][]string `json:"action_plan_summary"`
+	}
+	decode(sections["mitigations"], &mitigations)
+
+	assetNames := make(map[string]string)
+	for _, a := range r.Assets {
+		assetNames[a.ID] = a.Name
+	}
+	adversaryNames := make(map[string]string)
+	for _, a := range r.Adversaries {
+		adversaryNames[a.ID] = a.Name
+	}
+	threatNames := make(map[string]string)
+	for _, t := range r.Threats {
+		threatNames[t.ID] = t.Name
+	}
+
+	for i := range r.Threats {
+		t := &r.Threats[i]
+		t.AssetNames = names(t.TargetedAssets, assetNames)
+		t.AdversaryNames = names(t.RelevantAdversaries, adversaryNames)
+	}
+
+	for i := range r.Risks {
+		risk := &r.Risks[i]
+		risk.AssetName = nameOr(assetNames, risk.AssetID)
+		risk.ThreatName = nameOr(threatNames, risk.ThreatID)
+	}
+	sort.SliceStable(r.Risks, func(i, j int) bool {
+		return r.Risks[i].RiskScore > r.Risks[j].RiskScore
+	})
+
+	counts := make(map[string]int)
+	for _, risk := range r.Risks {
+		counts[risk.RiskLevel]++
+	}
+	for _, level := range riskLevels {
+		r.RiskCounts = append(r.RiskCounts, LevelCount{Level: level, Count: counts[level]})
+	}
+
+	r.Roadmap = roadmap(mitigations.Mitigations, mitigations.ActionPlanSummary)
+
+	return r
+}
+
+// roadmap groups mitigations by the action plan lists. Mitigations in none
+// of them are collected under "Unscheduled" so nothing drops off the report.
+func roadmap(mitigations []Mitigation, plan map[string][]string) []RoadmapBucket {
+	byID := make(map[string]Mitigation)
+	for _, m := range mitigations {
+		byID[m.ID] = m
+	}
+
+	scheduled := make(map[string]bool)
+	var buckets []RoadmapBucket
+	for _, b := range roadmapBuckets {
+		bucket := RoadmapBucket{Key: b.key, Name: b.name}
+		for _, id := range plan[b.key] {
+			if m, ok := byID[id]; ok {
+				bucket.Mitigations = append(bucket.Mitigations, m)
+				scheduled[id] = true
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	unscheduled := RoadmapBucket{Key: "unscheduled", Name: "Unscheduled"}
+	for _, m := range mitigations {
+		if !scheduled[m.ID] {
+			unscheduled.Mitigations = append(unscheduled.Mitigations, m)
+		}
+	}
+	if len(unscheduled.Mitigations) > 0 {
+		buckets = append(buckets, unscheduled)
+	}
+
+	return buckets
+}
+
+func decode(data *string, v interface{}) {
+	if data == nil {
+		return
+	}
+	json.Unmarshal([]byte(*data), v)
+}
+
+func names(ids []string, lookup map[string]string) []string {
+	var result []string
+	for _, id := range ids {
+		result = append(result, nameOr(lookup, id))
+	}
+	return result
+}
+
+// nameOr returns the name of the item with id, or the ID itself for broken
+// links so the report still shows what the profile says.
+func nameOr(lookup map[string]string, id string) string {
+	if name := lookup[id]; name != "" {
+		return name
+	}
+	return id
+}
@@ -0,0 +1,262 @@
+// Package review works out how an incident should change the threat model.
+// It suggests edits to the threats, risks and mitigations an incident
+// touches, and applies the ones a facilitator accepts.
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Suggestion kinds.
+const (
+	KindRaiseLikelihood  = "raise_likelihood"
+	KindReopenRisk       = "reopen_risk"
+	KindReopenMitigation = "reopen_mitigation"
+)
+
+// Suggestion statuses.
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusDismissed = "dismissed"
+)
+
+// Suggestion is a proposed edit to one item of a section. Changes record
+// the value each field had when the suggestion was made, so an accepted
+// suggestion is not applied on top of a later edit.
+type Suggestion struct {
+	ID        string   `json:"id"`
+	Kind      string   `json:"kind"`
+	Section   string   `json:"section"`
+	ItemID    string   `json:"item_id"`
+	ItemName  string   `json:"item_name,omitempty"`
+	Changes   []Change `json:"changes"`
+	Rationale string   `json:"rationale"`
+	Status    string   `json:"status"`
+}
+
+// Change sets one top-level field of an item. From is nil when the field
+// was not set.
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Links are the assets and threats an incident involves.
+type Links struct {
+	AssetIDs  []string
+	ThreatIDs []string
+}
+
+var nextLikelihood = map[string]string{"low": "medium", "medium": "high"}
+
+var likelihoodScores = map[string]int{"high": 3, "medium": 2, "low": 1}
+
+// lists names the item array and ID field of the sections suggestions
+// touch.
+var lists = map[string]string{
+	"threats":     "threat_id",
+	"risks":       "risk_id",
+	"mitigations": "mitigation_id",
+}
+
+// Suggest works out the edits an incident calls for:
+//
+//   - linked threats get their likelihood raised one step,
+//   - mitigated risks on a linked threat or asset are reopened, and
+//   - completed mitigations for those risks go back in progress.
+//
+// Items that are already at the suggested values are left out.
+func Suggest(sections map[string]*string, links Links) []Suggestion {
+	docs := decode(sections)
+	threatIDs := set(links.ThreatIDs)
+	assetIDs := set(links.AssetIDs)
+
+	var suggestions []Suggestion
+	add := func(s Suggestion) {
+		s.ID = fmt.Sprintf("suggestion-%d", len(suggestions)+1)
+		s.Status = StatusPending
+		suggestions = append(suggestions, s)
+	}
+
+	for _, threat := range docs.items("threats") {
+		id := threat.str("threat_id")
+		if !threatIDs[id] {
+			continue
+		}
+		likelihood := threat.str("likelihood")
+		next, ok := nextLikelihood[likelihood]
+		if !ok {
+			continue
+		}
+		add(Suggestion{
+			Kind:     KindRaiseLikelihood,
+			Section:  "threats",
+			ItemID:   id,
+			ItemName: threat.str("name"),
+			Changes: []Change{
+				{Field: "likelihood", From: likelihood, To: next},
+				{Field: "likelihood_score", From: threat["likelihood_score"], To: float64(likelihoodScores[next])},
+			},
+			Rationale: fmt.Sprintf("An incident involved this threat, so a likelihood of %s may be too low.", likelihood),
+		})
+	}
+
+	affectedRisks := make(map[string]bool)
+	affectedMitigations := make(map[string]bool)
+	for _, risk := range docs.items("risks") {
+		id := risk.str("risk_id")
+		if !threatIDs[risk.str("threat_id")] && !assetIDs[risk.str("asset_id")] {
+			continue
+		}
+		affectedRisks[id] = true
+		affectedMitigations[risk.str("mitigation_id")] = true
+		if risk.str("status") != "mitigated" {
+			continue
+		}
+		add(Suggestion{
+			Kind:      KindReopenRisk,
+			Section:   "risks",
+			ItemID:    id,
+			ItemName:  risk.str("scenario"),
+			Changes:   []Change{{Field: "status", From: "mitigated", To: "identified"}},
+			Rationale: "This risk is marked mitigated, but an incident involved its threat or asset.",
+		})
+	}
+
+	for _, mitigation := range docs.items("mitigations") {
+		id := mitigation.str("mitigation_id")
+		if mitigation.str("status") != "completed" {
+			continue
+		}
+		linked := affectedMitigations[id]
+		for _, riskID := range mitigation.strings("risk_ids") {
+			linked = linked || affectedRisks[riskID]
+		}
+		if !linked {
+			continue
+		}
+		add(Suggestion{
+			Kind:      KindReopenMitigation,
+			Section:   "mitigations",
+			ItemID:    id,
+			ItemName:  mitigation.str("title"),
+			Changes:   []Change{{Field: "status", From: "completed", To: "in_progress"}},
+			Rationale: "An incident touched a risk this completed mitigation addresses, so it may not be working.",
+		})
+	}
+
+	return suggestions
+}
+
+// StaleError reports a suggestion whose item has been removed or edited
+// since the suggestion was made.
+type StaleError struct {
+	SuggestionID string
+	ItemID       string
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%s: %s has changed since the suggestion was made", e.SuggestionID, e.ItemID)
+}
+
+// Apply makes the changes of suggestions in a section and returns the new
+// section JSON. Every suggestion must belong to the section.
+func Apply(section string, data *string, suggestions []Suggestion) (string, error) {
+	idField, ok := lists[section]
+	if !ok {
+		return "", fmt.Errorf("section %s has no suggestions", section)
+	}
+
+	doc := map[string]interface{}{}
+	if data != nil {
+		if err := json.Unmarshal([]byte(*data), &doc); err != nil {
+			return "", fmt.Errorf("invalid %s document: %w", section, err)
+		}
+	}
+	items, _ := doc[section].([]interface{})
+
+	for _, s := range suggestions {
+		var target map[string]interface{}
+		for _, value := range items {
+			if item, ok := value.(map[string]interface{}); ok && item[idField] == s.ItemID {
+				target = item
+				break
+			}
+		}
+		if target == nil {
+			return "", &StaleError{SuggestionID: s.ID, ItemID: s.ItemID}
+		}
+
+		for _, c := range s.Changes {
+			if !reflect.DeepEqual(target[c.Field], c.From) {
+				return "", &StaleError{SuggestionID: s.ID, ItemID: s.ItemID}
+			}
+		}
+		for _, c := range s.Changes {
+			target[c.Field] = c.To
+		}
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+type item map[string]interface{}
+
+func (i item) str(field string) string {
+	value, _ := i[field].(string)
+	return value
+}
+
+func (i item) strings(field string) []string {
+	values, _ := i[field].([]interface{})
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+type documents map[string]item
+
+func decode(sections map[string]*string) documents {
+	docs := make(documents)
+	for name, data := range sections {
+		doc := item{}
+		if data != nil {
+			json.Unmarshal([]byte(*data), &doc)
+		}
+		docs[name] = doc
+	}
+	return docs
+}
+
+// items returns the elements of a section's main array, which is named
+// after the section.
+func (d documents) items(section string) []item {
+	values, _ := d[section][section].([]interface{})
+	var result []item
+	for _, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func set(values []string) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, v := range values {
+		result[v] = true
+	}
+	return result
+}
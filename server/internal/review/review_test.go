@@ -0,0 +1,154 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func sections(threats, risks, mitigations string) map[string]*string {
+	return map[string]*string{"threats": &threats, "risks": &risks, "mitigations": &mitigations}
+}
+
+func TestSuggest(t *testing.T) {
+	threats := `{"threats": [
+		{"threat_id": "t-low", "name": "Phishing", "likelihood": "low", "likelihood_score": 1},
+		{"threat_id": "t-high", "name": "Doxing", "likelihood": "high"},
+		{"threat_id": "t-other", "name": "Flood", "likelihood": "low"}
+	]}`
+	risks := `{"risks": [
+		{"risk_id": "r-threat", "threat_id": "t-low", "asset_id": "a-other", "status": "mitigated"},
+		{"risk_id": "r-asset", "threat_id": "t-other", "asset_id": "a-linked", "status": "mitigated"},
+		{"risk_id": "r-open", "threat_id": "t-low", "asset_id": "a-other", "status": "identified"},
+		{"risk_id": "r-unlinked", "threat_id": "t-other", "asset_id": "a-other", "status": "mitigated"}
+	]}`
+	mitigations := `{"mitigations": [
+		{"mitigation_id": "m-linked", "title": "MFA", "status": "completed", "risk_ids": ["r-open"]},
+		{"mitigation_id": "m-running", "title": "Training", "status": "in_progress", "risk_ids": ["r-threat"]},
+		{"mitigation_id": "m-unlinked", "title": "Backups", "status": "completed", "risk_ids": ["r-unlinked"]}
+	]}`
+
+	tests := []struct {
+		name  string
+		links Links
+		want  []string
+	}{
+		{
+			name:  "linked threat",
+			links: Links{ThreatIDs: []string{"t-low"}},
+			want:  []string{KindRaiseLikelihood + " t-low", KindReopenRisk + " r-threat", KindReopenMitigation + " m-linked"},
+		},
+		{
+			name:  "linked asset",
+			links: Links{AssetIDs: []string{"a-linked"}},
+			want:  []string{KindReopenRisk + " r-asset"},
+		},
+		{
+			name:  "likelihood already high",
+			links: Links{ThreatIDs: []string{"t-high"}},
+			want:  nil,
+		},
+		{
+			name:  "nothing linked",
+			links: Links{},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := Suggest(sections(threats, risks, mitigations), tt.links)
+
+			var got []string
+			for _, s := range suggestions {
+				got = append(got, s.Kind+" "+s.ItemID)
+				if s.Status != StatusPending {
+					t.Errorf("%s status = %q, want pending", s.ID, s.Status)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("suggestions = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("suggestions = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestRaisesLikelihoodScore(t *testing.T) {
+	suggestions := Suggest(sections(`{"threats": [{"threat_id": "t", "likelihood": "medium"}]}`, `{}`, `{}`),
+		Links{ThreatIDs: []string{"t"}})
+	if len(suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want 1", len(suggestions))
+	}
+
+	want := []Change{
+		{Field: "likelihood", From: "medium", To: "high"},
+		{Field: "likelihood_score", From: nil, To: 3.0},
+	}
+	for i, change := range suggestions[0].Changes {
+		if change != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, change, want[i])
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	threats := `{"threats": [{"threat_id": "t", "likelihood": "low", "likelihood_score": 1}]}`
+	raise := Suggestion{
+		ID:      "suggestion-1",
+		Section: "threats",
+		ItemID:  "t",
+		Changes: []Change{
+			{Field: "likelihood", From: "low", To: "medium"},
+			{Field: "likelihood_score", From: 1.0, To: 2.0},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		data  string
+		stale bool
+	}{
+		{name: "unchanged item", data: threats},
+		{name: "edited since", data: `{"threats": [{"threat_id": "t", "likelihood": "high", "likelihood_score": 3}]}`, stale: true},
+		{name: "removed since", data: `{"threats": []}`, stale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Apply("threats", &tt.data, []Suggestion{raise})
+
+			var stale *StaleError
+			if tt.stale {
+				if !errors.As(err, &stale) || stale.SuggestionID != "suggestion-1" || stale.ItemID != "t" {
+					t.Fatalf("Apply error = %v, want a StaleError for suggestion-1", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var doc struct {
+				Threats []map[string]interface{} `json:"threats"`
+			}
+			if err := json.Unmarshal([]byte(data), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.Threats[0]["likelihood"] != "medium" || doc.Threats[0]["likelihood_score"] != 2.0 {
+				t.Errorf("threat = %v, want medium/2", doc.Threats[0])
+			}
+		})
+	}
+}
+
+func TestApplyUnknownSection(t *testing.T) {
+	data := `{"assets": []}`
+	if _, err := Apply("assets", &data, nil); err == nil {
+		t.Fatal("Apply accepted a section without suggestions")
+	}
+}
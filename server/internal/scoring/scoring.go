@@ -0,0 +1,241 @@
+// Package scoring implements the ARMOR three-factor risk model:
+// Risk Score = Asset Value × Likelihood × Vulnerability, each scored 1-3.
+package scoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Band is the lowest score that falls into a risk level.
+type Band struct {
+	Level    string `json:"level"`
+	MinScore int    `json:"min_score"`
+}
+
+// Bands are ordered from the highest level to the lowest.
+type Bands []Band
+
+// DefaultBands are the methodology's priority bands: critical (18-27),
+// high (10-17), moderate (4-9) and low (1-3).
+var DefaultBands = Bands{
+	{Level: "critical", MinScore: 18},
+	{Level: "high", MinScore: 10},
+	{Level: "moderate", MinScore: 4},
+	{Level: "low", MinScore: 1},
+}
+
+var riskLevels = map[string]bool{"critical": true, "high": true, "moderate": true, "low": true}
+
+// ParseBands reads bands written as "critical=18,high=10,moderate=4,low=1".
+// Every risk level must be given a distinct minimum score.
+func ParseBands(spec string) (Bands, error) {
+	var bands Bands
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		level, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid band %q", part)
+		}
+		level = strings.TrimSpace(level)
+		if !riskLevels[level] {
+			return nil, fmt.Errorf("unknown risk level %q", level)
+		}
+		if seen[level] {
+			return nil, fmt.Errorf("duplicate risk level %q", level)
+		}
+		minScore, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || minScore < 1 || minScore > 27 {
+			return nil, fmt.Errorf("invalid minimum score for %s", level)
+		}
+		seen[level] = true
+		bands = append(bands, Band{Level: level, MinScore: minScore})
+	}
+
+	if len(bands) != len(riskLevels) {
+		return nil, fmt.Errorf("bands must cover critical, high, moderate and low")
+	}
+
+	sort.Slice(bands, func(i, j int) bool { return bands[i].MinScore > bands[j].MinScore })
+	for i := 1; i < len(bands); i++ {
+		if bands[i].MinScore == bands[i-1].MinScore {
+			return nil, fmt.Errorf("bands %s and %s share a minimum score", bands[i-1].Level, bands[i].Level)
+		}
+	}
+	if bands[len(bands)-1].MinScore != 1 {
+		return nil, fmt.Errorf("the lowest band must start at 1")
+	}
+
+	return bands, nil
+}
+
+// Level returns the risk level a score falls into.
+func (b Bands) Level(score int) string {
+	for _, band := range b {
+		if score >= band.MinScore {
+			return band.Level
+		}
+	}
+	return b[len(b)-1].Level
+}
+
+// Score returns the risk score for the three factors.
+func Score(assetValue, likelihood, vulnerability int) int {
+	return assetValue * likelihood * vulnerability
+}
+
+// PrimaryDriver names the factor contributing most to a score. Ties go to
+// vulnerability, then likelihood, because those are the factors an
+// organization can act on.
+func PrimaryDriver(assetValue, likelihood, vulnerability int) string {
+	switch {
+	case vulnerability >= likelihood && vulnerability >= assetValue:
+		return "vulnerability"
+	case likelihood >= assetValue:
+		return "likelihood"
+	default:
+		return "asset_value"
+	}
+}
+
+// Discrepancy is a submitted value that differs from what the server computed.
+type Discrepancy struct {
+	RiskID    string      `json:"risk_id"`
+	Path      string      `json:"path"`
+	Submitted interface{} `json:"submitted"`
+	Computed  interface{} `json:"computed"`
+	Message   string      `json:"message"`
+}
+
+var assetValueScores = map[string]int{"critical": 3, "high": 2, "medium": 2, "low": 1}
+
+var likelihoodScores = map[string]int{"high": 3, "medium": 2, "low": 1}
+
+// ScoreRisks recomputes every risk's score fields from the sections it
+// references. asset_value_score comes from the asset's risk_score_value (or
+// its value rating), likelihood_score from the threat's likelihood_score (or
+// its likelihood rating). When a referenced item cannot be found the risk's
+// own factor scores are used. It returns the updated risks document and the
+// submitted values that disagreed with the computed ones.
+func ScoreRisks(risks string, assets, threats *string, bands Bands) (string, []Discrepancy, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(risks), &doc); err != nil {
+		return "", nil, fmt.Errorf("invalid risks document: %w", err)
+	}
+
+	assetScores := lookupScores(assets, "assets", "asset_id", "risk_score_value", "value", assetValueScores)
+	threatScores := lookupScores(threats, "threats", "threat_id", "likelihood_score", "likelihood", likelihoodScores)
+
+	discrepancies := []Discrepancy{}
+	items, _ := doc["risks"].([]interface{})
+	for i, item := range items {
+		risk, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		riskID, _ := risk["risk_id"].(string)
+		path := "/risks/" + strconv.Itoa(i)
+		check := func(field string, computed interface{}) {
+			submitted, present := risk[field]
+			if present && !sameValue(submitted, computed) {
+				discrepancies = append(discrepancies, Discrepancy{
+					RiskID:    riskID,
+					Path:      path + "/" + field,
+					Submitted: submitted,
+					Computed:  computed,
+					Message:   fmt.Sprintf("%s should be %v", field, computed),
+				})
+			}
+			risk[field] = computed
+		}
+
+		assetValue, ok := assetScores[stringField(risk, "asset_id")]
+		if ok {
+			check("asset_value_score", assetValue)
+		} else {
+			assetValue = intField(risk, "asset_value_score")
+		}
+
+		likelihood, ok := threatScores[stringField(risk, "threat_id")]
+		if ok {
+			check("likelihood_score", likelihood)
+		} else {
+			likelihood = intField(risk, "likelihood_score")
+		}
+
+		vulnerability := intField(risk, "vulnerability_score")
+		if assetValue == 0 || likelihood == 0 || vulnerability == 0 {
+			continue
+		}
+
+		score := Score(assetValue, likelihood, vulnerability)
+		check("risk_score", score)
+		check("risk_level", bands.Level(score))
+		check("primary_risk_driver", PrimaryDriver(assetValue, likelihood, vulnerability))
+	}
+
+	updated, err := json.Marshal(doc)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode risks: %w", err)
+	}
+
+	return string(updated), discrepancies, nil
+}
+
+// lookupScores maps item IDs in a section to their numeric score, falling
+// back to the rating field when the numeric field is missing.
+func lookupScores(data *string, list, idField, scoreField, ratingField string, ratings map[string]int) map[string]int {
+	scores := make(map[string]int)
+	if data == nil {
+		return scores
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(*data), &doc); err != nil {
+		return scores
+	}
+
+	items, _ := doc[list].([]interface{})
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := stringField(fields, idField)
+		if id == "" {
+			continue
+		}
+		if score := intField(fields, scoreField); score > 0 {
+			scores[id] = score
+		} else if score, ok := ratings[stringField(fields, ratingField)]; ok {
+			scores[id] = score
+		}
+	}
+
+	return scores
+}
+
+func stringField(fields map[string]interface{}, name string) string {
+	value, _ := fields[name].(string)
+	return value
+}
+
+func intField(fields map[string]interface{}, name string) int {
+	value, _ := fields[name].(float64)
+	return int(value)
+}
+
+func sameValue(submitted, computed interface{}) bool {
+	switch c := computed.(type) {
+	case int:
+		n, ok := submitted.(float64)
+		return ok && n == float64(c)
+	default:
+		return submitted == computed
+	}
+}
@@ -0,0 +1,233 @@
+package scoring
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseBands(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Bands
+		wantErr bool
+	}{
+		{
+			name: "default",
+			spec: "critical=18,high=10,moderate=4,low=1",
+			want: DefaultBands,
+		},
+		{
+			name: "any order with spaces",
+			spec: " low=1, moderate=5 ,high=12,critical=20",
+			want: Bands{{"critical", 20}, {"high", 12}, {"moderate", 5}, {"low", 1}},
+		},
+		{name: "missing level", spec: "critical=18,high=10,low=1", wantErr: true},
+		{name: "unknown level", spec: "critical=18,high=10,moderate=4,low=1,severe=25", wantErr: true},
+		{name: "duplicate level", spec: "critical=18,critical=10,moderate=4,low=1", wantErr: true},
+		{name: "shared minimum", spec: "critical=10,high=10,moderate=4,low=1", wantErr: true},
+		{name: "lowest above one", spec: "critical=18,high=10,moderate=4,low=2", wantErr: true},
+		{name: "out of range", spec: "critical=28,high=10,moderate=4,low=1", wantErr: true},
+		{name: "not a number", spec: "critical=x,high=10,moderate=4,low=1", wantErr: true},
+		{name: "no equals sign", spec: "critical,high=10,moderate=4,low=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBands(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseBands(%q) = %v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBands(%q): %v", tt.spec, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseBands(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("ParseBands(%q) = %v, want %v", tt.spec, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBandsLevel(t *testing.T) {
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{1, "low"},
+		{3, "low"},
+		{4, "moderate"},
+		{9, "moderate"},
+		{10, "high"},
+		{17, "high"},
+		{18, "critical"},
+		{27, "critical"},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultBands.Level(tt.score); got != tt.want {
+			t.Errorf("Level(%d) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestPrimaryDriver(t *testing.T) {
+	tests := []struct {
+		assetValue, likelihood, vulnerability int
+		want                                  string
+	}{
+		{1, 1, 3, "vulnerability"},
+		{1, 3, 1, "likelihood"},
+		{3, 1, 1, "asset_value"},
+		{2, 2, 2, "vulnerability"},
+		{3, 3, 1, "likelihood"},
+		{3, 2, 3, "vulnerability"},
+	}
+
+	for _, tt := range tests {
+		if got := PrimaryDriver(tt.assetValue, tt.likelihood, tt.vulnerability); got != tt.want {
+			t.Errorf("PrimaryDriver(%d, %d, %d) = %q, want %q", tt.assetValue, tt.likelihood, tt.vulnerability, got, tt.want)
+		}
+	}
+}
+
+func TestScoreRisks(t *testing.T) {
+	assets := `{"assets": [
+		{"asset_id": "a-rated", "value": "critical"},
+		{"asset_id": "a-scored", "value": "low", "risk_score_value": 2}
+	]}`
+	threats := `{"threats": [
+		{"threat_id": "t-rated", "likelihood": "medium"},
+		{"threat_id": "t-scored", "likelihood": "low", "likelihood_score": 3}
+	]}`
+
+	tests := []struct {
+		name          string
+		risk          string
+		want          map[string]interface{}
+		discrepancies []string
+	}{
+		{
+			name: "factors from ratings",
+			risk: `{"risk_id": "r", "asset_id": "a-rated", "threat_id": "t-rated", "vulnerability_score": 3}`,
+			want: map[string]interface{}{
+				"asset_value_score":   3.0,
+				"likelihood_score":    2.0,
+				"risk_score":          18.0,
+				"risk_level":          "critical",
+				"primary_risk_driver": "vulnerability",
+			},
+		},
+		{
+			name: "numeric scores win over ratings",
+			risk: `{"risk_id": "r", "asset_id": "a-scored", "threat_id": "t-scored", "vulnerability_score": 1}`,
+			want: map[string]interface{}{
+				"asset_value_score":   2.0,
+				"likelihood_score":    3.0,
+				"risk_score":          6.0,
+				"risk_level":          "moderate",
+				"primary_risk_driver": "likelihood",
+			},
+		},
+		{
+			name: "unknown references keep the risk's own factors",
+			risk: `{"risk_id": "r", "asset_id": "gone", "threat_id": "gone", "asset_value_score": 1, "likelihood_score": 1, "vulnerability_score": 2}`,
+			want: map[string]interface{}{
+				"asset_value_score":   1.0,
+				"likelihood_score":    1.0,
+				"risk_score":          2.0,
+				"risk_level":          "low",
+				"primary_risk_driver": "vulnerability",
+			},
+		},
+		{
+			name: "submitted values that disagree are corrected",
+			risk: `{"risk_id": "r", "asset_id": "a-rated", "threat_id": "t-rated", "asset_value_score": 1,
+				"likelihood_score": 2, "vulnerability_score": 3, "risk_score": 6, "risk_level": "moderate"}`,
+			want: map[string]interface{}{
+				"asset_value_score": 3.0,
+				"risk_score":        18.0,
+				"risk_level":        "critical",
+			},
+			discrepancies: []string{
+				"/risks/0/asset_value_score",
+				"/risks/0/risk_score",
+				"/risks/0/risk_level",
+			},
+		},
+		{
+			name: "missing vulnerability leaves the score alone",
+			risk: `{"risk_id": "r", "asset_id": "a-rated", "threat_id": "t-rated", "risk_score": 5}`,
+			want: map[string]interface{}{
+				"asset_value_score": 3.0,
+				"likelihood_score":  2.0,
+				"risk_score":        5.0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scored, discrepancies, err := ScoreRisks(`{"risks": [`+tt.risk+`]}`, &assets, &threats, DefaultBands)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var doc struct {
+				Risks []map[string]interface{} `json:"risks"`
+			}
+			if err := json.Unmarshal([]byte(scored), &doc); err != nil {
+				t.Fatal(err)
+			}
+			for field, want := range tt.want {
+				if got := doc.Risks[0][field]; got != want {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+
+			if len(discrepancies) != len(tt.discrepancies) {
+				t.Fatalf("got %d discrepancies %+v, want %v", len(discrepancies), discrepancies, tt.discrepancies)
+			}
+			for i, path := range tt.discrepancies {
+				if discrepancies[i].Path != path {
+					t.Errorf("discrepancy %d path = %q, want %q", i, discrepancies[i].Path, path)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreRisksBands(t *testing.T) {
+	bands, err := ParseBands("critical=27,high=12,moderate=2,low=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scored, _, err := ScoreRisks(`{"risks": [{"risk_id": "r", "asset_value_score": 3, "likelihood_score": 2, "vulnerability_score": 3}]}`, nil, nil, bands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Risks []map[string]interface{} `json:"risks"`
+	}
+	if err := json.Unmarshal([]byte(scored), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Risks[0]["risk_level"]; got != "high" {
+		t.Errorf("risk_level = %v, want high", got)
+	}
+}
+
+func TestScoreRisksInvalidDocument(t *testing.T) {
+	if _, _, err := ScoreRisks(`not json`, nil, nil, DefaultBands); err == nil {
+		t.Fatal("ScoreRisks accepted an invalid document")
+	}
+}
@@ -0,0 +1,136 @@
+// Package summary recomputes the aggregate blocks that the risks and
+// mitigations sections carry alongside their item arrays. The server owns
+// these values so they always agree with the items they describe. The risk
+// summary is recounted whenever the risks are rescored, including after a
+// change to the assets or threats they are scored from.
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// topRiskCount is how many risks are listed in risk_summary.top_risk_ids.
+const topRiskCount = 5
+
+var mitigationStatuses = []string{"planned", "in_progress", "completed", "blocked", "deferred"}
+
+// Risks rewrites risk_summary from the risks array. The narrative
+// overall_risk_posture is left as submitted.
+func Risks(data string) (string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return "", fmt.Errorf("invalid risks document: %w", err)
+	}
+
+	type scored struct {
+		id    string
+		score float64
+	}
+
+	counts := map[string]int{"critical": 0, "high": 0, "moderate": 0, "low": 0}
+	var ranked []scored
+	vulnerabilityDriven := []string{}
+
+	items, _ := doc["risks"].([]interface{})
+	for _, item := range items {
+		risk, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		id, _ := risk["risk_id"].(string)
+		if level, ok := risk["risk_level"].(string); ok {
+			if _, known := counts[level]; known {
+				counts[level]++
+			}
+		}
+		if score, ok := risk["risk_score"].(float64); ok && id != "" {
+			ranked = append(ranked, scored{id: id, score: score})
+		}
+		if driver, _ := risk["primary_risk_driver"].(string); driver == "vulnerability" && id != "" {
+			vulnerabilityDriven = append(vulnerabilityDriven, id)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	topRiskIDs := []string{}
+	for i := 0; i < len(ranked) && i < topRiskCount; i++ {
+		topRiskIDs = append(topRiskIDs, ranked[i].id)
+	}
+
+	riskSummary, _ := doc["risk_summary"].(map[string]interface{})
+	if riskSummary == nil {
+		riskSummary = make(map[string]interface{})
+	}
+	riskSummary["total_risks"] = len(items)
+	riskSummary["critical_risks"] = counts["critical"]
+	riskSummary["high_risks"] = counts["high"]
+	riskSummary["moderate_risks"] = counts["moderate"]
+	riskSummary["low_risks"] = counts["low"]
+	riskSummary["top_risk_ids"] = topRiskIDs
+	riskSummary["vulnerability_driven_risks"] = vulnerabilityDriven
+	doc["risk_summary"] = riskSummary
+
+	return encode(doc)
+}
+
+// Mitigations rewrites progress_tracking.status_summary and
+// completion_percentage from the mitigations array. Review dates are left as
+// submitted. Cancelled mitigations do not count towards completion.
+func Mitigations(data string) (string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return "", fmt.Errorf("invalid mitigations document: %w", err)
+	}
+
+	statusSummary := make(map[string]interface{})
+	counts := make(map[string]int)
+	active := 0
+
+	items, _ := doc["mitigations"].([]interface{})
+	for _, item := range items {
+		mitigation, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, _ := mitigation["status"].(string)
+		if status == "" {
+			status = "planned"
+		}
+		if status == "cancelled" {
+			continue
+		}
+		counts[status]++
+		active++
+	}
+
+	for _, status := range mitigationStatuses {
+		statusSummary[status] = counts[status]
+	}
+
+	completion := 0.0
+	if active > 0 {
+		completion = math.Round(float64(counts["completed"])/float64(active)*1000) / 10
+	}
+
+	progress, _ := doc["progress_tracking"].(map[string]interface{})
+	if progress == nil {
+		progress = make(map[string]interface{})
+	}
+	progress["status_summary"] = statusSummary
+	progress["completion_percentage"] = completion
+	doc["progress_tracking"] = progress
+
+	return encode(doc)
+}
+
+func encode(doc map[string]interface{}) (string, error) {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode section: %w", err)
+	}
+	return string(encoded), nil
+}
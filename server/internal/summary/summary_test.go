@@ -0,0 +1,153 @@
+package summary
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestRisks(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want map[string]interface{}
+	}{
+		{
+			name: "empty",
+			doc:  `{"risks": []}`,
+			want: map[string]interface{}{
+				"total_risks":                0.0,
+				"critical_risks":             0.0,
+				"high_risks":                 0.0,
+				"moderate_risks":             0.0,
+				"low_risks":                  0.0,
+				"top_risk_ids":               []interface{}{},
+				"vulnerability_driven_risks": []interface{}{},
+			},
+		},
+		{
+			name: "counts, ranking and drivers",
+			doc: `{"risks": [
+				{"risk_id": "r1", "risk_score": 6, "risk_level": "moderate", "primary_risk_driver": "likelihood"},
+				{"risk_id": "r2", "risk_score": 27, "risk_level": "critical", "primary_risk_driver": "vulnerability"},
+				{"risk_id": "r3", "risk_score": 12, "risk_level": "high", "primary_risk_driver": "asset_value"},
+				{"risk_id": "r4", "risk_score": 2, "risk_level": "low", "primary_risk_driver": "vulnerability"},
+				{"risk_id": "r5", "risk_score": 12, "risk_level": "high"},
+				{"risk_id": "r6", "risk_score": 1, "risk_level": "low"},
+				{"risk_id": "r7", "risk_level": "unknown"}
+			]}`,
+			want: map[string]interface{}{
+				"total_risks":                7.0,
+				"critical_risks":             1.0,
+				"high_risks":                 2.0,
+				"moderate_risks":             1.0,
+				"low_risks":                  2.0,
+				"top_risk_ids":               []interface{}{"r2", "r3", "r5", "r1", "r4"},
+				"vulnerability_driven_risks": []interface{}{"r2", "r4"},
+			},
+		},
+		{
+			name: "narrative kept, stale counts replaced",
+			doc: `{"risks": [{"risk_id": "r1", "risk_score": 18, "risk_level": "critical"}],
+				"risk_summary": {"overall_risk_posture": "Exposed", "critical_risks": 9, "top_risk_ids": ["gone"]}}`,
+			want: map[string]interface{}{
+				"overall_risk_posture": "Exposed",
+				"critical_risks":       1.0,
+				"top_risk_ids":         []interface{}{"r1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Risks(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			riskSummary := decode(t, data)["risk_summary"].(map[string]interface{})
+			for field, want := range tt.want {
+				if got := riskSummary[field]; !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMitigations(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		statuses   map[string]interface{}
+		completion float64
+		kept       map[string]interface{}
+	}{
+		{
+			name:       "empty",
+			doc:        `{"mitigations": []}`,
+			statuses:   map[string]interface{}{"planned": 0.0, "in_progress": 0.0, "completed": 0.0, "blocked": 0.0, "deferred": 0.0},
+			completion: 0,
+		},
+		{
+			name: "cancelled left out, missing status planned",
+			doc: `{"mitigations": [
+				{"mitigation_id": "m1", "status": "completed"},
+				{"mitigation_id": "m2", "status": "in_progress"},
+				{"mitigation_id": "m3"},
+				{"mitigation_id": "m4", "status": "cancelled"}
+			]}`,
+			statuses:   map[string]interface{}{"planned": 1.0, "in_progress": 1.0, "completed": 1.0, "blocked": 0.0, "deferred": 0.0},
+			completion: 33.3,
+		},
+		{
+			name: "review dates kept",
+			doc: `{"mitigations": [{"mitigation_id": "m1", "status": "completed"}],
+				"progress_tracking": {"next_review": "2030-01-01", "completion_percentage": 5}}`,
+			statuses:   map[string]interface{}{"planned": 0.0, "in_progress": 0.0, "completed": 1.0, "blocked": 0.0, "deferred": 0.0},
+			completion: 100,
+			kept:       map[string]interface{}{"next_review": "2030-01-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Mitigations(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			progress := decode(t, data)["progress_tracking"].(map[string]interface{})
+			if got := progress["status_summary"]; !reflect.DeepEqual(got, tt.statuses) {
+				t.Errorf("status_summary = %v, want %v", got, tt.statuses)
+			}
+			if got := progress["completion_percentage"]; got != tt.completion {
+				t.Errorf("completion_percentage = %v, want %v", got, tt.completion)
+			}
+			for field, want := range tt.kept {
+				if got := progress[field]; got != want {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidDocuments(t *testing.T) {
+	if _, err := Risks(`[`); err == nil {
+		t.Error("Risks accepted an invalid document")
+	}
+	if _, err := Mitigations(`[`); err == nil {
+		t.Error("Mitigations accepted an invalid document")
+	}
+}
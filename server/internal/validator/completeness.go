@@ -2,128 +2,267 @@ package validator
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/HyphaGroup/armor/server/internal/catalog"
+	"github.com/HyphaGroup/armor/server/internal/jsondiff"
+)
+
+// Field levels. Required fields come from each schema's "required" keyword,
+// recommended fields from the non-standard "recommended" keyword next to it,
+// which validators ignore.
+const (
+	LevelRequired    = "required"
+	LevelRecommended = "recommended"
 )
 
+type MissingField struct {
+	Path   string `json:"path"`
+	Level  string `json:"level"`
+	Weight int    `json:"weight"`
+}
+
 type SectionCompleteness struct {
-	Section    string  `json:"section"`
-	Percentage float64 `json:"percentage"`
-	Filled     int     `json:"filled"`
-	Total      int     `json:"total"`
+	Section    string         `json:"section"`
+	Percentage float64        `json:"percentage"`
+	Filled     int            `json:"filled"`
+	Total      int            `json:"total"`
+	Missing    []MissingField `json:"missing"`
 }
 
 type ProfileCompleteness struct {
 	Overall  float64               `json:"overall"`
 	Sections []SectionCompleteness `json:"sections"`
+	Modules  []SectionCompleteness `json:"modules"`
 }
 
-var requiredFields = map[string][]string{
-	"mission": {
-		"mission_statement",
-		"impact_areas",
-	},
-	"assets": {
-		"assets",
-	},
-	"adversaries": {
-		"adversaries",
-	},
-	"threats": {
-		"threats",
-	},
-	"risks": {
-		"risks",
-	},
-	"mitigations": {
-		"mitigations",
-	},
-}
-
-func CalculateSectionCompleteness(section string, data *string) SectionCompleteness {
-	fields, ok := requiredFields[section]
-	if !ok {
-		return SectionCompleteness{Section: section, Percentage: 0, Filled: 0, Total: 0}
-	}
+// Weights sets how much each field counts towards completeness. Fields take
+// the weight of their level unless a path override matches. Override keys
+// are "section:/pointer" with "*" standing for any array index, e.g.
+// "risks:/risks/*/likelihood_rationale".
+type Weights struct {
+	Required    int
+	Recommended int
+	Paths       map[string]int
+}
 
-	total := len(fields)
-	if data == nil || *data == "" {
-		return SectionCompleteness{
-			Section:    section,
-			Percentage: 0,
-			Filled:     0,
-			Total:      total,
-		}
+var DefaultWeights = Weights{Required: 3, Recommended: 1}
+
+// ParseWeights reads a comma-separated list such as
+// "required=3,recommended=1,risks:/risks/*/likelihood_rationale=2".
+// Levels not mentioned keep their default weight.
+func ParseWeights(spec string) (Weights, error) {
+	weights := Weights{
+		Required:    DefaultWeights.Required,
+		Recommended: DefaultWeights.Recommended,
+		Paths:       make(map[string]int),
 	}
 
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal([]byte(*data), &jsonData); err != nil {
-		return SectionCompleteness{
-			Section:    section,
-			Percentage: 0,
-			Filled:     0,
-			Total:      total,
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Weights{}, fmt.Errorf("invalid weight %q", part)
+		}
+		key = strings.TrimSpace(key)
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return Weights{}, fmt.Errorf("invalid weight for %s", key)
 		}
-	}
 
-	filled := 0
-	for _, field := range fields {
-		if isFieldFilled(jsonData, field) {
-			filled++
+		switch {
+		case key == LevelRequired:
+			weights.Required = weight
+		case key == LevelRecommended:
+			weights.Recommended = weight
+		case strings.Contains(key, ":/"):
+			weights.Paths[key] = weight
+		default:
+			return Weights{}, fmt.Errorf("unknown weight key %q", key)
 		}
 	}
 
-	percentage := 0.0
-	if total > 0 {
-		percentage = float64(filled) / float64(total) * 100
-	}
+	return weights, nil
+}
 
-	return SectionCompleteness{
-		Section:    section,
-		Percentage: percentage,
-		Filled:     filled,
-		Total:      total,
+func (w Weights) weight(section, pattern, level string) int {
+	if weight, ok := w.Paths[section+":"+pattern]; ok {
+		return weight
 	}
+	if level == LevelRequired {
+		return w.Required
+	}
+	return w.Recommended
 }
 
-func isFieldFilled(data map[string]interface{}, field string) bool {
-	val, ok := data[field]
+// SectionCompleteness scores a section against the required and recommended
+// fields of its schema at every depth. Array items are scored individually,
+// so a stub row with empty fields lowers the percentage rather than
+// completing the section.
+func (v *Validator) SectionCompleteness(section string, data *string, weights Weights) SectionCompleteness {
+	result := SectionCompleteness{Section: section, Missing: []MissingField{}}
+
+	schema, ok := v.documents[section]
 	if !ok {
-		return false
+		return result
 	}
 
-	switch v := val.(type) {
-	case nil:
-		return false
-	case string:
-		return v != ""
-	case []interface{}:
-		return len(v) > 0
-	case map[string]interface{}:
-		return len(v) > 0
-	default:
-		return true
+	var value interface{}
+	if data != nil && *data != "" {
+		if err := json.Unmarshal([]byte(*data), &value); err != nil {
+			value = nil
+		}
 	}
-}
 
-func CalculateProfileCompleteness(sections map[string]*string) ProfileCompleteness {
-	sectionNames := []string{"mission", "assets", "adversaries", "threats", "risks", "mitigations"}
+	s := &scorer{
+		section:     section,
+		definitions: object(schema["definitions"]),
+		weights:     weights,
+		result:      &result,
+	}
+	s.walkObject(schema, value, "", "")
+
+	if result.Total > 0 {
+		result.Percentage = float64(result.Filled) / float64(result.Total) * 100
+	}
+
+	return result
+}
 
+func (v *Validator) ProfileCompleteness(sections map[string]*string, weights Weights) ProfileCompleteness {
 	var sectionResults []SectionCompleteness
 	totalPercentage := 0.0
 
-	for _, name := range sectionNames {
-		data := sections[name]
-		result := CalculateSectionCompleteness(name, data)
+	for _, name := range catalog.CoreSections {
+		result := v.SectionCompleteness(name, sections[name], weights)
 		sectionResults = append(sectionResults, result)
 		totalPercentage += result.Percentage
 	}
 
 	overall := 0.0
-	if len(sectionNames) > 0 {
-		overall = totalPercentage / float64(len(sectionNames))
+	if len(catalog.CoreSections) > 0 {
+		overall = totalPercentage / float64(len(catalog.CoreSections))
+	}
+
+	// Optional modules are reported alongside the core sections but do not
+	// count towards the overall percentage, since most profiles never
+	// complete them.
+	var moduleResults []SectionCompleteness
+	for _, name := range catalog.ModuleSections {
+		moduleResults = append(moduleResults, v.SectionCompleteness(name, sections[name], weights))
 	}
 
 	return ProfileCompleteness{
 		Overall:  overall,
 		Sections: sectionResults,
+		Modules:  moduleResults,
+	}
+}
+
+type scorer struct {
+	section     string
+	definitions map[string]interface{}
+	weights     Weights
+	result      *SectionCompleteness
+}
+
+// walkObject scores the required and recommended properties of an object
+// schema. Properties that are neither are skipped even when present, so
+// filling in optional detail never lowers the score.
+func (s *scorer) walkObject(schema map[string]interface{}, value interface{}, path, pattern string) {
+	data, _ := value.(map[string]interface{})
+	properties := object(schema["properties"])
+
+	for _, field := range s.fields(schema) {
+		child := s.resolve(object(properties[field.name]))
+		s.walk(child, data[field.name], path+"/"+jsondiff.EscapePointer(field.name), pattern+"/"+jsondiff.EscapePointer(field.name), field.level)
+	}
+}
+
+func (s *scorer) walk(schema map[string]interface{}, value interface{}, path, pattern, level string) {
+	// Objects with scored properties of their own are containers: their
+	// children count instead of the object itself.
+	if len(s.fields(schema)) > 0 {
+		if _, ok := value.(map[string]interface{}); ok || value == nil {
+			s.walkObject(schema, value, path, pattern)
+			return
+		}
+	}
+
+	s.count(isFilled(value), path, pattern, level)
+
+	items := s.resolve(object(schema["items"]))
+	list, _ := value.([]interface{})
+	if len(s.fields(items)) == 0 {
+		return
+	}
+	for i, item := range list {
+		s.walkObject(items, item, path+"/"+strconv.Itoa(i), pattern+"/*")
+	}
+}
+
+func (s *scorer) count(filled bool, path, pattern, level string) {
+	weight := s.weights.weight(s.section, pattern, level)
+	if weight == 0 {
+		return
+	}
+
+	s.result.Total += weight
+	if filled {
+		s.result.Filled += weight
+		return
+	}
+	s.result.Missing = append(s.result.Missing, MissingField{Path: path, Level: level, Weight: weight})
+}
+
+type scoredField struct {
+	name  string
+	level string
+}
+
+func (s *scorer) fields(schema map[string]interface{}) []scoredField {
+	var fields []scoredField
+	seen := make(map[string]bool)
+
+	for _, level := range []string{LevelRequired, LevelRecommended} {
+		list, _ := schema[level].([]interface{})
+		for _, name := range list {
+			if name, ok := name.(string); ok && !seen[name] {
+				seen[name] = true
+				fields = append(fields, scoredField{name: name, level: level})
+			}
+		}
+	}
+
+	return fields
+}
+
+// resolve follows local "#/definitions/..." references.
+func (s *scorer) resolve(schema map[string]interface{}) map[string]interface{} {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	return object(s.definitions[strings.TrimPrefix(ref, "#/definitions/")])
+}
+
+func object(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+func isFilled(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
 	}
 }
@@ -0,0 +1,186 @@
+package validator
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	schemas, err := filepath.Abs(filepath.Join("..", "..", "..", "schemas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSectionCompleteness(t *testing.T) {
+	v := newTestValidator(t)
+
+	completeRisk := `{"risk_id": "r1", "scenario": "Donor list leaks", "asset_id": "a1", "threat_id": "t1",
+		"asset_value_score": 2, "likelihood_score": 3, "vulnerability_score": 2,
+		"vulnerability": {"description": "Shared password"}, "likelihood_rationale": "Active campaign",
+		"vulnerability_rationale": "No MFA", "impact_areas": ["donors"], "status": "open"}`
+
+	tests := []struct {
+		name        string
+		section     string
+		data        string
+		weights     Weights
+		wantFilled  int
+		wantTotal   int
+		wantMissing []MissingField
+	}{
+		{
+			name:       "empty section",
+			section:    "risks",
+			weights:    DefaultWeights,
+			wantFilled: 0,
+			wantTotal:  4,
+			wantMissing: []MissingField{
+				{Path: "/risks", Level: LevelRequired, Weight: 3},
+				{Path: "/security_indicators", Level: LevelRecommended, Weight: 1},
+			},
+		},
+		{
+			// A stub row fills the risks array but none of its own fields,
+			// so it scores each of them as missing.
+			name:       "stub risk row",
+			section:    "risks",
+			data:       `{"risks": [{"risk_id": "r1"}], "security_indicators": {"monitored": true}}`,
+			weights:    DefaultWeights,
+			wantFilled: 3 + 1 + 3,
+			wantTotal:  3 + 1 + 7*3 + 5*1,
+			wantMissing: []MissingField{
+				{Path: "/risks/0/scenario", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/asset_id", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/threat_id", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/asset_value_score", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/likelihood_score", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/vulnerability_score", Level: LevelRequired, Weight: 3},
+				{Path: "/risks/0/vulnerability", Level: LevelRecommended, Weight: 1},
+				{Path: "/risks/0/likelihood_rationale", Level: LevelRecommended, Weight: 1},
+				{Path: "/risks/0/vulnerability_rationale", Level: LevelRecommended, Weight: 1},
+				{Path: "/risks/0/impact_areas", Level: LevelRecommended, Weight: 1},
+				{Path: "/risks/0/status", Level: LevelRecommended, Weight: 1},
+			},
+		},
+		{
+			name:        "complete risk row",
+			section:     "risks",
+			data:        `{"risks": [` + completeRisk + `], "security_indicators": {"monitored": true}}`,
+			weights:     DefaultWeights,
+			wantFilled:  3 + 1 + 7*3 + 5*1,
+			wantTotal:   3 + 1 + 7*3 + 5*1,
+			wantMissing: []MissingField{},
+		},
+		{
+			name:    "path override",
+			section: "risks",
+			data: `{"risks": [` + completeRisk + `, {"risk_id": "r2", "scenario": "s", "asset_id": "a1", "threat_id": "t1",
+				"asset_value_score": 1, "likelihood_score": 1, "vulnerability_score": 1}]}`,
+			weights: Weights{Required: 3, Recommended: 0, Paths: map[string]int{"risks:/risks/*/likelihood_rationale": 2}},
+			// Recommended fields count for nothing except the overridden
+			// rationale, which the second row lacks.
+			wantFilled: 3 + 2*7*3 + 2,
+			wantTotal:  3 + 2*7*3 + 2*2,
+			wantMissing: []MissingField{
+				{Path: "/risks/1/likelihood_rationale", Level: LevelRecommended, Weight: 2},
+			},
+		},
+		{
+			name:    "nested array items",
+			section: "assets",
+			data: `{"assets": [{"asset_id": "a1", "name": "Laptops", "category": "devices", "value": "high",
+				"description": "Staff laptops", "owner": "IT", "security_requirements": ["encryption"],
+				"containers": [{"container_type": "laptop", "location": "Office"}]}]}`,
+			weights:    DefaultWeights,
+			wantFilled: 3 + 4*3 + 4*1 + 3 + 1,
+			wantTotal:  3 + 4*3 + 4*1 + 2*3 + 2*1,
+			wantMissing: []MissingField{
+				{Path: "/assets/0/containers/0/description", Level: LevelRequired, Weight: 3},
+				{Path: "/assets/0/containers/0/access_controls", Level: LevelRecommended, Weight: 1},
+			},
+		},
+		{
+			name:       "module section",
+			section:    "opsec",
+			data:       `{"public_exposure": {"website": "example.org"}, "opsec_vulnerabilities": [{"description": "Public staff list"}]}`,
+			weights:    DefaultWeights,
+			wantFilled: 2,
+			wantTotal:  6,
+			wantMissing: []MissingField{
+				{Path: "/operational_patterns", Level: LevelRecommended, Weight: 1},
+				{Path: "/digital_footprint", Level: LevelRecommended, Weight: 1},
+				{Path: "/human_sources", Level: LevelRecommended, Weight: 1},
+				{Path: "/personal_safety", Level: LevelRecommended, Weight: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data *string
+			if tt.data != "" {
+				data = &tt.data
+			}
+
+			got := v.SectionCompleteness(tt.section, data, tt.weights)
+			if got.Filled != tt.wantFilled || got.Total != tt.wantTotal {
+				t.Errorf("filled/total = %d/%d, want %d/%d", got.Filled, got.Total, tt.wantFilled, tt.wantTotal)
+			}
+			if want := float64(tt.wantFilled) / float64(tt.wantTotal) * 100; got.Percentage != want {
+				t.Errorf("percentage = %v, want %v", got.Percentage, want)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("missing = %+v\nwant %+v", got.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Weights
+		wantErr bool
+	}{
+		{
+			spec: "required=5",
+			want: Weights{Required: 5, Recommended: 1, Paths: map[string]int{}},
+		},
+		{
+			spec: " required = 2 , recommended=0, risks:/risks/*/likelihood_rationale=2",
+			want: Weights{Required: 2, Recommended: 0, Paths: map[string]int{"risks:/risks/*/likelihood_rationale": 2}},
+		},
+		{spec: "required", wantErr: true},
+		{spec: "required=high", wantErr: true},
+		{spec: "recommended=-1", wantErr: true},
+		{spec: "optional=1", wantErr: true},
+		{spec: "/risks/*/status=2", wantErr: true},
+		{spec: "required=3,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseWeights(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseWeights(%q) = %+v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWeights(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
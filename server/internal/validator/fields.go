@@ -0,0 +1,175 @@
+package validator
+
+import (
+	"sort"
+	"strings"
+)
+
+// Field types for flat, spreadsheet-style access to array items.
+const (
+	FieldString  = "string"
+	FieldInteger = "integer"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	FieldList    = "list"
+)
+
+// Field is a scalar property of an array item, or a list of scalars. Path
+// is dot separated for properties of nested objects, such as
+// "timeline.target_completion".
+type Field struct {
+	Path     string   `json:"path"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+}
+
+// ItemFields lists the flat fields of the items of an array in a section
+// schema, addressed by a slash separated path such as
+// "mitigations/*/actions". Lists of objects have no flat form and are left
+// out. Required fields come first, then recommended ones, then the rest in
+// name order, so spreadsheets lead with what matters.
+func (v *Validator) ItemFields(section, path string) []Field {
+	schema, ok := v.documents[section]
+	if !ok {
+		return nil
+	}
+	s := &scorer{definitions: object(schema["definitions"])}
+
+	node := schema
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "*" {
+			node = s.resolve(object(node["items"]))
+		} else {
+			node = s.resolve(object(object(node["properties"])[segment]))
+		}
+	}
+	items := s.resolve(object(node["items"]))
+	if items == nil {
+		return nil
+	}
+
+	var fields []Field
+	s.flatten(items, "", true, &fields)
+	return fields
+}
+
+func (s *scorer) flatten(schema map[string]interface{}, prefix string, top bool, fields *[]Field) {
+	properties := object(schema["properties"])
+
+	rank := make(map[string]int)
+	required := make(map[string]bool)
+	for _, f := range s.fields(schema) {
+		rank[f.name] = len(rank) + 1
+		required[f.name] = f.level == LevelRequired
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := rank[names[i]], rank[names[j]]
+		if ri == 0 {
+			ri = len(names) + 1
+		}
+		if rj == 0 {
+			rj = len(names) + 1
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		property := s.resolve(object(properties[name]))
+		path := prefix + name
+		typ, _ := property["type"].(string)
+
+		switch typ {
+		case FieldString, FieldInteger, FieldNumber, FieldBoolean:
+			*fields = append(*fields, Field{
+				Path:     path,
+				Type:     typ,
+				Required: top && required[name],
+				Enum:     enum(property),
+			})
+		case "array":
+			items := s.resolve(object(property["items"]))
+			if itemType, _ := items["type"].(string); itemType == FieldString {
+				*fields = append(*fields, Field{Path: path, Type: FieldList, Required: top && required[name], Enum: enum(items)})
+			}
+		case "object":
+			s.flatten(property, path+".", false, fields)
+		}
+	}
+}
+
+func enum(schema map[string]interface{}) []string {
+	values, _ := schema["enum"].([]interface{})
+	var result []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Property describes the value at a JSON Pointer in a section, as its
+// schema defines it. Array indices, "*" and "-" all address an array's
+// items.
+type Property struct {
+	Path        string   `json:"path"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	ItemType    string   `json:"item_type,omitempty"`
+	ItemEnum    []string `json:"item_enum,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+}
+
+// Property looks up the schema of the value at a JSON Pointer in a section.
+// It returns false if the schema has no such property.
+func (v *Validator) Property(section, pointer string) (Property, bool) {
+	schema, ok := v.documents[section]
+	if !ok {
+		return Property{}, false
+	}
+	s := &scorer{definitions: object(schema["definitions"])}
+
+	node := schema
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		if items := object(node["items"]); items != nil {
+			node = s.resolve(items)
+			continue
+		}
+		node = s.resolve(object(object(node["properties"])[segment]))
+		if node == nil {
+			return Property{}, false
+		}
+	}
+
+	property := Property{Path: pointer, Enum: enum(node)}
+	property.Title, _ = node["title"].(string)
+	property.Description, _ = node["description"].(string)
+	property.Type, _ = node["type"].(string)
+	if minimum, ok := node["minimum"].(float64); ok {
+		property.Minimum = &minimum
+	}
+	if maximum, ok := node["maximum"].(float64); ok {
+		property.Maximum = &maximum
+	}
+	if items := s.resolve(object(node["items"])); items != nil {
+		property.ItemType, _ = items["type"].(string)
+		property.ItemEnum = enum(items)
+	}
+	return property, true
+}
@@ -0,0 +1,305 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BrokenReference is an ID in one section that does not resolve to an item
+// in the section it points at.
+type BrokenReference struct {
+	Section string `json:"section"`
+	Path    string `json:"path"`
+	ID      string `json:"id"`
+	Target  string `json:"target"`
+	Message string `json:"message"`
+
+	field string
+}
+
+// Key identifies a broken reference by the field and ID involved, ignoring
+// array positions so that reordering a list does not make an existing broken
+// link look new.
+func (b BrokenReference) Key() string {
+	return b.Section + ":" + b.field + "=" + b.ID
+}
+
+// reference describes a field holding IDs of items in another section. The
+// path is slash separated and "*" walks every element of an array; the field
+// at the end may hold a single ID or a list of IDs.
+type reference struct {
+	section string
+	path    string
+	target  string
+}
+
+var references = []reference{
+	{"threats", "threats/*/relevant_adversaries", "adversaries"},
+	{"threats", "threats/*/targeted_assets", "assets"},
+	{"risks", "risks/*/asset_id", "assets"},
+	{"risks", "risks/*/threat_id", "threats"},
+	{"risks", "risks/*/adversary_id", "adversaries"},
+	{"risks", "risks/*/mitigation_id", "mitigations"},
+	{"risks", "risk_summary/top_risk_ids", "risks"},
+	{"risks", "risk_summary/vulnerability_driven_risks", "risks"},
+	{"adversaries", "adversary_summary/primary_adversaries", "adversaries"},
+	{"mitigations", "mitigations/*/risk_ids", "risks"},
+	{"mitigations", "mitigations/*/dependencies", "mitigations"},
+	{"mitigations", "action_plan_summary/immediate_actions", "mitigations"},
+	{"mitigations", "action_plan_summary/short_term_actions", "mitigations"},
+	{"mitigations", "action_plan_summary/long_term_actions", "mitigations"},
+	{"mitigations", "action_plan_summary/quick_wins", "mitigations"},
+}
+
+// identifiers maps each referenced section to the path of its item IDs.
+var identifiers = map[string]string{
+	"assets":      "assets/*/asset_id",
+	"adversaries": "adversaries/*/adversary_id",
+	"threats":     "threats/*/threat_id",
+	"risks":       "risks/*/risk_id",
+	"mitigations": "mitigations/*/mitigation_id",
+}
+
+// CheckReferences resolves every cross-section ID reference in a profile and
+// returns the ones that point at nothing. A reference into a section that
+// has not been filled in yet is reported as broken too.
+func CheckReferences(sections map[string]*string) []BrokenReference {
+	documents := make(map[string]interface{})
+	for name, data := range sections {
+		if data == nil {
+			continue
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(*data), &doc); err == nil {
+			documents[name] = doc
+		}
+	}
+
+	known := make(map[string]map[string]bool)
+	for section, path := range identifiers {
+		ids := make(map[string]bool)
+		for _, v := range collectValues(documents[section], path) {
+			ids[v.id] = true
+		}
+		known[section] = ids
+	}
+
+	broken := []BrokenReference{}
+	for _, ref := range references {
+		for _, v := range collectValues(documents[ref.section], ref.path) {
+			if v.id == "" || known[ref.target][v.id] {
+				continue
+			}
+			broken = append(broken, BrokenReference{
+				Section: ref.section,
+				Path:    v.pointer,
+				ID:      v.id,
+				Target:  ref.target,
+				Message: fmt.Sprintf("%s %q not found in %s", singular(ref.target), v.id, ref.target),
+				field:   ref.path,
+			})
+		}
+	}
+
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].Section != broken[j].Section {
+			return broken[i].Section < broken[j].Section
+		}
+		return broken[i].Path < broken[j].Path
+	})
+
+	return broken
+}
+
+// ItemIDField returns the list that holds a section's referenced items, the
+// field of their IDs and the kind of item, such as "assets", "asset_id" and
+// "asset". It returns false for sections without item IDs.
+func ItemIDField(section string) (list, field, kind string, ok bool) {
+	path, ok := identifiers[section]
+	if !ok {
+		return "", "", "", false
+	}
+	parts := strings.Split(path, "/")
+	return parts[0], parts[len(parts)-1], singular(section), true
+}
+
+// ItemIDs returns the IDs of the items in a section that other sections
+// reference, such as the asset IDs in assets. It is empty for sections
+// without item IDs and for sections that are not filled in.
+func ItemIDs(section string, data *string) map[string]bool {
+	ids := make(map[string]bool)
+	path, ok := identifiers[section]
+	if !ok || data == nil {
+		return ids
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(*data), &doc); err != nil {
+		return ids
+	}
+	for _, v := range collectValues(doc, path) {
+		ids[v.id] = true
+	}
+	return ids
+}
+
+type idValue struct {
+	pointer string
+	id      string
+}
+
+func collectValues(doc interface{}, path string) []idValue {
+	var values []idValue
+	walk(doc, strings.Split(path, "/"), "", &values)
+	return values
+}
+
+func walk(node interface{}, segments []string, pointer string, values *[]idValue) {
+	if node == nil {
+		return
+	}
+
+	if len(segments) == 0 {
+		switch v := node.(type) {
+		case string:
+			*values = append(*values, idValue{pointer: pointer, id: v})
+		case []interface{}:
+			for i, item := range v {
+				if id, ok := item.(string); ok {
+					*values = append(*values, idValue{pointer: pointer + "/" + strconv.Itoa(i), id: id})
+				}
+			}
+		}
+		return
+	}
+
+	segment := segments[0]
+	if segment == "*" {
+		items, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			walk(item, segments[1:], pointer+"/"+strconv.Itoa(i), values)
+		}
+		return
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	walk(object[segment], segments[1:], pointer+"/"+segment, values)
+}
+
+func singular(section string) string {
+	switch section {
+	case "adversaries":
+		return "adversary"
+	default:
+		return strings.TrimSuffix(section, "s")
+	}
+}
+
+// RemapIDs gives every item in the sections that others reference a new ID
+// from newID, which is called with the kind of item, such as "asset", and
+// rewrites the references to match. References to IDs that are not part of
+// sections are left alone. It returns the old to new ID mapping per section.
+func RemapIDs(sections map[string]*string, newID func(kind string) string) (map[string]map[string]string, error) {
+	documents := make(map[string]interface{})
+	for name, data := range sections {
+		if data == nil {
+			continue
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(*data), &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON in %s: %w", name, err)
+		}
+		documents[name] = doc
+	}
+
+	mapping := make(map[string]map[string]string)
+	for section, path := range identifiers {
+		doc, ok := documents[section]
+		if !ok {
+			continue
+		}
+		ids := make(map[string]string)
+		rewrite(doc, strings.Split(path, "/"), func(id string) string {
+			if id == "" {
+				return id
+			}
+			if _, ok := ids[id]; !ok {
+				ids[id] = newID(singular(section))
+			}
+			return ids[id]
+		})
+		mapping[section] = ids
+	}
+
+	for _, ref := range references {
+		doc, ok := documents[ref.section]
+		if !ok {
+			continue
+		}
+		ids := mapping[ref.target]
+		rewrite(doc, strings.Split(ref.path, "/"), func(id string) string {
+			if remapped, ok := ids[id]; ok {
+				return remapped
+			}
+			return id
+		})
+	}
+
+	for name, doc := range documents {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		remapped := string(data)
+		sections[name] = &remapped
+	}
+
+	return mapping, nil
+}
+
+// rewrite replaces the IDs found along path in place, following the same
+// path syntax as walk.
+func rewrite(node interface{}, segments []string, fn func(id string) string) interface{} {
+	if len(segments) == 0 {
+		switch v := node.(type) {
+		case string:
+			return fn(v)
+		case []interface{}:
+			for i, item := range v {
+				if id, ok := item.(string); ok {
+					v[i] = fn(id)
+				}
+			}
+		}
+		return node
+	}
+
+	if segments[0] == "*" {
+		items, ok := node.([]interface{})
+		if !ok {
+			return node
+		}
+		for i, item := range items {
+			items[i] = rewrite(item, segments[1:], fn)
+		}
+		return node
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	if child, ok := object[segments[0]]; ok {
+		object[segments[0]] = rewrite(child, segments[1:], fn)
+	}
+	return node
+}
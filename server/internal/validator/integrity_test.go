@@ -0,0 +1,73 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRemapIDs(t *testing.T) {
+	assets := `{"assets": [{"asset_id": "a1"}, {"asset_id": "a2"}]}`
+	threats := `{"threats": [{"threat_id": "t1", "targeted_assets": ["a1", "a2", "elsewhere"]}]}`
+	risks := `{"risks": [{"risk_id": "r1", "asset_id": "a2", "threat_id": "t1", "mitigation_id": "m-stored"}],
+		"risk_summary": {"top_risk_ids": ["r1"]}}`
+	sections := map[string]*string{"assets": &assets, "threats": &threats, "risks": &risks, "mitigations": nil}
+
+	counter := 0
+	mapping, err := RemapIDs(sections, func(kind string) string {
+		counter++
+		return fmt.Sprintf("%s-new%d", kind, counter)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for section, ids := range map[string][]string{"assets": {"a1", "a2"}, "threats": {"t1"}, "risks": {"r1"}} {
+		if len(mapping[section]) != len(ids) {
+			t.Errorf("%s mapping = %v, want %d IDs", section, mapping[section], len(ids))
+		}
+	}
+	if _, ok := mapping["mitigations"]; ok {
+		t.Errorf("mapping has mitigations, which were not imported")
+	}
+
+	tests := []struct {
+		section string
+		pointer []string
+		want    interface{}
+	}{
+		{"assets", []string{"assets", "0", "asset_id"}, mapping["assets"]["a1"]},
+		{"threats", []string{"threats", "0", "threat_id"}, mapping["threats"]["t1"]},
+		{"threats", []string{"threats", "0", "targeted_assets"}, []interface{}{mapping["assets"]["a1"], mapping["assets"]["a2"], "elsewhere"}},
+		{"risks", []string{"risks", "0", "asset_id"}, mapping["assets"]["a2"]},
+		{"risks", []string{"risks", "0", "threat_id"}, mapping["threats"]["t1"]},
+		{"risks", []string{"risks", "0", "mitigation_id"}, "m-stored"},
+		{"risks", []string{"risk_summary", "top_risk_ids"}, []interface{}{mapping["risks"]["r1"]}},
+	}
+
+	for _, tt := range tests {
+		var node interface{}
+		if err := json.Unmarshal([]byte(*sections[tt.section]), &node); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range tt.pointer {
+			switch v := node.(type) {
+			case map[string]interface{}:
+				node = v[key]
+			case []interface{}:
+				node = v[0]
+			}
+		}
+		if got := node; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %v = %v, want %v", tt.section, tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestRemapIDsInvalidJSON(t *testing.T) {
+	data := `{"assets": [`
+	if _, err := RemapIDs(map[string]*string{"assets": &data}, func(string) string { return "x" }); err == nil {
+		t.Fatal("RemapIDs accepted invalid JSON")
+	}
+}
@@ -0,0 +1,133 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// AdversaryTemplate is a curated adversary profile from
+// adversary-templates.json. WhenRelevant helps people decide whether the
+// template applies; it is guidance and is not copied into profiles.
+type AdversaryTemplate struct {
+	TemplateID       string                 `json:"template_id"`
+	Name             string                 `json:"name"`
+	Category         string                 `json:"category"`
+	Description      string                 `json:"description"`
+	WhenRelevant     []string               `json:"when_relevant"`
+	AdversaryDetails map[string]interface{} `json:"adversary_details"`
+	Capability       map[string]interface{} `json:"capability"`
+	Infrastructure   map[string]interface{} `json:"infrastructure"`
+	VictimTargeting  map[string]interface{} `json:"victim_targeting"`
+}
+
+type TemplateLibrary struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Version     string              `json:"version"`
+	Templates   []AdversaryTemplate `json:"templates"`
+}
+
+func (v *Validator) loadTemplates(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read adversary templates: %w", err)
+	}
+	if err := json.Unmarshal(raw, &v.templates); err != nil {
+		return fmt.Errorf("failed to parse adversary templates: %w", err)
+	}
+	if v.templates.Templates == nil {
+		v.templates.Templates = []AdversaryTemplate{}
+	}
+	return nil
+}
+
+// AdversaryTemplates returns the adversary template library.
+func (v *Validator) AdversaryTemplates() TemplateLibrary {
+	return v.templates
+}
+
+// AdversaryTemplate returns the template with the given ID.
+func (v *Validator) AdversaryTemplate(id string) (*AdversaryTemplate, bool) {
+	for i := range v.templates.Templates {
+		if v.templates.Templates[i].TemplateID == id {
+			return &v.templates.Templates[i], true
+		}
+	}
+	return nil, false
+}
+
+// NewAdversary builds an adversaries item from a template with the given
+// adversary ID, "possible" relevance and template_id set. The template's
+// description becomes adversary_details.description. Template fields the
+// adversaries schema does not define, such as typical_targets, are left out,
+// as are values it does not allow, such as the insider template's "varies"
+// technical capability, which is left for the profile to assess.
+func (v *Validator) NewAdversary(t *AdversaryTemplate, adversaryID string) (map[string]interface{}, error) {
+	details := make(map[string]interface{}, len(t.AdversaryDetails)+1)
+	for key, value := range t.AdversaryDetails {
+		details[key] = value
+	}
+	details["description"] = t.Description
+
+	raw, err := json.Marshal(map[string]interface{}{
+		"adversary_id":      adversaryID,
+		"name":              t.Name,
+		"category":          t.Category,
+		"template_id":       t.TemplateID,
+		"relevance":         "possible",
+		"adversary_details": details,
+		"capability":        t.Capability,
+		"infrastructure":    t.Infrastructure,
+		"victim_targeting":  t.VictimTargeting,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip so the item shares nothing with the library.
+	var item map[string]interface{}
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+
+	schema := v.documents["adversaries"]
+	s := &scorer{definitions: object(schema["definitions"])}
+	items := s.resolve(object(object(object(schema["properties"])["adversaries"])["items"]))
+	s.prune(item, items)
+
+	return item, nil
+}
+
+// prune drops the keys of an object, at every depth, that its schema does
+// not list as properties or whose values are outside the property's enum.
+func (s *scorer) prune(value map[string]interface{}, schema map[string]interface{}) {
+	properties := object(schema["properties"])
+	if properties == nil {
+		return
+	}
+
+	for key, child := range value {
+		property := s.resolve(object(properties[key]))
+		if property == nil {
+			delete(value, key)
+			continue
+		}
+		if enum, ok := property["enum"].([]interface{}); ok && !contains(enum, child) {
+			delete(value, key)
+			continue
+		}
+		if nested, ok := child.(map[string]interface{}); ok {
+			s.prune(nested, property)
+		}
+	}
+}
+
+func contains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
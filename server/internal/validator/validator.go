@@ -3,13 +3,22 @@ package validator
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 type Validator struct {
-	schemas map[string]*jsonschema.Schema
+	schemas   map[string]*jsonschema.Schema
+	documents map[string]map[string]interface{}
+
+	// meta validates profile metadata, and schemaVersion is the version it
+	// pins, which versions exported profiles.
+	meta          *jsonschema.Schema
+	schemaVersion string
+
+	templates TemplateLibrary
 }
 
 type ValidationError struct {
@@ -19,7 +28,8 @@ type ValidationError struct {
 
 func New(schemasDir string) (*Validator, error) {
 	v := &Validator{
-		schemas: make(map[string]*jsonschema.Schema),
+		schemas:   make(map[string]*jsonschema.Schema),
+		documents: make(map[string]map[string]interface{}),
 	}
 
 	sectionFiles := map[string]string{
@@ -29,6 +39,12 @@ func New(schemasDir string) (*Validator, error) {
 		"threats":     "threats.schema.json",
 		"risks":       "risks.schema.json",
 		"mitigations": "mitigations.schema.json",
+
+		"opsec":                    "opsec.schema.json",
+		"response_capability":      "response-capability.schema.json",
+		"technical_deep_dive":      "technical-deep-dive.schema.json",
+		"information_operations":   "information-operations.schema.json",
+		"deep_adversary_profiling": "deep-adversary-profiling.schema.json",
 	}
 
 	compiler := jsonschema.NewCompiler()
@@ -44,17 +60,78 @@ func New(schemasDir string) (*Validator, error) {
 		}
 
 		v.schemas[section] = schema
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", filename, err)
+		}
+		var document map[string]interface{}
+		if err := json.Unmarshal(raw, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", filename, err)
+		}
+		v.documents[section] = document
+	}
+
+	if err := v.loadMeta(compiler, filepath.Join(schemasDir, "meta.schema.json")); err != nil {
+		return nil, err
+	}
+
+	if err := v.loadTemplates(filepath.Join(schemasDir, "adversary-templates.json")); err != nil {
+		return nil, err
 	}
 
 	return v, nil
 }
 
+func (v *Validator) loadMeta(compiler *jsonschema.Compiler, path string) error {
+	schema, err := compiler.Compile("file://" + path)
+	if err != nil {
+		return fmt.Errorf("failed to compile schema meta.schema.json: %w", err)
+	}
+	v.meta = schema
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read schema meta.schema.json: %w", err)
+	}
+	var document struct {
+		Properties struct {
+			SchemaVersion struct {
+				Const string `json:"const"`
+			} `json:"schema_version"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return fmt.Errorf("failed to parse schema meta.schema.json: %w", err)
+	}
+	if document.Properties.SchemaVersion.Const == "" {
+		return fmt.Errorf("meta.schema.json does not pin schema_version")
+	}
+	v.schemaVersion = document.Properties.SchemaVersion.Const
+
+	return nil
+}
+
+// SchemaVersion is the version of the profile schemas, as pinned by
+// meta.schema.json.
+func (v *Validator) SchemaVersion() string {
+	return v.schemaVersion
+}
+
 func (v *Validator) Validate(section, data string) ([]ValidationError, error) {
 	schema, ok := v.schemas[section]
 	if !ok {
 		return nil, fmt.Errorf("unknown section: %s", section)
 	}
+	return validate(schema, data)
+}
 
+// ValidateMeta validates profile metadata against meta.schema.json.
+func (v *Validator) ValidateMeta(data string) ([]ValidationError, error) {
+	return validate(v.meta, data)
+}
+
+func validate(schema *jsonschema.Schema, data string) ([]ValidationError, error) {
 	var jsonData interface{}
 	if err := json.Unmarshal([]byte(data), &jsonData); err != nil {
 		return []ValidationError{{
@@ -97,3 +174,16 @@ func (v *Validator) HasSchema(section string) bool {
 	_, ok := v.schemas[section]
 	return ok
 }
+
+// Schema returns the JSON schema document of a section as loaded.
+func (v *Validator) Schema(section string) ([]byte, bool) {
+	document, ok := v.documents[section]
+	if !ok {
+		return nil, false
+	}
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return nil, false
+	}
+	return raw, true
+}